@@ -53,6 +53,27 @@ func (f *FanOut) Do(n *goraff.Node, r *goraff.ReadableGraph, prevNode *goraff.Re
 	return f.combineResults(n)
 }
 
+// SubScaffs returns the scaff run for every input
+func (f *FanOut) SubScaffs() []*goraff.Scaff {
+	return []*goraff.Scaff{f.Scaff}
+}
+
+// NodesRead lists InNode, when set, which the inputs are read from
+func (f *FanOut) NodesRead() []string {
+	if f.InNode == "" {
+		return nil
+	}
+	return []string{f.InNode}
+}
+
+// SubGraphNodes lists InNode, which each sub-graph starts with holding its input
+func (f *FanOut) SubGraphNodes() []string {
+	if f.InNode == "" {
+		return nil
+	}
+	return []string{f.InNode}
+}
+
 func (f *FanOut) getInputs(r *goraff.ReadableGraph, prevNode *goraff.ReadableNode) ([][]byte, error) {
 	if f.InNode != "" {
 		n, err := r.FirstNodeByName(f.InNode)
@@ -0,0 +1,290 @@
+package blockactions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lordtatty/goraff"
+)
+
+// Keys the HTTP action writes to its node
+const (
+	// HTTPKeyMethod and HTTPKeyURL hold the request as it was sent, after rendering
+	HTTPKeyMethod = "http_method"
+	HTTPKeyURL    = "http_url"
+	// HTTPKeyStatus holds the response's status code
+	HTTPKeyStatus = "http_status"
+	// HTTPKeyHeaders holds one "Name: value" per response header value, sorted by name
+	HTTPKeyHeaders = "http_headers"
+	// HTTPKeyBody holds the response body
+	HTTPKeyBody = "result"
+)
+
+// defaultRetryDelay is the wait before the first retry when HTTP.RetryDelay is not set
+const defaultRetryDelay = 500 * time.Millisecond
+
+// defaultMaxRetryDelay is the longest wait between attempts when HTTP.MaxRetryDelay is not set
+const defaultMaxRetryDelay = 30 * time.Second
+
+// HTTP calls an API. Method, URL, header values and Body are templates over the graph,
+// see templateFuncs, so a URL can be "https://api/users/{{first "lookup"}}".
+type HTTP struct {
+	// Method defaults to GET
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+	// Timeout limits each attempt. Zero waits as long as the run does.
+	Timeout time.Duration
+	// Retries is how many more attempts are made after a 5xx or 429 response or a network error
+	Retries int
+	// RetryDelay is the wait before the first retry, doubled for each one after.
+	// A Retry-After header, in seconds or as an HTTP date, is used instead when the server sends one.
+	RetryDelay time.Duration
+	// MaxRetryDelay caps the wait between attempts, however long a server asks for.
+	// Defaults to 30 seconds.
+	MaxRetryDelay time.Duration
+	// Extract writes values found in a JSON response to node keys, keyed by node key.
+	// Paths are field names and array indexes joined with dots, such as "data.items.0.id".
+	// Strings are written as they are and anything else as JSON.
+	Extract map[string]string
+	// IgnoreStatus keeps the block from failing on 4xx and 5xx responses,
+	// such as when a join checks HTTPKeyStatus
+	IgnoreStatus bool
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// Validate checks the method, URL, headers and body parse
+func (h *HTTP) Validate() error {
+	return checkTemplates(h.templates(), nil)
+}
+
+// NodesRead lists the nodes the templates read by name
+func (h *HTTP) NodesRead() []string {
+	return nodesRead(h.templates(), nil)
+}
+
+func (h *HTTP) templates() map[string]string {
+	templates := map[string]string{"method": h.Method, "url": h.URL, "body": h.Body}
+	for name, v := range h.Headers {
+		templates["header "+name] = v
+	}
+	return templates
+}
+
+func (h *HTTP) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	method, err := render("method", h.Method, nil, r, t)
+	if err != nil {
+		return err
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := render("url", h.URL, nil, r, t)
+	if err != nil {
+		return err
+	}
+	body, err := render("body", h.Body, nil, r, t)
+	if err != nil {
+		return err
+	}
+	headers := http.Header{}
+	for name, v := range h.Headers {
+		value, err := render("header "+name, v, nil, r, t)
+		if err != nil {
+			return err
+		}
+		headers.Set(name, value)
+	}
+	n.SetStr(HTTPKeyMethod, method)
+	n.SetStr(HTTPKeyURL, u)
+
+	ctx := r.Context()
+	delay := h.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	maxDelay := h.MaxRetryDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxRetryDelay
+	}
+	var res *http.Response
+	var resBody []byte
+	for attempt := 1; ; attempt++ {
+		res, resBody, err = h.send(ctx, n, method, u, headers, body)
+		if attempt > h.Retries || !retryable(res, err) || ctx.Err() != nil {
+			break
+		}
+		if err == nil {
+			err = fmt.Errorf("status %d", res.StatusCode)
+		}
+		n.RecordRetry(attempt+1, err)
+		select {
+		case <-time.After(min(retryAfter(res, delay), maxDelay)):
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting to retry: %w", ctx.Err())
+		}
+		delay *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("error calling %s: %w", u, err)
+	}
+
+	n.SetStr(HTTPKeyStatus, strconv.Itoa(res.StatusCode))
+	names := make([]string, 0, len(res.Header))
+	for name := range res.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range res.Header[name] {
+			n.AddStr(HTTPKeyHeaders, name+": "+v)
+		}
+	}
+	n.Set(HTTPKeyBody, resBody)
+	if res.StatusCode >= 400 && !h.IgnoreStatus {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, u)
+	}
+	return h.extract(n, resBody)
+}
+
+// send makes one attempt and reports it to the graph's hooks
+func (h *HTTP) send(ctx context.Context, n *goraff.Node, method, u string, headers http.Header, body string) (*http.Response, []byte, error) {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, strings.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error making request: %w", err)
+	}
+	req.Header = headers.Clone()
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	start := time.Now()
+	res, err := client.Do(req)
+	var resBody []byte
+	if err == nil {
+		resBody, err = io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			err = fmt.Errorf("error reading response: %w", err)
+		}
+	}
+	n.RecordCall(h.call(start, req, res, err))
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, resBody, nil
+}
+
+func (h *HTTP) call(start time.Time, req *http.Request, res *http.Response, err error) goraff.Call {
+	c := goraff.Call{
+		Kind:     "http",
+		Provider: req.URL.Host,
+		// the method stands in for a model, so spans are named "http GET"
+		Model:      req.Method,
+		Start:      start,
+		End:        time.Now(),
+		Err:        err,
+		Attributes: map[string]string{"url": req.URL.String()},
+	}
+	if res != nil {
+		c.Attributes["status"] = strconv.Itoa(res.StatusCode)
+	}
+	return c
+}
+
+// retryable reports whether an attempt failed in a way that may pass if tried again
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		var urlErr *url.Error
+		return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled)
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+// retryAfter returns the wait the response's Retry-After header asks for, or delay when it has none
+func retryAfter(res *http.Response, delay time.Duration) time.Duration {
+	if res == nil {
+		return delay
+	}
+	v := res.Header.Get("Retry-After")
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return delay
+}
+
+func (h *HTTP) extract(n *goraff.Node, body []byte) error {
+	if len(h.Extract) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("error parsing response for extraction: %w", err)
+	}
+	keys := make([]string, 0, len(h.Extract))
+	for k := range h.Extract {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v, err := jsonPath(doc, h.Extract[key])
+		if err != nil {
+			return fmt.Errorf("error extracting %s: %w", key, err)
+		}
+		n.SetStr(key, v)
+	}
+	return nil
+}
+
+// jsonPath finds the value at a dot separated path, such as "data.items.0.id"
+func jsonPath(doc any, path string) (string, error) {
+	v := doc
+	for _, part := range strings.Split(path, ".") {
+		switch cur := v.(type) {
+		case map[string]any:
+			next, ok := cur[part]
+			if !ok {
+				return "", fmt.Errorf("%s: no field %q", path, part)
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(cur) {
+				return "", fmt.Errorf("%s: no index %q in an array of %d", path, part, len(cur))
+			}
+			v = cur[i]
+		default:
+			return "", fmt.Errorf("%s: cannot find %q in a %T", path, part, v)
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
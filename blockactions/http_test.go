@@ -0,0 +1,212 @@
+package blockactions_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTP_Do(t *testing.T) {
+	assert := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("POST", r.Method)
+		assert.Equal("/users/42", r.URL.Path)
+		assert.Equal("Bearer secret", r.Header.Get("Authorization"))
+		b, _ := io.ReadAll(r.Body)
+		assert.JSONEq(`{"note": "say \"hi\""}`, string(b))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Tag", "b")
+		w.Header().Add("X-Tag", "a")
+		_, _ = w.Write([]byte(`{"data": {"name": "sam", "age": 7, "tags": ["x", "y"]}}`))
+	}))
+	defer srv.Close()
+
+	g := &goraff.Graph{}
+	g.NewNode("lookup", nil).SetStr("result", "42")
+	trigger := g.NewNode("draft", nil)
+	trigger.SetStr("result", `say "hi"`)
+	trigger.SetStr("token", "secret")
+	n := g.NewNode("call", nil)
+	sut := &blockactions.HTTP{
+		Method:  "POST",
+		URL:     srv.URL + `/users/{{first "lookup"}}`,
+		Headers: map[string]string{"Authorization": `Bearer {{trigger "token"}}`},
+		Body:    `{"note": {{json trigger}}}`,
+		Extract: map[string]string{"name": "data.name", "age": "data.age", "second_tag": "data.tags.1", "tags": "data.tags"},
+	}
+	assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), trigger.Get()))
+
+	r := n.Get()
+	assert.Equal("POST", r.FirstStr(blockactions.HTTPKeyMethod))
+	assert.Equal(srv.URL+"/users/42", r.FirstStr(blockactions.HTTPKeyURL))
+	assert.Equal("200", r.FirstStr(blockactions.HTTPKeyStatus))
+	assert.Contains(r.AllStr(blockactions.HTTPKeyHeaders), "Content-Type: application/json")
+	assert.Contains(r.AllStr(blockactions.HTTPKeyHeaders), "X-Tag: b")
+	assert.Contains(r.AllStr(blockactions.HTTPKeyHeaders), "X-Tag: a")
+	assert.Contains(r.FirstStr(blockactions.HTTPKeyBody), `"name": "sam"`)
+	assert.Equal("sam", r.FirstStr("name"))
+	assert.Equal("7", r.FirstStr("age"))
+	assert.Equal("y", r.FirstStr("second_tag"))
+	assert.Equal(`["x","y"]`, r.FirstStr("tags"))
+}
+
+func TestHTTP_Retries(t *testing.T) {
+	assert := assert.New(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	retries := []int{}
+	callCount := 0
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{{
+		Retry: func(n *goraff.ReadableNode, attempt int, err error) { retries = append(retries, attempt) },
+		Call:  func(n *goraff.ReadableNode, c goraff.Call) { callCount++ },
+	}}}
+	n := g.NewNode("call", nil)
+	sut := &blockactions.HTTP{URL: srv.URL, Retries: 3, RetryDelay: time.Millisecond}
+	assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), nil))
+	assert.Equal("ok", n.Get().FirstStr(blockactions.HTTPKeyBody))
+	assert.Equal([]int{2, 3}, retries)
+	assert.Equal(3, callCount)
+}
+
+func TestHTTP_MaxRetryDelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter func() string
+	}{
+		{"seconds", func() string { return "3600" }},
+		{"date", func() string { return time.Now().Add(time.Hour).UTC().Format(http.TimeFormat) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					w.Header().Set("Retry-After", tt.retryAfter())
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			g := &goraff.Graph{}
+			n := g.NewNode("call", nil)
+			sut := &blockactions.HTTP{URL: srv.URL, Retries: 1, RetryDelay: time.Millisecond, MaxRetryDelay: 100 * time.Millisecond}
+			start := time.Now()
+			assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), nil))
+			// the header is followed, rather than RetryDelay, up to MaxRetryDelay
+			assert.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+			assert.Less(time.Since(start), time.Second)
+			assert.Equal("ok", n.Get().FirstStr(blockactions.HTTPKeyBody))
+			assert.Equal(int32(2), calls.Load())
+		})
+	}
+}
+
+func TestHTTP_GivesUp(t *testing.T) {
+	assert := assert.New(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("down"))
+	}))
+	defer srv.Close()
+
+	g := &goraff.Graph{}
+	n := g.NewNode("call", nil)
+	sut := &blockactions.HTTP{URL: srv.URL, Retries: 1, RetryDelay: time.Millisecond}
+	assert.EqualError(sut.Do(n, goraff.NewReadableGraph(g), nil), "unexpected status 500 from "+srv.URL)
+	assert.Equal(int32(2), calls.Load())
+	assert.Equal("500", n.Get().FirstStr(blockactions.HTTPKeyStatus))
+	assert.Equal("down", n.Get().FirstStr(blockactions.HTTPKeyBody))
+}
+
+func TestHTTP_ClientErrors(t *testing.T) {
+	assert := assert.New(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	retries := 0
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{{
+		Retry: func(n *goraff.ReadableNode, attempt int, err error) { retries++ },
+	}}}
+	r := goraff.NewReadableGraph(g)
+	sut := &blockactions.HTTP{URL: srv.URL, Retries: 3, RetryDelay: time.Millisecond}
+	// 4xx responses other than 429 are not retried, even with a Retry-After header
+	assert.EqualError(sut.Do(g.NewNode("call", nil), r, nil), "unexpected status 404 from "+srv.URL)
+	assert.Equal(int32(1), calls.Load())
+	assert.Equal(0, retries)
+
+	sut.IgnoreStatus = true
+	n := g.NewNode("call", nil)
+	assert.NoError(sut.Do(n, r, nil))
+	assert.Equal("404", n.Get().FirstStr(blockactions.HTTPKeyStatus))
+}
+
+func TestHTTP_Timeout(t *testing.T) {
+	assert := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	g := &goraff.Graph{}
+	sut := &blockactions.HTTP{URL: srv.URL, Timeout: 10 * time.Millisecond}
+	err := sut.Do(g.NewNode("call", nil), goraff.NewReadableGraph(g), nil)
+	assert.ErrorContains(err, "context deadline exceeded")
+}
+
+func TestHTTP_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"items": []}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		sut  *blockactions.HTTP
+		want string
+	}{
+		{"missing node", &blockactions.HTTP{URL: `{{first "nope"}}`}, "error rendering url template"},
+		{"no trigger", &blockactions.HTTP{URL: srv.URL, Body: `{{trigger}}`}, "no triggering node"},
+		{"bad template", &blockactions.HTTP{URL: srv.URL, Headers: map[string]string{"X": "{{"}}, "error parsing header X template"},
+		{"missing field", &blockactions.HTTP{URL: srv.URL, Extract: map[string]string{"id": "item.id"}}, `error extracting id: item.id: no field "item"`},
+		{"missing index", &blockactions.HTTP{URL: srv.URL, Extract: map[string]string{"id": "items.0"}}, `error extracting id: items.0: no index "0" in an array of 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &goraff.Graph{}
+			err := tt.sut.Do(g.NewNode("call", nil), goraff.NewReadableGraph(g), nil)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package blockactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lordtatty/goraff"
+)
+
+// Keys the HumanInput action writes to its node. The answer is written to "result".
+const (
+	HumanKeyPrompt  = "human_prompt"
+	HumanKeySchema  = "human_schema"
+	HumanKeyOptions = "human_options"
+	// HumanKeyState holds one of the HumanState values
+	HumanKeyState = "human_state"
+)
+
+// States of a HumanInput node's question
+const (
+	HumanStatePending    = "pending"
+	HumanStateAnswered   = "answered"
+	HumanStateDefaulted  = "defaulted"
+	HumanStateUnanswered = "unanswered"
+)
+
+// ErrNoAnswer is returned by Inbox.Ask when the question's deadline passes without an answer
+var ErrNoAnswer = errors.New("no answer before the deadline")
+
+type ErrQuestionNotFound struct {
+	ID string
+}
+
+func (e ErrQuestionNotFound) Error() string {
+	return "question not found: " + e.ID
+}
+
+type ErrInvalidAnswer struct {
+	Answer  string
+	Options []string
+}
+
+func (e ErrInvalidAnswer) Error() string {
+	return fmt.Sprintf("answer %q is not one of %v", e.Answer, e.Options)
+}
+
+// Question is a request for a person to answer
+type Question struct {
+	// ID is the ID of the asking node
+	ID       string
+	GraphID  string
+	NodeName string
+	Prompt   string
+	// Schema optionally describes the answer, such as a JSON schema, for UIs to build a form from
+	Schema string
+	// Options, when set, are the only answers accepted
+	Options []string
+	AskedAt time.Time
+	// Deadline is zero when the question waits indefinitely
+	Deadline time.Time
+}
+
+// Inbox holds the questions waiting for answers. Share one between the
+// HumanInput actions of a scaff and whatever delivers the answers.
+// Questions are only held in memory, by the goroutines waiting on them, so they
+// are lost when the process stops.
+type Inbox struct {
+	// Asked, when set, is called with every question as it is asked
+	Asked func(q Question)
+
+	mu      sync.Mutex
+	pending map[string]*pendingQuestion
+}
+
+type pendingQuestion struct {
+	q      Question
+	answer chan string
+}
+
+// Ask publishes the question and waits for its answer, until the question's deadline
+// passes, returning ErrNoAnswer, or ctx is done
+func (i *Inbox) Ask(ctx context.Context, q Question) (string, error) {
+	p := &pendingQuestion{q: q, answer: make(chan string, 1)}
+	i.mu.Lock()
+	if _, ok := i.pending[q.ID]; ok {
+		i.mu.Unlock()
+		return "", fmt.Errorf("question %s is already waiting for an answer", q.ID)
+	}
+	if i.pending == nil {
+		i.pending = map[string]*pendingQuestion{}
+	}
+	i.pending[q.ID] = p
+	i.mu.Unlock()
+
+	if i.Asked != nil {
+		i.Asked(q)
+	}
+	var expired <-chan time.Time
+	if !q.Deadline.IsZero() {
+		timer := time.NewTimer(time.Until(q.Deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var err error
+	select {
+	case a := <-p.answer:
+		return a, nil
+	case <-expired:
+		err = ErrNoAnswer
+	case <-ctx.Done():
+		err = fmt.Errorf("error waiting for an answer: %w", ctx.Err())
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.pending[q.ID] != p {
+		// answered just as the wait ended
+		return <-p.answer, nil
+	}
+	delete(i.pending, q.ID)
+	return "", err
+}
+
+// Answer delivers the answer to a waiting question
+func (i *Inbox) Answer(questionID, answer string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	p, ok := i.pending[questionID]
+	if !ok {
+		return ErrQuestionNotFound{ID: questionID}
+	}
+	if len(p.q.Options) > 0 && !slices.Contains(p.q.Options, answer) {
+		return ErrInvalidAnswer{Answer: answer, Options: p.q.Options}
+	}
+	delete(i.pending, questionID)
+	p.answer <- answer
+	return nil
+}
+
+// Pending returns the questions waiting for answers, oldest first
+func (i *Inbox) Pending() []Question {
+	i.mu.Lock()
+	result := make([]Question, 0, len(i.pending))
+	for _, p := range i.pending {
+		result = append(result, p.q)
+	}
+	i.mu.Unlock()
+	sort.SliceStable(result, func(a, b int) bool {
+		if result[a].AskedAt.Equal(result[b].AskedAt) {
+			return result[a].ID < result[b].ID
+		}
+		return result[a].AskedAt.Before(result[b].AskedAt)
+	})
+	return result
+}
+
+// HumanInput asks a person a question through an Inbox and writes their answer to "result".
+// Only the branch the block is on waits for the answer.
+// The question's ID is the node's ID, so UIs can answer from the node's state.
+//
+// The branch waits in its goroutine rather than suspending, so the run is not kept by a
+// runs.Store and an unanswered question does not survive a restart. Use Wait, resuming
+// the run with the answer, where the question must outlive the process.
+type HumanInput struct {
+	Inbox   *Inbox
+	Prompt  string
+	Schema  string
+	Options []string
+	// Timeout is how long to wait for an answer. Zero waits until the run is cancelled.
+	Timeout time.Duration
+	// Default is the answer used when the timeout passes. When empty, the node fails instead.
+	Default string
+}
+
+func (h *HumanInput) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	if h.Inbox == nil {
+		return fmt.Errorf("human input needs an inbox")
+	}
+	now := time.Now()
+	q := Question{
+		ID:       n.Get().ID(),
+		GraphID:  r.ID(),
+		NodeName: n.Get().Name(),
+		Prompt:   h.Prompt,
+		Schema:   h.Schema,
+		Options:  h.Options,
+		AskedAt:  now,
+	}
+	if h.Timeout > 0 {
+		q.Deadline = now.Add(h.Timeout)
+	}
+	n.SetStr(HumanKeyPrompt, h.Prompt)
+	if h.Schema != "" {
+		n.SetStr(HumanKeySchema, h.Schema)
+	}
+	for _, o := range h.Options {
+		n.AddStr(HumanKeyOptions, o)
+	}
+	n.SetStr(HumanKeyState, HumanStatePending)
+
+	state := HumanStateAnswered
+	answer, err := h.Inbox.Ask(r.Context(), q)
+	if errors.Is(err, ErrNoAnswer) && h.Default != "" {
+		state = HumanStateDefaulted
+		answer = h.Default
+		err = nil
+	}
+	if err != nil {
+		n.SetStr(HumanKeyState, HumanStateUnanswered)
+		return fmt.Errorf("error waiting for human input: %w", err)
+	}
+	n.SetStr(HumanKeyState, state)
+	n.SetStr("result", answer)
+	return nil
+}
@@ -0,0 +1,130 @@
+package blockactions_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHumanInput_WaitsOnlyItsBranch(t *testing.T) {
+	assert := assert.New(t)
+	asked := make(chan blockactions.Question, 1)
+	inbox := &blockactions.Inbox{Asked: func(q blockactions.Question) { asked <- q }}
+
+	s := goraff.NewScaff()
+	start := s.Blocks().Add("start", &blockactions.Input{Value: "draft"})
+	approve := s.Blocks().Add("approve", &blockactions.HumanInput{
+		Inbox:   inbox,
+		Prompt:  "Publish the draft?",
+		Schema:  `{"type": "string"}`,
+		Options: []string{"yes", "no"},
+	})
+	other := s.Blocks().Add("other", &blockactions.Input{Value: "busy"})
+	s.SetEntrypoint(start)
+	require.NoError(t, s.Joins().Add(start, approve, nil))
+	require.NoError(t, s.Joins().Add(start, other, nil))
+
+	g := &goraff.Graph{}
+	r := goraff.NewReadableGraph(g)
+	done := make(chan error)
+	go func() { done <- s.Go(g) }()
+
+	q := <-asked
+	assert.Equal("approve", q.NodeName)
+	assert.Equal(r.ID(), q.GraphID)
+	assert.Equal("Publish the draft?", q.Prompt)
+	assert.Equal([]string{"yes", "no"}, q.Options)
+	assert.True(q.Deadline.IsZero())
+	assert.Equal([]blockactions.Question{q}, inbox.Pending())
+
+	// the other branch finishes while the question waits
+	assert.Eventually(func() bool {
+		n, err := r.FirstNodeByName("other")
+		return err == nil && n.Done()
+	}, 2*time.Second, 5*time.Millisecond)
+	n, err := r.Node(q.ID)
+	require.NoError(t, err)
+	assert.Equal(blockactions.HumanStatePending, n.FirstStr(blockactions.HumanKeyState))
+	assert.Equal("Publish the draft?", n.FirstStr(blockactions.HumanKeyPrompt))
+	assert.Equal(`{"type": "string"}`, n.FirstStr(blockactions.HumanKeySchema))
+	assert.Equal([]string{"yes", "no"}, n.AllStr(blockactions.HumanKeyOptions))
+
+	assert.Equal(blockactions.ErrInvalidAnswer{Answer: "maybe", Options: []string{"yes", "no"}}, inbox.Answer(q.ID, "maybe"))
+	assert.NoError(inbox.Answer(q.ID, "yes"))
+	assert.NoError(<-done)
+	assert.Equal("yes", n.FirstStr("result"))
+	assert.Equal(blockactions.HumanStateAnswered, n.FirstStr(blockactions.HumanKeyState))
+	assert.Empty(inbox.Pending())
+	assert.Equal(blockactions.ErrQuestionNotFound{ID: q.ID}, inbox.Answer(q.ID, "yes"))
+}
+
+func TestHumanInput_Timeout(t *testing.T) {
+	assert := assert.New(t)
+	inbox := &blockactions.Inbox{}
+
+	g := &goraff.Graph{}
+	n := g.NewNode("approve", nil)
+	sut := &blockactions.HumanInput{Inbox: inbox, Prompt: "ok?", Timeout: 10 * time.Millisecond, Default: "no"}
+	assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), nil))
+	assert.Equal("no", n.Get().FirstStr("result"))
+	assert.Equal(blockactions.HumanStateDefaulted, n.Get().FirstStr(blockactions.HumanKeyState))
+
+	// without a default the node fails
+	n = g.NewNode("approve", nil)
+	sut.Default = ""
+	err := sut.Do(n, goraff.NewReadableGraph(g), nil)
+	assert.True(errors.Is(err, blockactions.ErrNoAnswer))
+	assert.Equal(blockactions.HumanStateUnanswered, n.Get().FirstStr(blockactions.HumanKeyState))
+	assert.Empty(inbox.Pending())
+}
+
+func TestHumanInput_NoInbox(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	err := (&blockactions.HumanInput{}).Do(g.NewNode("approve", nil), goraff.NewReadableGraph(g), nil)
+	assert.EqualError(err, "human input needs an inbox")
+}
+
+func TestInbox_AskCancelled(t *testing.T) {
+	assert := assert.New(t)
+	sut := &blockactions.Inbox{}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := sut.Ask(ctx, blockactions.Question{ID: "q1"})
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Empty(sut.Pending())
+}
+
+func TestInbox_Pending(t *testing.T) {
+	assert := assert.New(t)
+	sut := &blockactions.Inbox{}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i, id := range []string{"b", "a", "c"} {
+		q := blockactions.Question{ID: id, AskedAt: now.Add(time.Duration(i) * time.Second)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = sut.Ask(ctx, q)
+		}()
+	}
+	assert.Eventually(func() bool { return len(sut.Pending()) == 3 }, time.Second, 5*time.Millisecond)
+	ids := []string{}
+	for _, q := range sut.Pending() {
+		ids = append(ids, q.ID)
+	}
+	assert.Equal([]string{"b", "a", "c"}, ids)
+
+	_, err := sut.Ask(ctx, blockactions.Question{ID: "a"})
+	assert.EqualError(err, "question a is already waiting for an answer")
+	cancel()
+	wg.Wait()
+}
@@ -8,10 +8,36 @@ import (
 
 type Input struct {
 	Value string
+	// FromNode and FromKey, when set, copy the value from another node, such as runs.InputsNode.
+	// Value is used when that node has no such key.
+	FromNode string
+	FromKey  string
+}
+
+// NodesRead lists FromNode, when set
+func (l *Input) NodesRead() []string {
+	if l.FromNode == "" {
+		return nil
+	}
+	return []string{l.FromNode}
 }
 
 func (l *Input) Do(s *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
 	fmt.Println("Running Input Node")
-	s.SetStr("result", l.Value)
+	value := l.Value
+	if l.FromNode != "" {
+		n, err := r.FirstNodeByName(l.FromNode)
+		if err != nil {
+			return fmt.Errorf("error getting input node: %w", err)
+		}
+		key := l.FromKey
+		if key == "" {
+			key = "result"
+		}
+		if len(n.All(key)) > 0 {
+			value = n.FirstStr(key)
+		}
+	}
+	s.SetStr("result", value)
 	return nil
 }
@@ -0,0 +1,39 @@
+package blockactions_test
+
+import (
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInput_Do(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	n := g.NewNode("input", nil)
+	sut := &blockactions.Input{Value: "hello"}
+	assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), nil))
+	assert.Equal("hello", n.Get().FirstStr("result"))
+}
+
+func TestInput_FromNode(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	g.NewNode("inputs", nil).SetStr("topic", "otters")
+	r := goraff.NewReadableGraph(g)
+
+	n := g.NewNode("topic", nil)
+	sut := &blockactions.Input{FromNode: "inputs", FromKey: "topic", Value: "default"}
+	assert.NoError(sut.Do(n, r, nil))
+	assert.Equal("otters", n.Get().FirstStr("result"))
+
+	// the value is the fallback for a missing key
+	n = g.NewNode("mood", nil)
+	sut = &blockactions.Input{FromNode: "inputs", FromKey: "mood", Value: "cheerful"}
+	assert.NoError(sut.Do(n, r, nil))
+	assert.Equal("cheerful", n.Get().FirstStr("result"))
+
+	sut = &blockactions.Input{FromNode: "missing"}
+	assert.ErrorContains(sut.Do(n, r, nil), "error getting input node")
+}
@@ -2,45 +2,129 @@ package blockactions
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/keys"
+)
+
+// Keys the LLM action writes to its node, see package keys
+const (
+	// LLMKeySystemMsg holds the exact system message sent to the client
+	LLMKeySystemMsg = keys.LLMSystemMsg
+	// LLMKeyUserMsg holds the exact user message sent to the client, including any included outputs
+	LLMKeyUserMsg = keys.LLMUserMsg
+	// LLMKeyResult holds the response, updated as it streams in
+	LLMKeyResult = keys.Result
 )
 
 type LLMClient interface {
 	Chat(systemMsg, userMsg string, stream chan string) (string, error)
 }
 
+// LLMModel is implemented by clients that can say which provider and model they call
+type LLMModel interface {
+	Provider() string
+	ModelName() string
+}
+
+// LLM sends a system and user message to a client and streams the response into the node.
+// Both messages are templates over the graph, see templateFuncs, such as
+// "Review this draft:\n{{trigger}}", and can include Prompts with {{template "name"}}.
 type LLM struct {
-	SystemMsg      string
-	UserMsg        string
-	Client         LLMClient
+	SystemMsg string
+	UserMsg   string
+	Client    LLMClient
+	// IncludeOutputs puts the result of each named node before the user message, between
+	// <name> and </name> tags. Use templates instead for any other layout.
 	IncludeOutputs []string
+	Prompts        *Prompts
+}
+
+// Validate checks the messages parse and include only prompts that exist
+func (l *LLM) Validate() error {
+	return checkTemplates(l.templates(), l.Prompts)
+}
+
+// NodesRead lists the nodes the messages and prompts read by name, and the included outputs
+func (l *LLM) NodesRead() []string {
+	result := nodesRead(l.templates(), l.Prompts)
+	for _, name := range l.IncludeOutputs {
+		if !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func (l *LLM) templates() map[string]string {
+	return map[string]string{"system": l.SystemMsg, "user": l.UserMsg}
 }
 
 func (l *LLM) Do(s *goraff.Node, r *goraff.ReadableGraph, triggeringNode *goraff.ReadableNode) error {
 	fmt.Println("Running LLM Node")
-	msg, err := l.buildIncludes(r)
+	system, err := render("system", l.SystemMsg, l.Prompts, r, triggeringNode)
+	if err != nil {
+		return err
+	}
+	msg, err := render("user", l.UserMsg, l.Prompts, r, triggeringNode)
+	if err != nil {
+		return err
+	}
+	includes, err := l.buildIncludes(r)
 	if err != nil {
 		return fmt.Errorf("error building includes: %w", err)
 	}
-	msg = msg + "\n\n" + l.UserMsg
+	if includes != "" {
+		msg = includes + "\n" + msg
+	}
+	s.SetStr(LLMKeySystemMsg, system)
+	s.SetStr(LLMKeyUserMsg, msg)
 	streamCh := make(chan string)
+	start := time.Now()
 	go func() {
-		_, e := l.Client.Chat(l.SystemMsg, msg, streamCh)
+		_, e := l.Client.Chat(system, msg, streamCh)
 		err = e
 		close(streamCh)
 	}()
 	result := ""
 	for r := range streamCh {
 		result += r
-		s.SetStr("result", result)
+		s.SetStr(LLMKeyResult, result)
 	}
+	s.RecordCall(l.call(start, system+msg, result, err))
 	if err != nil {
 		return fmt.Errorf("failed to chat: %w", err)
 	}
 	return nil
 }
 
+func (l *LLM) call(start time.Time, prompt, result string, err error) goraff.Call {
+	c := goraff.Call{
+		Kind:         "llm",
+		Provider:     "unknown",
+		Model:        "unknown",
+		Start:        start,
+		End:          time.Now(),
+		InputTokens:  estimateTokens(prompt),
+		OutputTokens: estimateTokens(result),
+		Err:          err,
+		Attributes:   map[string]string{"tokens_estimated": "true"},
+	}
+	if m, ok := l.Client.(LLMModel); ok {
+		c.Provider = m.Provider()
+		c.Model = m.ModelName()
+	}
+	return c
+}
+
+// estimateTokens approximates a token count, as clients do not report usage.
+// Roughly four characters per token holds for English text with most tokenizers.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
 func (l *LLM) buildIncludes(r *goraff.ReadableGraph) (string, error) {
 	result := ""
 	for _, output := range l.IncludeOutputs {
@@ -48,15 +132,7 @@ func (l *LLM) buildIncludes(r *goraff.ReadableGraph) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("error getting node state: %w", err)
 		}
-		wants := n.FirstStr("result")
-		n, err = r.FirstNodeByName(output)
-		if err != nil {
-			return "", fmt.Errorf("error getting node state: %w", err)
-		}
-		name := n.FirstStr("name")
-		wantStr := fmt.Sprintf("NAME: %s", name)
-		resultStr := fmt.Sprintf("RESULT: %s", wants)
-		result += fmt.Sprintf("### OUTPUT BLOCK START###\n%s\n%s\n### OUTPUT BLOCK END###\n", wantStr, resultStr)
+		result += fmt.Sprintf("<%s>\n%s\n</%s>\n", n.Name(), n.FirstStr("result"), n.Name())
 	}
 	return result, nil
 }
@@ -52,6 +52,9 @@ func TestLLM_Do(t *testing.T) {
 	s := &goraff.Graph{Notifier: ntfy}
 	r := goraff.NewReadableGraph(s)
 	ntfy.Listen(func(ntfy goraff.GraphChangeNotification) {
+		if ntfy.Op != goraff.ChangeOpSet || ntfy.Key != blockactions.LLMKeyResult {
+			return
+		}
 		msgIdx++
 		want := strings.Join(expectedMessages[:msgIdx], "")
 		n, err := r.FirstNodeByName("node1")
@@ -64,4 +67,50 @@ func TestLLM_Do(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(msgIdx, len(expectedMessages))
 	assert.Equal(expectedResult, n.Get().FirstStr("result"))
+	assert.Equal(expectedSystemMsg, n.Get().FirstStr(blockactions.LLMKeySystemMsg))
+	assert.Equal(expectedUserMsg, n.Get().FirstStr(blockactions.LLMKeyUserMsg))
+}
+
+func TestLLM_DoTemplates(t *testing.T) {
+	assert := assert.New(t)
+	prompts := &blockactions.Prompts{}
+	assert.NoError(prompts.Add("tone", `Be as {{first "style"}} as a {{first "style" "animal"}}.`))
+
+	g := &goraff.Graph{}
+	style := g.NewNode("style", nil)
+	style.SetStr("result", "brief")
+	style.SetStr("animal", "cat")
+	g.NewNode("notes", nil).SetStr("result", "likes fish")
+	trigger := g.NewNode("draft", nil)
+	trigger.SetStr("result", "a story")
+	n := g.NewNode("review", nil)
+
+	wantSystem := "You review stories. Be as brief as a cat."
+	wantUser := "<notes>\nlikes fish\n</notes>\n\nReview a story"
+	mClient := mocks.NewLLMClient(t)
+	mClient.EXPECT().Chat(wantSystem, wantUser, mock.Anything).Return("", nil)
+	sut := &blockactions.LLM{
+		SystemMsg:      `You review stories. {{template "tone"}}`,
+		UserMsg:        "Review {{trigger}}",
+		Client:         mClient,
+		IncludeOutputs: []string{"notes"},
+		Prompts:        prompts,
+	}
+	assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), trigger.Get()))
+	assert.Equal(wantSystem, n.Get().FirstStr(blockactions.LLMKeySystemMsg))
+	assert.Equal(wantUser, n.Get().FirstStr(blockactions.LLMKeyUserMsg))
+}
+
+func TestLLM_DoTemplateErrors(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	r := goraff.NewReadableGraph(g)
+	// the client is never called
+	mClient := mocks.NewLLMClient(t)
+
+	sut := &blockactions.LLM{SystemMsg: `{{template "missing"}}`, Client: mClient}
+	assert.ErrorContains(sut.Do(g.NewNode("a", nil), r, nil), "error rendering system template")
+
+	sut = &blockactions.LLM{UserMsg: `{{first "nope"}}`, Client: mClient}
+	assert.ErrorContains(sut.Do(g.NewNode("b", nil), r, nil), "Node with name nope not found")
 }
@@ -0,0 +1,86 @@
+package blockactions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// PromptExt is the extension of the files LoadPrompts reads
+const PromptExt = ".tmpl"
+
+// Prompts are named templates, such as instructions shared by several LLM blocks,
+// that an LLM's messages include with {{template "name"}}. Prompts use the same
+// functions as the messages, and read the graph of the block including them.
+type Prompts struct {
+	tmpl *template.Template
+}
+
+// LoadPrompts reads every PromptExt file in dir, naming each prompt after its file without the extension
+func LoadPrompts(dir string) (*Prompts, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+PromptExt))
+	if err != nil {
+		return nil, fmt.Errorf("error listing prompts: %w", err)
+	}
+	p := &Prompts{}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading prompt: %w", err)
+		}
+		if err := p.Add(strings.TrimSuffix(filepath.Base(path), PromptExt), string(b)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return p, nil
+}
+
+// Add parses text as the prompt called name, replacing any prompt with that name
+func (p *Prompts) Add(name, text string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid prompt name %q", name)
+	}
+	if p.tmpl == nil {
+		p.tmpl = template.New("").Funcs(templateFuncs(nil, nil))
+	}
+	if _, err := p.tmpl.New(name).Parse(text); err != nil {
+		return fmt.Errorf("error parsing prompt %s: %w", name, err)
+	}
+	return nil
+}
+
+// Names returns the name of every prompt, sorted
+func (p *Prompts) Names() []string {
+	result := []string{}
+	if p == nil || p.tmpl == nil {
+		return result
+	}
+	for _, t := range p.tmpl.Templates() {
+		if t.Name() != "" {
+			result = append(result, t.Name())
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// parse parses text as a template named name that can include the prompts.
+// Its functions are placeholders until bound to a graph with Funcs.
+func (p *Prompts) parse(name, text string) (*template.Template, error) {
+	var tmpl *template.Template
+	if p == nil || p.tmpl == nil {
+		tmpl = template.New(name).Funcs(templateFuncs(nil, nil))
+	} else {
+		// a clone per template keeps concurrent blocks from binding each other's graphs
+		set, err := p.tmpl.Clone()
+		if err != nil {
+			return nil, err
+		}
+		// no prompt file can be named with a "/", so the text cannot replace one
+		tmpl = set.New("/" + name)
+	}
+	return tmpl.Parse(text)
+}
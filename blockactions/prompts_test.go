@@ -0,0 +1,38 @@
+package blockactions_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPrompts(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tone.tmpl"), []byte("Be brief."), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "review.tmpl"), []byte(`{{template "tone"}} Review {{trigger}}.`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a prompt"), 0o600))
+
+	p, err := blockactions.LoadPrompts(dir)
+	assert.NoError(err)
+	assert.Equal([]string{"review", "tone"}, p.Names())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte("{{"), 0o600))
+	_, err = blockactions.LoadPrompts(dir)
+	assert.ErrorContains(err, "broken.tmpl: error parsing prompt broken")
+}
+
+func TestPrompts_Add(t *testing.T) {
+	assert := assert.New(t)
+	p := &blockactions.Prompts{}
+	assert.NoError(p.Add("a", "one"))
+	assert.NoError(p.Add("a", "two"))
+	assert.Equal([]string{"a"}, p.Names())
+	assert.EqualError(p.Add("a/b", "x"), `invalid prompt name "a/b"`)
+	assert.EqualError(p.Add("", "x"), `invalid prompt name ""`)
+	assert.Empty((*blockactions.Prompts)(nil).Names())
+}
@@ -17,3 +17,7 @@ func (g *ScaffNode) Do(s *goraff.Node, r *goraff.ReadableGraph, triggeringNS *go
 	g.Scaff.Go(graph)
 	return nil
 }
+
+func (g *ScaffNode) SubScaffs() []*goraff.Scaff {
+	return []*goraff.Scaff{g.Scaff}
+}
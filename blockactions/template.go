@@ -0,0 +1,38 @@
+package blockactions
+
+import "github.com/lordtatty/goraff"
+
+// Template renders Text, a text/template over the graph, see templateFuncs.
+// It assembles text from upstream nodes, such as:
+//
+//	Summaries:
+//	{{range sub "fanout/*/summarise"}}- {{truncate 200 .}}
+//	{{end}}
+type Template struct {
+	Text string
+	// Key is where the rendered text is written, defaulting to "result"
+	Key string
+}
+
+// Validate checks the text parses
+func (tp *Template) Validate() error {
+	return checkTemplates(map[string]string{"text": tp.Text}, nil)
+}
+
+// NodesRead lists the nodes the text reads by name
+func (tp *Template) NodesRead() []string {
+	return nodesRead(map[string]string{"text": tp.Text}, nil)
+}
+
+func (tp *Template) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	out, err := render("text", tp.Text, nil, r, t)
+	if err != nil {
+		return err
+	}
+	key := tp.Key
+	if key == "" {
+		key = "result"
+	}
+	n.SetStr(key, out)
+	return nil
+}
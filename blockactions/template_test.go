@@ -0,0 +1,91 @@
+package blockactions_test
+
+import (
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/stretchr/testify/assert"
+)
+
+func templateGraph() (*goraff.Graph, *goraff.ReadableNode) {
+	g := &goraff.Graph{}
+	g.NewNode("topic", nil).SetStr("result", "otters")
+	for _, draft := range []string{"first draft", "second draft"} {
+		n := g.NewNode("draft", nil)
+		n.SetStr("result", draft)
+		n.AddStr("tags", draft+" a")
+		n.AddStr("tags", draft+" b")
+	}
+	fan := g.NewNode("fanout", nil)
+	for _, item := range []string{"x", "y"} {
+		sub := &goraff.Graph{}
+		fan.AddSubGraph(sub)
+		sub.NewNode("item", nil).SetStr("result", item)
+	}
+	trigger := g.NewNode("review", nil)
+	trigger.SetStr("result", `looks "good"`)
+	trigger.SetStr("score", "9")
+	return g, trigger.Get()
+}
+
+func TestTemplate_Do(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "no actions", "no actions"},
+		{"first", `{{first "topic"}} / {{first "draft"}}`, "otters / first draft"},
+		{"latest", `{{latest "draft"}}`, "second draft"},
+		{"all", `{{range all "draft"}}[{{.}}]{{end}}`, "[first draft][second draft]"},
+		{"all key", `{{all "draft" "tags" | join ","}}`, "first draft a,first draft b,second draft a,second draft b"},
+		{"trigger", `{{trigger}} scored {{trigger "score"}}`, `looks "good" scored 9`},
+		{"sub", `{{join "+" (sub "fanout/*/item")}}`, "x+y"},
+		{"json", `{"review": {{json trigger}}}`, `{"review": "looks \"good\""}`},
+		{"truncate", `{{truncate 5 (first "topic")}}|{{first "topic" | truncate 50}}`, "otter|otters"},
+		{"missing key", `[{{first "topic" "nope"}}]`, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			g, trigger := templateGraph()
+			n := g.NewNode("out", nil)
+			sut := &blockactions.Template{Text: tt.text}
+			assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), trigger))
+			assert.Equal(tt.want, n.Get().FirstStr("result"))
+		})
+	}
+}
+
+func TestTemplate_Key(t *testing.T) {
+	assert := assert.New(t)
+	g, trigger := templateGraph()
+	n := g.NewNode("out", nil)
+	sut := &blockactions.Template{Text: `about {{first "topic"}}`, Key: "title"}
+	assert.NoError(sut.Do(n, goraff.NewReadableGraph(g), trigger))
+	assert.Equal("about otters", n.Get().FirstStr("title"))
+	assert.Empty(n.Get().All("result"))
+}
+
+func TestTemplate_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"parse", `{{first "topic"`, "error parsing text template"},
+		{"missing node", `{{first "nope"}}`, "Node with name nope not found"},
+		{"missing latest", `{{latest "nope"}}`, "Node with name nope not found"},
+		{"bad path", `{{sub "fanout/*"}}`, `invalid path "fanout/*"`},
+		{"too many keys", `{{first "topic" "a" "b"}}`, "expected at most one key, got 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, trigger := templateGraph()
+			sut := &blockactions.Template{Text: tt.text}
+			err := sut.Do(g.NewNode("out", nil), goraff.NewReadableGraph(g), trigger)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package blockactions
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/lordtatty/goraff"
+)
+
+// templateFuncs are the functions available to the text/templates actions render, over the
+// graph the action runs on and the node that triggered it. Key defaults to "result" throughout.
+//
+//	{{first "node"}}              the first value of "result" on the first node named node
+//	{{first "node" "key"}}        the first value of key instead
+//	{{latest "node" "key"}}       the first value of key on the most recent node named node, such as in a loop
+//	{{all "node" "key"}}          every value of key on every node named node, in the order they were created
+//	{{trigger "key"}}             the first value of key on the triggering node
+//	{{sub "fanout/*/item" "key"}} the first value of key on each node a sub-graph path matches, see ReadableGraph.Query
+//	{{join ", " (all "node")}}    the values joined with a separator, which pipes as {{all "node" | join ", "}}
+//	{{json .}}                    the value encoded as JSON, such as to put a string in a JSON body
+//	{{truncate 100 .}}            at most the first 100 characters of the value
+func templateFuncs(r *goraff.ReadableGraph, t *goraff.ReadableNode) template.FuncMap {
+	return template.FuncMap{
+		"first": func(node string, key ...string) (string, error) {
+			k, err := templateKey(key)
+			if err != nil {
+				return "", err
+			}
+			n, err := r.FirstNodeByName(node)
+			if err != nil {
+				return "", err
+			}
+			return n.FirstStr(k), nil
+		},
+		"latest": func(node string, key ...string) (string, error) {
+			k, err := templateKey(key)
+			if err != nil {
+				return "", err
+			}
+			n, err := r.LatestNodeByName(node)
+			if err != nil {
+				return "", err
+			}
+			return n.FirstStr(k), nil
+		},
+		"all": func(node string, key ...string) ([]string, error) {
+			k, err := templateKey(key)
+			if err != nil {
+				return nil, err
+			}
+			result := []string{}
+			for _, n := range r.NodesByName(node) {
+				result = append(result, n.AllStr(k)...)
+			}
+			return result, nil
+		},
+		"trigger": func(key ...string) (string, error) {
+			k, err := templateKey(key)
+			if err != nil {
+				return "", err
+			}
+			if t == nil {
+				return "", fmt.Errorf("no triggering node")
+			}
+			return t.FirstStr(k), nil
+		},
+		"sub": func(path string, key ...string) ([]string, error) {
+			k, err := templateKey(key)
+			if err != nil {
+				return nil, err
+			}
+			nodes, err := r.Query(path)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]string, 0, len(nodes))
+			for _, n := range nodes {
+				result = append(result, n.FirstStr(k))
+			}
+			return result, nil
+		},
+		"join": func(sep string, values []string) string {
+			return strings.Join(values, sep)
+		},
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		},
+		"truncate": func(n int, s string) string {
+			runes := []rune(s)
+			if n < 0 || len(runes) <= n {
+				return s
+			}
+			return string(runes[:n])
+		},
+	}
+}
+
+// templateKey returns the optional key given to a template function, defaulting to "result"
+func templateKey(key []string) (string, error) {
+	switch len(key) {
+	case 0:
+		return "result", nil
+	case 1:
+		return key[0], nil
+	}
+	return "", fmt.Errorf("expected at most one key, got %d", len(key))
+}
+
+// render renders text as a template named name, which can include prompts.
+// Text without actions is returned as it is.
+func render(name, text string, prompts *Prompts, r *goraff.ReadableGraph, t *goraff.ReadableNode) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := prompts.parse(name, text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s template: %w", name, err)
+	}
+	b := &strings.Builder{}
+	if err := tmpl.Funcs(templateFuncs(r, t)).Execute(b, nil); err != nil {
+		return "", fmt.Errorf("error rendering %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// checkTemplates reports the first of the templates, keyed by name, that does not parse
+// or that includes a prompt that does not exist
+func checkTemplates(templates map[string]string, prompts *Prompts) error {
+	for _, name := range sortedNames(templates) {
+		if _, err := TemplateRefs(templates[name], prompts); err != nil {
+			return fmt.Errorf("error checking %s template: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// nodesRead lists the nodes the templates read with first, latest and all, each once.
+// Templates that do not parse are left to checkTemplates.
+func nodesRead(templates map[string]string, prompts *Prompts) []string {
+	result := []string{}
+	for _, name := range sortedNames(templates) {
+		refs, _ := TemplateRefs(templates[name], prompts)
+		for _, ref := range refs {
+			if ref.Func != "trigger" && ref.Func != "sub" && !slices.Contains(result, ref.Node) {
+				result = append(result, ref.Node)
+			}
+		}
+	}
+	return result
+}
+
+func sortedNames(templates map[string]string) []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateRef is a value a template reads with first, latest, all, trigger or sub,
+// with Node empty for trigger and the path for sub. Calls whose node or key is not a literal string are left out.
+type TemplateRef struct {
+	Func string
+	Node string
+	Key  string
+}
+
+// TemplateRefs lists the values text reads, including through the prompts it includes,
+// so a scaff can be checked before it runs. Including a prompt that does not exist is an error.
+func TemplateRefs(text string, prompts *Prompts) ([]TemplateRef, error) {
+	tmpl, err := prompts.parse("refs", text)
+	if err != nil {
+		return nil, err
+	}
+	w := &refWalker{tmpl: tmpl, seen: map[string]bool{}}
+	if err := w.walk(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return w.refs, nil
+}
+
+type refWalker struct {
+	tmpl *template.Template
+	seen map[string]bool
+	refs []TemplateRef
+}
+
+func (w *refWalker) walk(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := w.walk(c); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return w.walk(n.Pipe)
+	case *parse.IfNode:
+		return w.walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		return w.walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return w.walkBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		if err := w.walk(n.Pipe); err != nil {
+			return err
+		}
+		if w.seen[n.Name] {
+			return nil
+		}
+		w.seen[n.Name] = true
+		included := w.tmpl.Lookup(n.Name)
+		if included == nil || included.Tree == nil {
+			return fmt.Errorf("no prompt named %q", n.Name)
+		}
+		return w.walk(included.Tree.Root)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for i, c := range n.Cmds {
+			// later commands take their last argument from the pipe, so their literals are not a node and key
+			if i == 0 {
+				w.add(c)
+			}
+			for _, a := range c.Args {
+				if err := w.walk(a); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (w *refWalker) walkBranch(b *parse.BranchNode) error {
+	for _, n := range []parse.Node{b.Pipe, b.List, b.ElseList} {
+		if err := w.walk(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add records the command if it reads a node value with literal arguments
+func (w *refWalker) add(c *parse.CommandNode) {
+	id, ok := c.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return
+	}
+	args := []string{}
+	for _, a := range c.Args[1:] {
+		s, ok := a.(*parse.StringNode)
+		if !ok {
+			return
+		}
+		args = append(args, s.Text)
+	}
+	switch id.Ident {
+	case "first", "latest", "all", "sub":
+		if len(args) == 0 {
+			return
+		}
+		if key, err := templateKey(args[1:]); err == nil {
+			w.refs = append(w.refs, TemplateRef{Func: id.Ident, Node: args[0], Key: key})
+		}
+	case "trigger":
+		if key, err := templateKey(args); err == nil {
+			w.refs = append(w.refs, TemplateRef{Func: id.Ident, Key: key})
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package blockactions_test
+
+import (
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateRefs(t *testing.T) {
+	assert := assert.New(t)
+	prompts := &blockactions.Prompts{}
+	assert.NoError(prompts.Add("style", `{{first "style" "tone"}}{{template "style"}}`))
+
+	refs, err := blockactions.TemplateRefs(`{{first "topic"}}
+{{if latest "check" "ok"}}{{range all "draft" | join ","}}{{end}}{{else}}{{trigger "score"}}{{end}}
+{{truncate 10 (first "summary")}} {{"x" | first "skipped"}} {{first .}} {{template "style"}} {{sub "fanout/*/item" "title"}}`, prompts)
+	assert.NoError(err)
+	assert.Equal([]blockactions.TemplateRef{
+		{Func: "first", Node: "topic", Key: "result"},
+		{Func: "latest", Node: "check", Key: "ok"},
+		{Func: "all", Node: "draft", Key: "result"},
+		{Func: "trigger", Key: "score"},
+		{Func: "first", Node: "summary", Key: "result"},
+		{Func: "first", Node: "style", Key: "tone"},
+		{Func: "sub", Node: "fanout/*/item", Key: "title"},
+	}, refs)
+
+	_, err = blockactions.TemplateRefs(`{{template "style"}}`, nil)
+	assert.EqualError(err, `no prompt named "style"`)
+	_, err = blockactions.TemplateRefs(`{{first`, nil)
+	assert.ErrorContains(err, "unclosed action")
+}
+
+func TestValidate(t *testing.T) {
+	prompts := &blockactions.Prompts{}
+	assert.NoError(t, prompts.Add("style", "Be brief."))
+	tests := []struct {
+		name   string
+		action goraff.BlockValidator
+		want   string
+	}{
+		{"llm", &blockactions.LLM{SystemMsg: `{{template "style"}}`, UserMsg: `{{first "topic"}}`, Prompts: prompts}, ""},
+		{"llm missing prompt", &blockactions.LLM{UserMsg: `{{template "tone"}}`, Prompts: prompts}, `error checking user template: no prompt named "tone"`},
+		{"llm parse", &blockactions.LLM{SystemMsg: `{{first`}, "error checking system template"},
+		{"template", &blockactions.Template{Text: `{{trigger}}`}, ""},
+		{"template parse", &blockactions.Template{Text: `{{trigger`}, "error checking text template"},
+		{"http", &blockactions.HTTP{URL: `http://api/{{first "id"}}`, Headers: map[string]string{"X": "{{trigger}}"}}, ""},
+		{"http header", &blockactions.HTTP{URL: "http://api", Headers: map[string]string{"X": "{{trigger"}}, "error checking header X template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.action.Validate()
+			if tt.want == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestValidate_NodesRead(t *testing.T) {
+	assert := assert.New(t)
+	item := goraff.NewScaff()
+	item.SetEntrypoint(item.Blocks().Add("upper", &blockactions.Template{Text: `{{first "topic"}} {{first "write"}}`}))
+	s := goraff.NewScaff()
+	topic := s.Blocks().Add("topic", &blockactions.Input{FromNode: goraff.InputsNode, FromKey: "topic"})
+	write := s.Blocks().Add("write", &blockactions.Template{Text: `{{first "topic"}} {{first "missing" "key"}}`})
+	s.SetEntrypoint(topic)
+	assert.NoError(s.Joins().Add(topic, write, nil))
+	assert.EqualError(s.Validate(), `error validating block write: reads node "missing", which is not a block`)
+
+	// a fan-out's scaff reads its own blocks and the in node holding its item, but not the blocks around the fan-out
+	s.Blocks().Get(write).Action = &blockactions.FanOut{Scaff: item, InNode: "topic"}
+	assert.EqualError(s.Validate(), `error validating block write: error validating block upper: reads node "write", which is not a block`)
+	item.Blocks().Get("upper").Action = &blockactions.Template{Text: `{{first "topic"}}`}
+	assert.NoError(s.Validate())
+}
@@ -0,0 +1,31 @@
+package blockactions
+
+import (
+	"fmt"
+
+	"github.com/lordtatty/goraff"
+)
+
+// Wait suspends its branch until the run is resumed with Event, such as a webhook arriving,
+// without keeping a goroutine waiting. The event's data is written to Key.
+type Wait struct {
+	Event string
+	// Key defaults to "result"
+	Key string
+}
+
+func (w *Wait) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	if w.Event == "" {
+		return fmt.Errorf("wait needs an event")
+	}
+	return goraff.Suspend(w.Event)
+}
+
+func (w *Wait) Resume(n *goraff.Node, r *goraff.ReadableGraph, e goraff.Event) error {
+	key := w.Key
+	if key == "" {
+		key = "result"
+	}
+	n.Set(key, e.Data)
+	return nil
+}
@@ -0,0 +1,34 @@
+package blockactions_test
+
+import (
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWait_SuspendsUntilResumed(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	wait := s.Blocks().Add("wait", &blockactions.Wait{Event: "payment", Key: "receipt"})
+	after := s.Blocks().Add("after", &blockactions.Input{FromNode: "wait", FromKey: "receipt"})
+	s.SetEntrypoint(wait)
+	require.NoError(t, s.Joins().Add(wait, after, nil))
+
+	g := &goraff.Graph{}
+	assert.Equal(goraff.ErrRunSuspended{Events: []string{"payment"}}, s.Go(g))
+	assert.Nil(g.FirstNodeByName("after"))
+
+	assert.NoError(s.Resume(g, goraff.Event{Name: "payment", Data: []byte("paid")}))
+	assert.Equal("paid", g.FirstNodeByName("wait").Get().FirstStr("receipt"))
+	assert.Equal("paid", g.FirstNodeByName("after").Get().FirstStr("result"))
+}
+
+func TestWait_NoEvent(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	err := (&blockactions.Wait{}).Do(g.NewNode("wait", nil), goraff.NewReadableGraph(g), nil)
+	assert.EqualError(err, "wait needs an event")
+}
@@ -40,6 +40,29 @@ type BlockAction interface {
 	Do(s *Node, r *ReadableGraph, triggeringNS *ReadableNode) error
 }
 
+// BlockValidator is implemented by block actions that can find their own problems before a run,
+// such as a template that does not parse. Scaff.Validate calls it for every block.
+type BlockValidator interface {
+	Validate() error
+}
+
+// NodeReader is implemented by block actions that read nodes by name, such as from templates,
+// so Scaff.Validate can check each is a block
+type NodeReader interface {
+	NodesRead() []string
+}
+
+// SubScaffer is implemented by block actions that run other scaffs
+type SubScaffer interface {
+	SubScaffs() []*Scaff
+}
+
+// SubGraphSeeder is implemented by SubScaffers whose scaffs' graphs start with nodes
+// that are not blocks, such as a fan-out's item, so Scaff.Validate knows they can be read
+type SubGraphSeeder interface {
+	SubGraphNodes() []string
+}
+
 // Block represents a node in the graph
 type Block struct {
 	Action BlockAction
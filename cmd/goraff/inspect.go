@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lordtatty/goraff/httpapi"
+	"github.com/lordtatty/goraff/outputs"
+)
+
+// savedRun holds either an Output, as written by run -out, or a RunDetail from the HTTP API
+type savedRun struct {
+	httpapi.RunDetail
+	outputs.Output
+}
+
+func inspectCmd(args []string, stdout, stderr io.Writer) error {
+	fs := flags("inspect", stderr)
+	width := fs.Int("width", 200, "fold values longer than this, zero to print them in full")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goraff inspect [flags] FILE")
+		fs.PrintDefaults()
+	}
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	b, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error reading run: %w", err)
+	}
+	var saved savedRun
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("error parsing run: %w", err)
+	}
+	out := &saved.Output
+	if saved.RunDetail.Output != nil {
+		info := saved.RunInfo
+		fmt.Fprintf(stdout, "run %s of %s: %s\n", info.ID, info.Scaff, info.Status)
+		if info.Error != "" {
+			fmt.Fprintf(stdout, "error: %s\n", info.Error)
+		}
+		out = saved.RunDetail.Output
+	}
+	if out.PrimaryStateID == "" {
+		return fmt.Errorf("%s does not hold a saved run", fs.Arg(0))
+	}
+	p := &printer{w: stdout, out: out, width: *width}
+	p.graph(out.PrimaryStateID, 0)
+	return nil
+}
+
+// printer writes an Output as an indented tree of graphs and nodes
+type printer struct {
+	w     io.Writer
+	out   *outputs.Output
+	width int
+}
+
+func (p *printer) line(depth int, format string, args ...any) {
+	fmt.Fprintf(p.w, "%s%s\n", strings.Repeat("  ", depth), fmt.Sprintf(format, args...))
+}
+
+func (p *printer) graph(id string, depth int) {
+	p.line(depth, "graph %s", id)
+	for _, st := range p.out.States {
+		if st.ID != id {
+			continue
+		}
+		for _, nodeID := range st.NodeIDs {
+			for i := range p.out.Nodes {
+				if p.out.Nodes[i].ID == nodeID {
+					p.node(&p.out.Nodes[i], depth+1)
+				}
+			}
+		}
+	}
+}
+
+func (p *printer) node(n *outputs.NodeOutput, depth int) {
+	p.line(depth, "%s [%s]", n.Name, n.Status)
+	if n.Error != "" {
+		p.value(depth+1, "error", n.Error)
+	}
+	for _, v := range n.Vals {
+		for _, value := range v.Values {
+			p.value(depth+1, v.Name, value)
+		}
+	}
+	for _, sub := range n.SubGraphIDs {
+		p.graph(sub, depth+1)
+	}
+}
+
+func (p *printer) value(depth int, key, value string) {
+	if p.width > 0 && len(value) > p.width {
+		value = fmt.Sprintf("%s... (%d characters)", value[:p.width], len(value))
+	}
+	lines := strings.Split(value, "\n")
+	p.line(depth, "%s: %s", key, lines[0])
+	for _, l := range lines[1:] {
+		p.line(depth, "%s  %s", strings.Repeat(" ", len(key)), l)
+	}
+}
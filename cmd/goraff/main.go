@@ -0,0 +1,104 @@
+// Command goraff works with scaffs defined in JSON, as described by scaffdef.Def.
+//
+//	goraff validate FILE...               check definitions
+//	goraff run [flags] FILE               run a scaff and print its results
+//	goraff viz [-format mermaid|dot] FILE draw a scaff
+//	goraff serve [flags] DIR              serve the scaffs in a directory over HTTP and websocket
+//	goraff inspect [flags] FILE           print a saved run
+//
+// LLM credentials come from the environment, such as GROQ_API_KEY.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const usage = `usage: goraff <command> [arguments]
+
+commands:
+  validate FILE...   check scaff definitions
+  run FILE           run a scaff and print its results
+  viz FILE           draw a scaff as Mermaid or DOT
+  serve DIR          serve the scaffs in a directory over HTTP and websocket
+  inspect FILE       print a run saved with run -out, or fetched from /api/runs/{id}
+
+Run goraff <command> -h for a command's flags.
+LLM credentials come from the environment, such as GROQ_API_KEY.
+`
+
+func main() {
+	os.Exit(cli(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// cli runs the command and returns the exit code
+func cli(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	commands := map[string]func(args []string, stdout, stderr io.Writer) error{
+		"validate": validateCmd,
+		"run":      runCmd,
+		"viz":      vizCmd,
+		"serve":    serveCmd,
+		"inspect":  inspectCmd,
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			fmt.Fprint(stdout, usage)
+			return 0
+		}
+		fmt.Fprintf(stderr, "goraff: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	err := cmd(args[1:], stdout, stderr)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, new(usageError)):
+		fmt.Fprintf(stderr, "goraff %s: %s\n", args[0], err.Error())
+		return 2
+	}
+	fmt.Fprintf(stderr, "goraff %s: %s\n", args[0], err.Error())
+	return 1
+}
+
+// usageError is returned for missing or unexpected arguments
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func flags(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parse parses the flags and checks the number of remaining arguments is between min and max.
+// A max below zero allows any number.
+func parse(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError(err.Error())
+	}
+	n := fs.NArg()
+	if n < min {
+		return usageError("missing arguments")
+	}
+	if max >= 0 && n > max {
+		return usageError(fmt.Sprintf("unexpected arguments: %s", strings.Join(fs.Args()[max:], " ")))
+	}
+	return nil
+}
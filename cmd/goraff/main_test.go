@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/lordtatty/goraff/scaffdef"
+	"github.com/lordtatty/goraff/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const echoDef = `{
+	"name": "echo",
+	"entrypoint": "topic",
+	"blocks": [
+		{"name": "topic", "type": "input", "from_node": "inputs", "from_key": "topic", "value": "nothing"},
+		{"name": "again", "type": "input", "from_node": "topic"}
+	],
+	"joins": [{"from": "topic", "to": "again"}]
+}`
+
+const llmDef = `{
+	"entrypoint": "ask",
+	"blocks": [{"name": "ask", "type": "llm", "provider": "groq", "user": "hello"}]
+}`
+
+func write(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func exec(args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := cli(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI_Usage(t *testing.T) {
+	assert := assert.New(t)
+	code, _, stderr := exec()
+	assert.Equal(2, code)
+	assert.Contains(stderr, "usage: goraff")
+
+	code, _, stderr = exec("frobnicate")
+	assert.Equal(2, code)
+	assert.Contains(stderr, `unknown command "frobnicate"`)
+
+	code, _, stderr = exec("run")
+	assert.Equal(2, code)
+	assert.Contains(stderr, "goraff run: missing arguments")
+
+	code, _, _ = exec("help")
+	assert.Equal(0, code)
+}
+
+func TestCLI_Validate(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	good := write(t, dir, "good.json", echoDef)
+	bad := write(t, dir, "bad.json", `{"entrypoint": "a", "blocks": [{"name": "a", "type": "llm"}, {"name": "b", "type": "print"}]}`)
+
+	code, stdout, _ := exec("validate", good)
+	assert.Equal(0, code)
+	assert.Equal(good+": ok\n", stdout)
+
+	code, stdout, stderr := exec("validate", good, bad)
+	assert.Equal(1, code)
+	assert.Contains(stdout, bad+": block a: llm needs a system or user message\n")
+	assert.Contains(stdout, bad+": block a: llm needs a provider\n")
+	assert.Contains(stdout, bad+": block b is not reachable from the entrypoint\n")
+	assert.Contains(stderr, "1 of 2 definitions are invalid")
+}
+
+func TestCLI_Run(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	def := write(t, dir, "echo.json", echoDef)
+	inputs := write(t, dir, "inputs.json", `{"topic": "from file"}`)
+	out := filepath.Join(dir, "out.json")
+
+	code, stdout, stderr := exec("run", "-inputs", inputs, "-input", "topic=otters", "-out", out, def)
+	assert.Equal(0, code, stderr)
+	assert.Contains(stdout, "== topic (done)\notters\n== again (done)\notters\n")
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var o outputs.Output
+	require.NoError(t, json.Unmarshal(b, &o))
+	assert.Len(o.Nodes, 3)
+
+	code, stdout, _ = exec("inspect", out)
+	assert.Equal(0, code)
+	assert.Contains(stdout, "graph "+o.PrimaryStateID+"\n  inputs [done]\n    topic: otters\n  topic [done]\n    result: otters\n")
+
+	code, _, stderr = exec("run", "-input", "nokey", def)
+	assert.Equal(2, code)
+	assert.Contains(stderr, "input must be key=value")
+}
+
+func TestCLI_RunFailed(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	def := write(t, dir, "broken.json", `{"entrypoint": "fan", "blocks": [
+		{"name": "fan", "type": "fanout", "in_node": "inputs", "in_key": "topic", "out_node": "missing", "scaff": {
+			"entrypoint": "b", "blocks": [{"name": "b", "type": "print"}]
+		}}
+	]}`)
+	code, stdout, stderr := exec("run", "-input", "topic=otters", def)
+	assert.Equal(1, code)
+	assert.Contains(stdout, "== fan (failed)\nerror: ")
+	assert.Contains(stderr, "goraff run: run failed: ")
+
+	t.Setenv(scaffdef.EnvGroqAPIKey, "")
+	code, _, stderr = exec("run", write(t, dir, "llm.json", llmDef))
+	assert.Equal(1, code)
+	assert.Contains(stderr, "GROQ_API_KEY is not set")
+}
+
+func TestCLI_RunSuspended(t *testing.T) {
+	assert := assert.New(t)
+	def := write(t, t.TempDir(), "hook.json", `{"entrypoint": "hook", "blocks": [{"name": "hook", "type": "wait", "event": "paid"}]}`)
+	code, stdout, stderr := exec("run", def)
+	assert.Equal(1, code)
+	assert.Contains(stdout, "== hook (suspended)\n")
+	assert.Contains(stderr, "goraff run: run suspended until paid, and goraff run cannot resume it")
+}
+
+func TestCLI_RunAsksOnTerminal(t *testing.T) {
+	assert := assert.New(t)
+	def := write(t, t.TempDir(), "ask.json", `{"entrypoint": "approve", "blocks": [
+		{"name": "approve", "type": "human_input", "prompt": "Ship it?", "options": ["yes", "no"]}
+	]}`)
+	stdin = strings.NewReader("maybe\nyes\n")
+	t.Cleanup(func() { stdin = os.Stdin })
+
+	code, stdout, stderr := exec("run", def)
+	assert.Equal(0, code, stderr)
+	assert.Equal("approve asks: Ship it?\nanswer one of: yes, no\n> answer \"maybe\" is not one of [yes no]\n> ", stderr)
+	assert.Contains(stdout, "== approve (done)\nyes\n")
+}
+
+func TestCLI_Viz(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	// drawing needs no credentials
+	t.Setenv(scaffdef.EnvGroqAPIKey, "")
+	llm := write(t, dir, "llm.json", llmDef)
+	def := write(t, dir, "echo.json", echoDef)
+
+	code, stdout, stderr := exec("viz", llm)
+	assert.Equal(0, code, stderr)
+	assert.Contains(stdout, "flowchart")
+	assert.Contains(stdout, "ask")
+
+	code, stdout, _ = exec("viz", "-format", "dot", def)
+	assert.Equal(0, code)
+	assert.Contains(stdout, "digraph")
+
+	code, _, stderr = exec("viz", "-format", "png", def)
+	assert.Equal(2, code)
+	assert.Contains(stderr, `unknown format "png"`)
+}
+
+func TestCLI_InspectRunDetail(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	long := strings.Repeat("x", 30)
+	path := write(t, dir, "run.json", `{
+		"id": "run-1", "scaff": "story", "status": "failed", "error": "boom",
+		"output": {
+			"primary_state_id": "g1",
+			"states": [{"id": "g1", "node_ids": ["n1"]}, {"id": "g2", "node_ids": ["n2"]}],
+			"nodes": [
+				{"id": "n1", "name": "fan", "status": "done", "vals": [], "subgraph_ids": ["g2"]},
+				{"id": "n2", "name": "item", "status": "failed", "error": "bad item", "vals": [{"name": "result", "values": ["line one\nline two", "`+long+`"]}], "subgraph_ids": []}
+			]
+		}
+	}`)
+	code, stdout, stderr := exec("inspect", "-width", "20", path)
+	assert.Equal(0, code, stderr)
+	want := `run run-1 of story: failed
+error: boom
+graph g1
+  fan [done]
+    graph g2
+      item [failed]
+        error: bad item
+        result: line one
+                line two
+        result: xxxxxxxxxxxxxxxxxxxx... (30 characters)
+`
+	assert.Equal(want, stdout)
+
+	code, _, stderr = exec("inspect", write(t, dir, "empty.json", `{}`))
+	assert.Equal(1, code)
+	assert.Contains(stderr, "does not hold a saved run")
+}
+
+func TestServe_Handler(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	write(t, dir, "echo.json", echoDef)
+	write(t, dir, "second.json", strings.Replace(echoDef, `"name": "echo",`, "", 1))
+	reg, err := loadDir(dir, &scaffdef.Builder{})
+	require.NoError(t, err)
+	assert.Equal([]string{"echo", "second"}, reg.Names())
+
+	m := runs.NewRunManager(reg)
+	m.StreamQueue = 4
+	ws := websocket.NewWebSocketServer("")
+	m.Broadcast(ws)
+	srv := httptest.NewServer(handler(m, ws, instrument(m)))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/scaffs")
+	require.NoError(t, err)
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.JSONEq(`[{"name": "echo"}, {"name": "second"}]`, string(b))
+
+	res, err = http.Get(srv.URL + "/")
+	require.NoError(t, err)
+	b, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Contains(string(b), `"websocket_url":"/ws"`)
+
+	run, err := m.Start("echo", nil)
+	require.NoError(t, err)
+	_, err = m.Wait(context.Background(), run.ID())
+	require.NoError(t, err)
+	res, err = http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	b, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Contains(string(b), `goraff_runs_total{status="done"} 1`)
+	assert.Contains(string(b), "goraff_stream_dropped_total 0\n")
+
+	write(t, dir, "dupe.json", echoDef)
+	_, err = loadDir(dir, &scaffdef.Builder{})
+	assert.ErrorContains(err, "are both named echo")
+
+	_, err = loadDir(t.TempDir(), &scaffdef.Builder{})
+	assert.ErrorContains(err, "no .json scaff definitions")
+}
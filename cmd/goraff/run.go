@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/lordtatty/goraff/scaffdef"
+)
+
+// stdin is where run reads answers to human_input questions
+var stdin io.Reader = os.Stdin
+
+// inputFlags collects repeated -input key=value flags
+type inputFlags map[string]string
+
+func (f inputFlags) String() string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+f[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f inputFlags) Set(v string) error {
+	k, val, ok := strings.Cut(v, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("input must be key=value")
+	}
+	f[k] = val
+	return nil
+}
+
+// build loads, validates and builds the definition at path, and returns the
+// name it runs under: the definition's name, or else the file's name
+func build(path string, b *scaffdef.Builder) (string, *goraff.Scaff, error) {
+	d, err := scaffdef.Load(path)
+	if err != nil {
+		return "", nil, err
+	}
+	s, err := b.Build(d)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+	name := d.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return name, s, nil
+}
+
+func runCmd(args []string, stdout, stderr io.Writer) error {
+	fs := flags("run", stderr)
+	inputs := inputFlags{}
+	fs.Var(inputs, "input", "an input as key=value, may be repeated")
+	inputsFile := fs.String("inputs", "", "a JSON file of inputs, {\"key\": \"value\"}, overridden by -input")
+	out := fs.String("out", "", "write the run's Output JSON to this file, for goraff inspect")
+	timeout := fs.Duration("timeout", 0, "cancel the run after this long")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goraff run [flags] FILE")
+		fs.PrintDefaults()
+	}
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	all := map[string]string{}
+	if *inputsFile != "" {
+		b, err := os.ReadFile(*inputsFile)
+		if err != nil {
+			return fmt.Errorf("error reading inputs: %w", err)
+		}
+		if err := json.Unmarshal(b, &all); err != nil {
+			return fmt.Errorf("error parsing inputs: %w", err)
+		}
+	}
+	for k, v := range inputs {
+		all[k] = v
+	}
+
+	inbox := &blockactions.Inbox{}
+	name, s, err := build(fs.Arg(0), &scaffdef.Builder{Inbox: inbox})
+	if err != nil {
+		return err
+	}
+	reg := &runs.Registry{}
+	if err := reg.Register(name, s); err != nil {
+		return err
+	}
+	m := runs.NewRunManager(reg)
+	m.Inbox = inbox
+	askOnTerminal(inbox, stdin, stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	run, err := m.Start(name, all)
+	if err != nil {
+		return fmt.Errorf("error starting run: %w", err)
+	}
+	if _, err := m.Wait(ctx, run.ID()); err != nil {
+		// blocks that have started are left to finish
+		if err := m.Cancel(run.ID()); err != nil {
+			return fmt.Errorf("error cancelling run: %w", err)
+		}
+		<-run.Done()
+	}
+
+	printResults(stdout, run.Graph())
+	if *out != "" {
+		if err := writeOutput(*out, run.Graph()); err != nil {
+			return err
+		}
+	}
+	if run.Status() == runs.StatusSuspended {
+		return fmt.Errorf("%w, and goraff run cannot resume it", run.Err())
+	}
+	if run.Status() != runs.StatusSucceeded {
+		return fmt.Errorf("run %s: %w", run.Status(), run.Err())
+	}
+	return nil
+}
+
+// askOnTerminal prints each question as it is asked and answers it with the next line read
+func askOnTerminal(inbox *blockactions.Inbox, in io.Reader, out io.Writer) {
+	asked := make(chan blockactions.Question, 64)
+	inbox.Asked = func(q blockactions.Question) {
+		asked <- q
+	}
+	go func() {
+		lines := bufio.NewScanner(in)
+		for q := range asked {
+			fmt.Fprintf(out, "%s asks: %s\n", q.NodeName, q.Prompt)
+			if len(q.Options) > 0 {
+				fmt.Fprintf(out, "answer one of: %s\n", strings.Join(q.Options, ", "))
+			}
+			for {
+				fmt.Fprint(out, "> ")
+				if !lines.Scan() {
+					return
+				}
+				err := inbox.Answer(q.ID, strings.TrimSpace(lines.Text()))
+				if !errors.As(err, new(blockactions.ErrInvalidAnswer)) {
+					if err != nil {
+						fmt.Fprintf(out, "%s\n", err.Error())
+					}
+					break
+				}
+				fmt.Fprintf(out, "%s\n", err.Error())
+			}
+		}
+	}()
+}
+
+// printResults prints the result of every node in the run's graph
+func printResults(w io.Writer, r *goraff.ReadableGraph) {
+	for _, n := range r.Nodes() {
+		if n.Name() == runs.InputsNode {
+			continue
+		}
+		fmt.Fprintf(w, "== %s (%s)\n", n.Name(), n.Status())
+		if err := n.Err(); err != nil {
+			fmt.Fprintf(w, "error: %s\n", err.Error())
+		}
+		for _, v := range n.AllStr("result") {
+			fmt.Fprintln(w, v)
+		}
+	}
+}
+
+func writeOutput(path string, r *goraff.ReadableGraph) error {
+	b, err := json.MarshalIndent((&outputs.Outputter{}).Output(r), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling output: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/httpapi"
+	"github.com/lordtatty/goraff/metrics"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/lordtatty/goraff/scaffdef"
+	"github.com/lordtatty/goraff/websocket"
+	"github.com/lordtatty/goraff/webui"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// loadDir builds every .json definition in dir
+func loadDir(dir string, b *scaffdef.Builder) (*runs.Registry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %w", dir, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .json scaff definitions in %s", dir)
+	}
+	reg := &runs.Registry{}
+	from := map[string]string{}
+	for _, path := range paths {
+		name, s, err := build(path, b)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := from[name]; ok {
+			return nil, fmt.Errorf("%s and %s are both named %s", other, path, name)
+		}
+		from[name] = path
+		if err := reg.Register(name, s); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return reg, nil
+}
+
+// instrument collects the metrics of the manager's runs, including changes dropped from their streams
+func instrument(m *runs.RunManager) *metrics.Collector {
+	c := metrics.NewCollector(nil)
+	m.Hooks = append(m.Hooks, c.Hooks())
+	c.WatchDropped("goraff_stream_dropped_total", m.Dropped)
+	return c
+}
+
+// handler serves the API under /api, the websocket on /ws, metrics on /metrics and the live viewer on /
+func handler(m *runs.RunManager, ws *websocket.WebSocketServer, c *metrics.Collector) http.Handler {
+	mux := http.NewServeMux()
+	api := httpapi.New(m)
+	api.OnError = m.OnError
+	mux.Handle("/api/", http.StripPrefix("/api", api.Handler()))
+	mux.Handle("/ws", ws.Handler())
+	mux.Handle("/metrics", c.Registry.Handler())
+	mux.Handle("/", &webui.Viewer{WebSocketURL: "/ws", OnError: m.OnError})
+	return mux
+}
+
+func serveCmd(args []string, stdout, stderr io.Writer) error {
+	fs := flags("serve", stderr)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	maxConcurrent := fs.Int("max-concurrent", 0, "runs to execute at once, zero for no limit")
+	retention := fs.Duration("retention", time.Hour, "how long finished runs are kept, zero to keep them all")
+	store := fs.String("store", "", "keep suspended runs in this directory, so they can be resumed after a restart")
+	streamQueue := fs.Int("stream-queue", 256, "changes queued for each run's live stream before writes to the same key are coalesced, zero to send them as they happen")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goraff serve [flags] DIR")
+		fs.PrintDefaults()
+	}
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	inbox := &blockactions.Inbox{}
+	reg, err := loadDir(fs.Arg(0), &scaffdef.Builder{Inbox: inbox})
+	if err != nil {
+		return err
+	}
+	m := runs.NewRunManager(reg)
+	m.OnError = func(err error) {
+		fmt.Fprintln(stderr, err)
+	}
+	m.MaxConcurrent = *maxConcurrent
+	m.Retention = *retention
+	m.Inbox = inbox
+	m.StreamQueue = *streamQueue
+	m.StreamOverflow = notifiers.OverflowCoalesce
+	collector := instrument(m)
+	if *store != "" {
+		m.Store = &runs.FileStore{Dir: *store}
+		// runs that cannot be recovered stay in the store, and the rest are still served
+		if err := m.Recover(); err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+	ws := websocket.NewWebSocketServer(*addr)
+	ws.Controller = m
+	m.Broadcast(ws)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv := &http.Server{Addr: *addr, Handler: handler(m, ws, collector)}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	fmt.Fprintf(stdout, "serving %v on %s\n", reg.Names(), *addr)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("error serving: %w", err)
+	case <-ctx.Done():
+	}
+	// hijacked websocket connections are not closed by Shutdown
+	ws.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("error shutting down:", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lordtatty/goraff/scaffdef"
+)
+
+func validateCmd(args []string, stdout, stderr io.Writer) error {
+	fs := flags("validate", stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goraff validate FILE...")
+	}
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	invalid := 0
+	for _, path := range fs.Args() {
+		d, err := scaffdef.Load(path)
+		if err == nil {
+			err = d.Validate()
+		}
+		if err == nil {
+			fmt.Fprintf(stdout, "%s: ok\n", path)
+			continue
+		}
+		invalid++
+		for _, problem := range problems(err) {
+			fmt.Fprintf(stdout, "%s: %s\n", path, problem)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d definitions are invalid", invalid, fs.NArg())
+	}
+	return nil
+}
+
+// problems splits errors made with errors.Join back apart
+func problems(err error) []string {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return []string{err.Error()}
+	}
+	result := []string{}
+	for _, e := range joined.Unwrap() {
+		result = append(result, problems(e)...)
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/scaffdef"
+)
+
+// offlineClient stands in for LLM clients when a scaff is only drawn, so no credentials are needed
+type offlineClient struct {
+	provider string
+	model    string
+}
+
+func (c *offlineClient) Chat(systemMsg, userMsg string, stream chan string) (string, error) {
+	return "", fmt.Errorf("%s client is offline", c.provider)
+}
+
+func (c *offlineClient) Provider() string {
+	return c.provider
+}
+
+func (c *offlineClient) ModelName() string {
+	return c.model
+}
+
+func offlineClients(provider, model string) (blockactions.LLMClient, error) {
+	return &offlineClient{provider: provider, model: model}, nil
+}
+
+func vizCmd(args []string, stdout, stderr io.Writer) error {
+	fs := flags("viz", stderr)
+	format := fs.String("format", "mermaid", "mermaid or dot")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goraff viz [-format mermaid|dot] FILE")
+		fs.PrintDefaults()
+	}
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	if *format != "mermaid" && *format != "dot" {
+		return usageError(fmt.Sprintf("unknown format %q", *format))
+	}
+	_, s, err := build(fs.Arg(0), &scaffdef.Builder{Clients: offlineClients})
+	if err != nil {
+		return err
+	}
+	if *format == "dot" {
+		fmt.Fprintln(stdout, outputs.ScaffDOT(s))
+		return nil
+	}
+	fmt.Fprintln(stdout, outputs.ScaffMermaid(s))
+	return nil
+}
@@ -1,13 +1,42 @@
 package goraff
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ChangeOp is the kind of change a GraphChangeNotification describes
+type ChangeOp string
+
+const (
+	ChangeOpAdd      ChangeOp = "add"
+	ChangeOpSet      ChangeOp = "set"
+	ChangeOpStatus   ChangeOp = "status"
+	ChangeOpSubGraph ChangeOp = "subgraph"
+	ChangeOpNode     ChangeOp = "node"
+)
+
+// GraphChangeNotification describes a single change to a node in a graph
 type GraphChangeNotification struct {
-	NodeID string
+	// Seq increases with every change, so notifications can be put back in order
+	Seq     uint64
+	GraphID string
+	// ParentGraphIDs lists the graphs above GraphID, outermost first
+	ParentGraphIDs []string
+	NodeID         string
+	NodeName       string
+	// Key is empty for status and subgraph changes
+	Key string
+	Op  ChangeOp
+	// Index is the position of the appended value within Key for adds
+	Index int
+	// Value holds the new value for sets, only the appended value for adds,
+	// the new status for status changes and the sub-graph ID for subgraph changes
+	Value []byte
 }
 
 type ChangeNotifier interface {
@@ -17,43 +46,139 @@ type ChangeNotifier interface {
 // Graph manages the state of all nodes in the graph
 type Graph struct {
 	id       string
+	parent   *Node
 	nodes    []*Node
+	byName   map[string][]*Node
+	byID     map[string]*Node
+	ctx      context.Context
+	mut      sync.RWMutex
 	Notifier ChangeNotifier
+	// Journal, when set, records every write to nodes created by this graph
+	Journal *JournalConfig
+	// Hooks observe runs of scaffs on this graph
+	Hooks []*Hooks
 }
 
 func (s *Graph) NewNode(name string, trigeredBy []*ReadableNode) *Node {
-	ns := &Node{name: name, notifier: s.Notifier, triggeredBy: trigeredBy}
+	ns := &Node{id: uuid.NewString(), name: name, graph: s, triggeredBy: trigeredBy, startedAt: time.Now()}
+	if s.Journal != nil {
+		ns.journal = &journal{config: *s.Journal}
+	}
+	s.mut.Lock()
+	if s.byName == nil {
+		s.byName = make(map[string][]*Node)
+		s.byID = make(map[string]*Node)
+	}
 	s.nodes = append(s.nodes, ns)
+	s.byName[name] = append(s.byName[name], ns)
+	s.byID[ns.id] = ns
+	seq := nextSeq()
+	s.mut.Unlock()
+	ns.notify(seq, ChangeOpNode, "", 0, nil)
 	return ns
 }
 
-func (s *Graph) NodeByName(name string) []*Node {
-	result := []*Node{}
-	for _, ns := range s.nodes {
-		if ns.name == name {
-			result = append(result, ns)
-		}
+func (s *Graph) ensureID() string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.id == "" {
+		s.id = uuid.New().String()
+	}
+	return s.id
+}
+
+func (s *Graph) setContext(ctx context.Context) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.ctx = ctx
+}
+
+// context returns the context of the run on this graph, or of its parent's run
+func (s *Graph) context() context.Context {
+	s.mut.RLock()
+	ctx := s.ctx
+	s.mut.RUnlock()
+	if ctx != nil {
+		return ctx
+	}
+	if p := s.parentNode(); p != nil && p.graph != nil {
+		return p.graph.context()
+	}
+	return context.Background()
+}
+
+func (s *Graph) setParent(n *Node) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.parent = n
+}
+
+func (s *Graph) parentNode() *Node {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.parent
+}
+
+// parentIDs returns the IDs of the graphs above this one, outermost first
+func (s *Graph) parentIDs() []string {
+	ids := []string{}
+	for p := s.parentNode(); p != nil && p.graph != nil; p = p.graph.parentNode() {
+		ids = append([]string{p.graph.ensureID()}, ids...)
 	}
+	return ids
+}
+
+func (s *Graph) notify(c GraphChangeNotification) {
+	if s.Notifier == nil {
+		return
+	}
+	c.GraphID = s.ensureID()
+	c.ParentGraphIDs = s.parentIDs()
+	s.Notifier.Notify(c)
+}
+
+// allNodes returns a snapshot of the nodes in the order they were created
+func (s *Graph) allNodes() []*Node {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	result := make([]*Node, len(s.nodes))
+	copy(result, s.nodes)
+	return result
+}
+
+func (s *Graph) NodeByName(name string) []*Node {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	result := make([]*Node, len(s.byName[name]))
+	copy(result, s.byName[name])
 	return result
 }
 
 // Gets the first node
 func (s *Graph) FirstNodeByName(name string) *Node {
-	for _, ns := range s.nodes {
-		if ns.name == name {
-			return ns
-		}
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if len(s.byName[name]) == 0 {
+		return nil
 	}
-	return nil
+	return s.byName[name][0]
 }
 
-func (s *Graph) NodeByID(id string) *Node {
-	for _, ns := range s.nodes {
-		if ns.Get().ID() == id {
-			return ns
-		}
+// Gets the most recently created node with the given name
+func (s *Graph) LatestNodeByName(name string) *Node {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	nodes := s.byName[name]
+	if len(nodes) == 0 {
+		return nil
 	}
-	return nil
+	return nodes[len(nodes)-1]
+}
+
+func (s *Graph) NodeByID(id string) *Node {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.byID[id]
 }
 
 func NewReadableGraph(g *Graph) *ReadableGraph {
@@ -91,7 +216,7 @@ func (s *ReadableGraph) Node(id string) (*ReadableNode, error) {
 
 func (s *ReadableGraph) NodeIDs() []string {
 	ids := []string{}
-	for _, ns := range s.graph.nodes {
+	for _, ns := range s.graph.allNodes() {
 		ids = append(ids, ns.Get().ID())
 	}
 	return ids
@@ -99,16 +224,32 @@ func (s *ReadableGraph) NodeIDs() []string {
 
 func (s *ReadableGraph) NodeNames() []string {
 	names := []string{}
-	for _, ns := range s.graph.nodes {
+	for _, ns := range s.graph.allNodes() {
 		names = append(names, ns.name)
 	}
 	return names
 }
 
 func (s *ReadableGraph) ID() string {
-	if s.graph.id == "" {
-		id := uuid.New().String()
-		s.graph.id = id
+	return s.graph.ensureID()
+}
+
+// ParentIDs returns the IDs of the graphs this one is nested in, outermost first
+func (s *ReadableGraph) ParentIDs() []string {
+	return s.graph.parentIDs()
+}
+
+// Context returns the context of the run on this graph. Sub-graphs share their parent's.
+// Long running actions should stop when it is done.
+func (s *ReadableGraph) Context() context.Context {
+	return s.graph.context()
+}
+
+// ParentNode returns the node this graph is a sub-graph of, or nil for a top level graph
+func (s *ReadableGraph) ParentNode() *ReadableNode {
+	p := s.graph.parentNode()
+	if p == nil {
+		return nil
 	}
-	return s.graph.id
+	return p.Get()
 }
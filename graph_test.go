@@ -1,11 +1,13 @@
 package goraff_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/lordtatty/goraff"
 	"github.com/lordtatty/goraff/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestNodeState(t *testing.T) {
@@ -100,11 +102,13 @@ func TestState_StateReadOnly_ID(t *testing.T) {
 }
 
 func TestState_Notifier(t *testing.T) {
+	assert := assert.New(t)
 	// Make sure this fires when updating a node
 	mNotifier := mocks.NewChangeNotifier(t)
-	mNotifier.EXPECT().Notify(goraff.GraphChangeNotification{NodeID: "node1"}).Times(1)
-	// And let's check a second node too to be sure
-	mNotifier.EXPECT().Notify(goraff.GraphChangeNotification{NodeID: "node2"}).Times(1)
+	got := []goraff.GraphChangeNotification{}
+	mNotifier.EXPECT().Notify(mock.Anything).Run(func(n goraff.GraphChangeNotification) {
+		got = append(got, n)
+	}).Times(4)
 
 	// Create the SUT and trigger the first node to update
 	s := &goraff.Graph{Notifier: mNotifier}
@@ -113,7 +117,75 @@ func TestState_Notifier(t *testing.T) {
 
 	// Trigger the second node to update
 	n2 := s.NewNode("node2", nil)
-	n2.SetStr("key", "value")
+	n2.AddStr("list", "item")
+
+	graphID := goraff.NewReadableGraph(s).ID()
+	assert.Len(got, 4)
+	assert.Equal(goraff.ChangeOpNode, got[0].Op)
+	assert.Equal(n.Get().ID(), got[0].NodeID)
+	assert.Equal("node1", got[0].NodeName)
+
+	assert.Equal(graphID, got[1].GraphID)
+	assert.Equal(n.Get().ID(), got[1].NodeID)
+	assert.Equal("node1", got[1].NodeName)
+	assert.Equal("key", got[1].Key)
+	assert.Equal(goraff.ChangeOpSet, got[1].Op)
+	assert.Equal([]byte("value"), got[1].Value)
+
+	assert.Equal(goraff.ChangeOpNode, got[2].Op)
+	assert.Equal(n2.Get().ID(), got[3].NodeID)
+	assert.Equal("node2", got[3].NodeName)
+	assert.Equal(goraff.ChangeOpAdd, got[3].Op)
+	assert.Equal(0, got[3].Index)
+	assert.Equal([]byte("item"), got[3].Value)
+
+	for i := 1; i < len(got); i++ {
+		assert.Less(got[i-1].Seq, got[i].Seq)
+	}
+}
+
+func TestState_Notifier_StatusAndSubGraphs(t *testing.T) {
+	assert := assert.New(t)
+	mNotifier := mocks.NewChangeNotifier(t)
+	got := []goraff.GraphChangeNotification{}
+	mNotifier.EXPECT().Notify(mock.Anything).Run(func(n goraff.GraphChangeNotification) {
+		if n.Op == goraff.ChangeOpNode {
+			return
+		}
+		got = append(got, n)
+	})
+
+	s := &goraff.Graph{Notifier: mNotifier}
+	n := s.NewNode("node1", nil)
+	sub := &goraff.Graph{}
+	n.AddSubGraph(sub)
+	sn := sub.NewNode("subnode", nil)
+	sn.AddStr("key", "value1")
+	sn.AddStr("key", "value2")
+	sn.MarkFailed(fmt.Errorf("boom"))
+	n.MarkDone()
+
+	graphID := goraff.NewReadableGraph(s).ID()
+	subID := goraff.NewReadableGraph(sub).ID()
+	assert.Len(got, 5)
+
+	assert.Equal(goraff.ChangeOpSubGraph, got[0].Op)
+	assert.Equal(graphID, got[0].GraphID)
+	assert.Equal([]byte(subID), got[0].Value)
+
+	assert.Equal(goraff.ChangeOpAdd, got[1].Op)
+	assert.Equal(subID, got[1].GraphID)
+	assert.Equal([]string{graphID}, got[1].ParentGraphIDs)
+	assert.Equal("subnode", got[1].NodeName)
+	assert.Equal(0, got[1].Index)
+	assert.Equal(1, got[2].Index)
+
+	assert.Equal(goraff.ChangeOpStatus, got[3].Op)
+	assert.Equal([]byte(goraff.NodeStatusFailed), got[3].Value)
+
+	assert.Equal(goraff.ChangeOpStatus, got[4].Op)
+	assert.Equal(n.Get().ID(), got[4].NodeID)
+	assert.Equal([]byte(goraff.NodeStatusDone), got[4].Value)
 }
 
 func TestStateReader_ID(t *testing.T) {
@@ -0,0 +1,65 @@
+package goraff
+
+import "time"
+
+// Hooks observe a run as it happens. Every field is optional.
+// Sub-graphs share the hooks of the graph their parent node belongs to.
+type Hooks struct {
+	RunStarted   func(g *ReadableGraph)
+	RunFinished  func(g *ReadableGraph, err error)
+	BlockStarted func(g *ReadableGraph, n *ReadableNode)
+	// BlockFinished is passed the block's error, which is an ErrBlockSuspended when the block suspends
+	BlockFinished func(g *ReadableGraph, n *ReadableNode, err error)
+	// JoinEvaluated is fired each time the scheduler checks whether a join should be followed
+	JoinEvaluated func(g *ReadableGraph, j *Join, met bool, err error)
+	// Call is fired when a block reports a call to an external service
+	Call func(n *ReadableNode, c Call)
+	// Retry is fired when a block retries work after a failed attempt
+	Retry func(n *ReadableNode, attempt int, err error)
+}
+
+// Call describes a call a block made to an external service, such as an LLM
+type Call struct {
+	Kind         string
+	Provider     string
+	Model        string
+	Start        time.Time
+	End          time.Time
+	InputTokens  int
+	OutputTokens int
+	Err          error
+	Attributes   map[string]string
+}
+
+func (s *Graph) eachHook(fn func(h *Hooks)) {
+	for _, h := range s.Hooks {
+		if h != nil {
+			fn(h)
+		}
+	}
+}
+
+// RecordCall reports an external call made while running the node's block to the graph's hooks
+func (n *Node) RecordCall(c Call) {
+	if n.graph == nil {
+		return
+	}
+	n.graph.eachHook(func(h *Hooks) {
+		if h.Call != nil {
+			h.Call(n.Get(), c)
+		}
+	})
+}
+
+// RecordRetry reports to the graph's hooks that the node's block is retrying after err.
+// attempt is the number of the attempt about to be made, starting at 2.
+func (n *Node) RecordRetry(attempt int, err error) {
+	if n.graph == nil {
+		return
+	}
+	n.graph.eachHook(func(h *Hooks) {
+		if h.Retry != nil {
+			h.Retry(n.Get(), attempt, err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package goraff_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/stretchr/testify/assert"
+)
+
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *hookRecorder) add(format string, args ...any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, fmt.Sprintf(format, args...))
+}
+
+func (h *hookRecorder) hooks() *goraff.Hooks {
+	return &goraff.Hooks{
+		RunStarted:  func(g *goraff.ReadableGraph) { h.add("run started") },
+		RunFinished: func(g *goraff.ReadableGraph, err error) { h.add("run finished %v", err) },
+		BlockStarted: func(g *goraff.ReadableGraph, n *goraff.ReadableNode) {
+			h.add("block started %s", n.Name())
+		},
+		BlockFinished: func(g *goraff.ReadableGraph, n *goraff.ReadableNode, err error) {
+			h.add("block finished %s %v", n.Name(), err)
+		},
+		Call: func(n *goraff.ReadableNode, c goraff.Call) { h.add("call %s %s", n.Name(), c.Kind) },
+	}
+}
+
+type callingAction struct{}
+
+func (a *callingAction) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	n.RecordCall(goraff.Call{Kind: "test", Start: time.Now(), End: time.Now()})
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	a := s.Blocks().Add("a", &callingAction{})
+	b := s.Blocks().Add("b", &actionMock{name: "b", err: fmt.Errorf("boom")})
+	s.SetEntrypoint(a)
+	s.Joins().Add(a, b, nil)
+
+	rec := &hookRecorder{}
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{rec.hooks(), {}}}
+	assert.Error(s.Go(g))
+
+	assert.Equal([]string{
+		"run started",
+		"block started a",
+		"call a test",
+		"block finished a <nil>",
+		"block started b",
+		"block finished b boom",
+		"run finished error running block: boom",
+	}, rec.events)
+}
+
+func TestHooks_Suspend(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	w := s.Blocks().Add("wait", &suspendAction{event: "approval"})
+	s.SetEntrypoint(w)
+
+	rec := &hookRecorder{}
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{rec.hooks()}}
+	assert.Error(s.Go(g))
+
+	assert.Equal([]string{
+		"run started",
+		"block started wait",
+		"block finished wait suspended until approval",
+		"run finished run suspended until approval",
+	}, rec.events)
+}
+
+func TestHooks_InheritedBySubGraphs(t *testing.T) {
+	assert := assert.New(t)
+	rec := &hookRecorder{}
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{rec.hooks()}}
+	n := g.NewNode("parent", nil)
+	sub := &goraff.Graph{}
+	n.AddSubGraph(sub)
+	sn := sub.NewNode("child", nil)
+	sn.RecordCall(goraff.Call{Kind: "test"})
+	assert.Equal([]string{"call child test"}, rec.events)
+
+	r := goraff.NewReadableGraph(sub)
+	assert.Equal(n.Get().ID(), r.ParentNode().ID())
+	assert.Nil(goraff.NewReadableGraph(g).ParentNode())
+}
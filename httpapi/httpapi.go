@@ -0,0 +1,358 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/redact"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/lordtatty/goraff/sse"
+)
+
+// Server exposes a RunManager over HTTP:
+//
+//	GET  /scaffs                      registered scaff names
+//	POST /runs                        start a run: {"scaff": "name", "inputs": {"key": "value"}}
+//	GET  /runs                        every run
+//	GET  /runs/{id}                   a run with its Output
+//	POST /runs/{id}/cancel            cancel a run
+//	POST /runs/{id}/resume            resume a suspended run: {"event": "name", "data": "..."}
+//	GET  /runs/{id}/nodes             every node, including those in sub-graphs
+//	GET  /runs/{id}/nodes/{node}      a node's values
+//	GET  /runs/{id}/events            Server-Sent Events, as sse.Handler
+//	GET  /runs/{id}/questions         questions the run is waiting on
+//	GET  /questions                   every question waiting for an answer
+//	POST /questions/{id}/answer       answer a question: {"answer": "yes"}
+//
+// Errors are returned as {"error": "message"}.
+type Server struct {
+	Runs *runs.RunManager
+	// Redactor, when set, hides sensitive values in outputs and node values.
+	// Use RunManager.Differ to hide them in events as well.
+	Redactor *redact.Redactor
+	// OnError, when set, is called when a response cannot be written, such as to a client
+	// that has gone away. Such errors are otherwise ignored.
+	OnError func(err error)
+}
+
+func New(m *runs.RunManager) *Server {
+	return &Server{Runs: m}
+}
+
+type ScaffInfo struct {
+	Name string `json:"name"`
+}
+
+type StartRequest struct {
+	Scaff  string            `json:"scaff"`
+	Inputs map[string]string `json:"inputs"`
+}
+
+type RunInfo struct {
+	ID         string            `json:"id"`
+	Scaff      string            `json:"scaff"`
+	Status     runs.Status       `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Inputs     map[string]string `json:"inputs"`
+	WaitingFor []string          `json:"waiting_for,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+type RunDetail struct {
+	RunInfo
+	Output *outputs.Output `json:"output"`
+}
+
+type NodeInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	GraphID string `json:"graph_id"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type QuestionInfo struct {
+	ID       string     `json:"id"`
+	GraphID  string     `json:"graph_id"`
+	NodeName string     `json:"node_name"`
+	Prompt   string     `json:"prompt"`
+	Schema   string     `json:"schema,omitempty"`
+	Options  []string   `json:"options,omitempty"`
+	AskedAt  time.Time  `json:"asked_at"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+type ResumeRequest struct {
+	Event string `json:"event"`
+	Data  string `json:"data"`
+}
+
+type AnswerRequest struct {
+	Answer string `json:"answer"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the API's routes. Mount it under a prefix with http.StripPrefix.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /scaffs", s.listScaffs)
+	mux.HandleFunc("POST /runs", s.startRun)
+	mux.HandleFunc("GET /runs", s.listRuns)
+	mux.HandleFunc("GET /runs/{id}", s.getRun)
+	mux.HandleFunc("POST /runs/{id}/cancel", s.cancelRun)
+	mux.HandleFunc("POST /runs/{id}/resume", s.resumeRun)
+	mux.HandleFunc("GET /runs/{id}/nodes", s.listNodes)
+	mux.HandleFunc("GET /runs/{id}/nodes/{node}", s.getNode)
+	mux.HandleFunc("GET /runs/{id}/events", s.events)
+	mux.HandleFunc("GET /runs/{id}/questions", s.runQuestions)
+	mux.HandleFunc("GET /questions", s.listQuestions)
+	mux.HandleFunc("POST /questions/{id}/answer", s.answer)
+	return mux
+}
+
+func (s *Server) listScaffs(w http.ResponseWriter, r *http.Request) {
+	result := []ScaffInfo{}
+	if s.Runs.Scaffs != nil {
+		for _, name := range s.Runs.Scaffs.Names() {
+			result = append(result, ScaffInfo{Name: name})
+		}
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) startRun(w http.ResponseWriter, r *http.Request) {
+	var req StartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Scaff == "" {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("scaff is required"))
+		return
+	}
+	run, err := s.Runs.Start(req.Scaff, req.Inputs)
+	if err != nil {
+		s.writeError(w, statusFor(err), err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, s.info(run))
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	result := []RunInfo{}
+	for _, run := range s.Runs.List() {
+		result = append(result, s.info(run))
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.run(w, r)
+	if !ok {
+		return
+	}
+	o := &outputs.Outputter{Redactor: s.Redactor}
+	s.writeJSON(w, http.StatusOK, RunDetail{RunInfo: s.info(run), Output: o.Output(run.Graph())})
+}
+
+func (s *Server) cancelRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.run(w, r)
+	if !ok {
+		return
+	}
+	if err := s.Runs.Cancel(run.ID()); err != nil {
+		s.writeError(w, statusFor(err), err)
+		return
+	}
+	s.writeJSON(w, http.StatusAccepted, s.info(run))
+}
+
+func (s *Server) resumeRun(w http.ResponseWriter, r *http.Request) {
+	var req ResumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Event == "" {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("event is required"))
+		return
+	}
+	// the run may only be in the store until it is resumed, so it is looked up after
+	if err := s.Runs.Resume(r.PathValue("id"), goraff.Event{Name: req.Event, Data: []byte(req.Data)}); err != nil {
+		s.writeError(w, statusFor(err), err)
+		return
+	}
+	run, ok := s.run(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, http.StatusAccepted, s.info(run))
+}
+
+func (s *Server) listNodes(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.run(w, r)
+	if !ok {
+		return
+	}
+	result := []NodeInfo{}
+	run.Graph().Walk(func(g *goraff.ReadableGraph, n *goraff.ReadableNode) {
+		info := NodeInfo{ID: n.ID(), Name: n.Name(), GraphID: g.ID(), Status: string(n.Status())}
+		if err := n.Err(); err != nil {
+			info.Error = s.Redactor.String(err.Error())
+		}
+		result = append(result, info)
+	})
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getNode(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.run(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("node")
+	found := run.Graph().FindAll(func(n *goraff.ReadableNode) bool {
+		return n.ID() == id
+	})
+	if len(found) == 0 {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("node not found: %s", id))
+		return
+	}
+	o := &outputs.Outputter{Redactor: s.Redactor}
+	s.writeJSON(w, http.StatusOK, o.Node(found[0]))
+}
+
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.run(w, r)
+	if !ok {
+		return
+	}
+	h := &sse.Handler{Stream: run.Stream()}
+	h.ServeHTTP(w, r)
+}
+
+func (s *Server) runQuestions(w http.ResponseWriter, r *http.Request) {
+	qs, err := s.Runs.Questions(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, statusFor(err), err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.questions(qs))
+}
+
+func (s *Server) listQuestions(w http.ResponseWriter, r *http.Request) {
+	qs := []blockactions.Question{}
+	if s.Runs.Inbox != nil {
+		qs = s.Runs.Inbox.Pending()
+	}
+	s.writeJSON(w, http.StatusOK, s.questions(qs))
+}
+
+func (s *Server) answer(w http.ResponseWriter, r *http.Request) {
+	var req AnswerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if err := s.Runs.Answer(r.PathValue("id"), req.Answer); err != nil {
+		s.writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) questions(qs []blockactions.Question) []QuestionInfo {
+	result := []QuestionInfo{}
+	for _, q := range qs {
+		info := QuestionInfo{
+			ID:       q.ID,
+			GraphID:  q.GraphID,
+			NodeName: q.NodeName,
+			Prompt:   s.Redactor.Value(q.NodeName, blockactions.HumanKeyPrompt, q.Prompt),
+			Schema:   q.Schema,
+			Options:  q.Options,
+			AskedAt:  q.AskedAt,
+		}
+		if !q.Deadline.IsZero() {
+			d := q.Deadline
+			info.Deadline = &d
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+func (s *Server) run(w http.ResponseWriter, r *http.Request) (*runs.Run, bool) {
+	run, err := s.Runs.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, statusFor(err), err)
+		return nil, false
+	}
+	return run, true
+}
+
+func (s *Server) info(run *runs.Run) RunInfo {
+	info := RunInfo{
+		ID:        run.ID(),
+		Scaff:     run.Scaff(),
+		Status:    run.Status(),
+		Inputs:    run.Inputs(),
+		CreatedAt: run.CreatedAt(),
+	}
+	for k, v := range info.Inputs {
+		info.Inputs[k] = s.Redactor.Value(runs.InputsNode, k, v)
+	}
+	if info.Status == runs.StatusSuspended {
+		info.WaitingFor = run.WaitingFor()
+	} else if err := run.Err(); err != nil {
+		info.Error = s.Redactor.String(err.Error())
+	}
+	if st := run.StartedAt(); !st.IsZero() {
+		info.StartedAt = &st
+	}
+	if f := run.FinishedAt(); !f.IsZero() {
+		info.FinishedAt = &f
+	}
+	return info
+}
+
+func statusFor(err error) int {
+	var runErr runs.ErrRunNotFound
+	var scaffErr runs.ErrScaffNotFound
+	var questionErr blockactions.ErrQuestionNotFound
+	var answerErr blockactions.ErrInvalidAnswer
+	var suspendedErr runs.ErrRunNotSuspended
+	var eventErr goraff.ErrEventNotAwaited
+	switch {
+	case errors.As(err, &suspendedErr), errors.As(err, &eventErr):
+		return http.StatusConflict
+	case errors.As(err, &runErr), errors.As(err, &scaffErr), errors.As(err, &questionErr):
+		return http.StatusNotFound
+	case errors.As(err, &answerErr):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil && s.OnError != nil {
+		s.OnError(fmt.Errorf("error writing response: %w", err))
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
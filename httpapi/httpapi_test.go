@@ -0,0 +1,332 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/httpapi"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/redact"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echo struct{}
+
+func (e *echo) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	in, err := r.FirstNodeByName(runs.InputsNode)
+	if err != nil {
+		return err
+	}
+	n.SetStr("result", "hello "+in.FirstStr("name"))
+	return nil
+}
+
+type wait struct{}
+
+func (w *wait) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	<-r.Context().Done()
+	return nil
+}
+
+func single(name string, a goraff.BlockAction) *goraff.Scaff {
+	s := goraff.NewScaff()
+	s.SetEntrypoint(s.Blocks().Add(name, a))
+	return s
+}
+
+func setup(t *testing.T) (*httptest.Server, *runs.RunManager) {
+	reg := &runs.Registry{}
+	echoScaff := goraff.NewScaff()
+	echoScaff.SetEntrypoint(echoScaff.Blocks().Add("greet", &echo{}))
+	require.NoError(t, reg.Register("echo", echoScaff))
+	waitScaff := goraff.NewScaff()
+	waitScaff.SetEntrypoint(waitScaff.Blocks().Add("wait", &wait{}))
+	require.NoError(t, reg.Register("wait", waitScaff))
+	m := runs.NewRunManager(reg)
+	srv := httptest.NewServer(httpapi.New(m).Handler())
+	t.Cleanup(srv.Close)
+	return srv, m
+}
+
+func do(t *testing.T, method, url, body string, want int, out any) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, want, resp.StatusCode, string(b))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	if out != nil {
+		require.NoError(t, json.Unmarshal(b, out))
+	}
+}
+
+func finished(t *testing.T, m *runs.RunManager, id string) {
+	t.Helper()
+	r, err := m.Get(id)
+	require.NoError(t, err)
+	select {
+	case <-r.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("run did not finish")
+	}
+}
+
+func TestServer_Runs(t *testing.T) {
+	assert := assert.New(t)
+	srv, m := setup(t)
+
+	var scaffs []httpapi.ScaffInfo
+	do(t, "GET", srv.URL+"/scaffs", "", 200, &scaffs)
+	assert.Equal([]httpapi.ScaffInfo{{Name: "echo"}, {Name: "wait"}}, scaffs)
+
+	var started httpapi.RunInfo
+	do(t, "POST", srv.URL+"/runs", `{"scaff":"echo","inputs":{"name":"jo"}}`, 201, &started)
+	assert.NotEmpty(started.ID)
+	assert.Equal("echo", started.Scaff)
+	assert.Equal(map[string]string{"name": "jo"}, started.Inputs)
+	finished(t, m, started.ID)
+
+	var detail httpapi.RunDetail
+	do(t, "GET", srv.URL+"/runs/"+started.ID, "", 200, &detail)
+	assert.Equal(runs.StatusSucceeded, detail.Status)
+	assert.NotNil(detail.FinishedAt)
+	assert.Equal(started.ID, detail.Output.PrimaryStateID)
+	assert.Len(detail.Output.Nodes, 2)
+
+	var list []httpapi.RunInfo
+	do(t, "GET", srv.URL+"/runs", "", 200, &list)
+	assert.Len(list, 1)
+	assert.Equal(started.ID, list[0].ID)
+
+	var nodes []httpapi.NodeInfo
+	do(t, "GET", srv.URL+"/runs/"+started.ID+"/nodes", "", 200, &nodes)
+	assert.Len(nodes, 2)
+	assert.Equal("greet", nodes[1].Name)
+	assert.Equal(started.ID, nodes[1].GraphID)
+	assert.Equal("done", nodes[1].Status)
+
+	var node outputs.NodeOutput
+	do(t, "GET", srv.URL+"/runs/"+started.ID+"/nodes/"+nodes[1].ID, "", 200, &node)
+	assert.Equal([]outputs.NodeOutputVal{{Name: "result", Values: []string{"hello jo"}}}, node.Vals)
+}
+
+func TestServer_Cancel(t *testing.T) {
+	assert := assert.New(t)
+	srv, m := setup(t)
+
+	var started httpapi.RunInfo
+	do(t, "POST", srv.URL+"/runs", `{"scaff":"wait"}`, 201, &started)
+	assert.Equal(runs.StatusRunning, started.Status)
+	do(t, "POST", srv.URL+"/runs/"+started.ID+"/cancel", "", 202, nil)
+	finished(t, m, started.ID)
+
+	var detail httpapi.RunDetail
+	do(t, "GET", srv.URL+"/runs/"+started.ID, "", 200, &detail)
+	assert.Equal(runs.StatusCancelled, detail.Status)
+	assert.Equal("run stopped: context canceled", detail.Error)
+}
+
+func TestServer_Errors(t *testing.T) {
+	tests := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"POST", "/runs", `{"scaff":"missing"}`, 404, "scaff not found: missing"},
+		{"POST", "/runs", `{}`, 400, "scaff is required"},
+		{"POST", "/runs", `nope`, 400, "invalid request body: invalid character 'o' in literal null (expecting 'u')"},
+		{"GET", "/runs/nope", "", 404, "run not found: nope"},
+		{"POST", "/runs/nope/cancel", "", 404, "run not found: nope"},
+		{"POST", "/runs/nope/resume", `{"event":"approval"}`, 404, "run not found: nope"},
+		{"POST", "/runs/nope/resume", `{}`, 400, "event is required"},
+		{"GET", "/runs/nope/nodes", "", 404, "run not found: nope"},
+		{"GET", "/runs/nope/events", "", 404, "run not found: nope"},
+		{"GET", "/runs/nope/questions", "", 404, "run not found: nope"},
+		{"POST", "/questions/nope/answer", `{"answer":"yes"}`, 404, "question not found: nope"},
+		{"POST", "/questions/nope/answer", `nope`, 400, "invalid request body: invalid character 'o' in literal null (expecting 'u')"},
+	}
+	srv, _ := setup(t)
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.method, tt.path), func(t *testing.T) {
+			var got map[string]string
+			do(t, tt.method, srv.URL+tt.path, tt.body, tt.status, &got)
+			assert.Equal(t, map[string]string{"error": tt.want}, got)
+		})
+	}
+}
+
+func TestServer_NodeNotFound(t *testing.T) {
+	srv, m := setup(t)
+	var started httpapi.RunInfo
+	do(t, "POST", srv.URL+"/runs", `{"scaff":"echo"}`, 201, &started)
+	finished(t, m, started.ID)
+	var got map[string]string
+	do(t, "GET", srv.URL+"/runs/"+started.ID+"/nodes/nope", "", 404, &got)
+	assert.Equal(t, "node not found: nope", got["error"])
+}
+
+func TestServer_Events(t *testing.T) {
+	assert := assert.New(t)
+	srv, m := setup(t)
+	var started httpapi.RunInfo
+	do(t, "POST", srv.URL+"/runs", `{"scaff":"echo","inputs":{"name":"jo"}}`, 201, &started)
+	finished(t, m, started.ID)
+
+	resp, err := http.Get(srv.URL + "/runs/" + started.ID + "/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			var m outputs.Message
+			assert.NoError(json.Unmarshal([]byte(strings.TrimPrefix(scanner.Text(), "data: ")), &m))
+			assert.Equal(outputs.MessageTypeSnapshot, m.Type)
+			assert.Len(m.Snapshot.Nodes, 2)
+			return
+		}
+	}
+	t.Fatal("no event received")
+}
+
+func TestServer_Redactor(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	s := goraff.NewScaff()
+	s.SetEntrypoint(s.Blocks().Add("greet", &echo{}))
+	require.NoError(t, reg.Register("echo", s))
+	m := runs.NewRunManager(reg)
+	api := httpapi.New(m)
+	api.Redactor = redact.New("name")
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	var started httpapi.RunInfo
+	do(t, "POST", srv.URL+"/runs", `{"scaff":"echo","inputs":{"name":"jo@example.com"}}`, 201, &started)
+	assert.Equal(map[string]string{"name": "[REDACTED]"}, started.Inputs)
+	finished(t, m, started.ID)
+	var detail httpapi.RunDetail
+	do(t, "GET", srv.URL+"/runs/"+started.ID, "", 200, &detail)
+	b, _ := json.Marshal(detail)
+	assert.NotContains(string(b), "jo@example.com")
+}
+
+func TestServer_Questions(t *testing.T) {
+	assert := assert.New(t)
+	inbox := &blockactions.Inbox{}
+	reg := &runs.Registry{}
+	ask := goraff.NewScaff()
+	ask.SetEntrypoint(ask.Blocks().Add("approve", &blockactions.HumanInput{
+		Inbox:   inbox,
+		Prompt:  "Ship it?",
+		Options: []string{"yes", "no"},
+		Timeout: time.Minute,
+	}))
+	require.NoError(t, reg.Register("ask", ask))
+	m := runs.NewRunManager(reg)
+	m.Inbox = inbox
+	srv := httptest.NewServer(httpapi.New(m).Handler())
+	t.Cleanup(srv.Close)
+
+	var all []httpapi.QuestionInfo
+	do(t, "GET", srv.URL+"/questions", "", 200, &all)
+	assert.Empty(all)
+
+	var started httpapi.RunInfo
+	do(t, "POST", srv.URL+"/runs", `{"scaff":"ask"}`, 201, &started)
+	var qs []httpapi.QuestionInfo
+	assert.Eventually(func() bool {
+		do(t, "GET", srv.URL+"/runs/"+started.ID+"/questions", "", 200, &qs)
+		return len(qs) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	q := qs[0]
+	assert.Equal("approve", q.NodeName)
+	assert.Equal(started.ID, q.GraphID)
+	assert.Equal("Ship it?", q.Prompt)
+	assert.Equal([]string{"yes", "no"}, q.Options)
+	assert.NotNil(q.Deadline)
+	do(t, "GET", srv.URL+"/questions", "", 200, &all)
+	assert.Equal(qs, all)
+
+	var got map[string]string
+	do(t, "POST", srv.URL+"/questions/"+q.ID+"/answer", `{"answer":"maybe"}`, 400, &got)
+	assert.Equal(`answer "maybe" is not one of [yes no]`, got["error"])
+
+	resp, err := http.Post(srv.URL+"/questions/"+q.ID+"/answer", "application/json", strings.NewReader(`{"answer":"yes"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+	finished(t, m, started.ID)
+	var detail httpapi.RunDetail
+	do(t, "GET", srv.URL+"/runs/"+started.ID, "", 200, &detail)
+	assert.Equal(runs.StatusSucceeded, detail.Status)
+}
+
+func TestServer_Resume(t *testing.T) {
+	assert := assert.New(t)
+	srv, m := setup(t)
+	require.NoError(t, m.Scaffs.Register("approval", single("approve", &blockactions.Wait{Event: "approval"})))
+	var started httpapi.RunInfo
+	do(t, "POST", srv.URL+"/runs", `{"scaff":"approval"}`, 201, &started)
+	_, err := m.Wait(context.Background(), started.ID)
+	require.NoError(t, err)
+
+	var info httpapi.RunInfo
+	do(t, "GET", srv.URL+"/runs/"+started.ID, "", 200, &info)
+	assert.Equal(runs.StatusSuspended, info.Status)
+	assert.Equal([]string{"approval"}, info.WaitingFor)
+	assert.Empty(info.Error)
+
+	var got map[string]string
+	do(t, "POST", srv.URL+"/runs/"+started.ID+"/resume", `{"event":"payment"}`, 409, &got)
+	assert.Equal("no block is waiting for event payment", got["error"])
+
+	do(t, "POST", srv.URL+"/runs/"+started.ID+"/resume", `{"event":"approval","data":"yes"}`, 202, &info)
+	finished(t, m, started.ID)
+	var detail httpapi.RunDetail
+	do(t, "GET", srv.URL+"/runs/"+started.ID, "", 200, &detail)
+	assert.Equal(runs.StatusSucceeded, detail.Status)
+	assert.Empty(detail.WaitingFor)
+
+	do(t, "POST", srv.URL+"/runs/"+started.ID+"/resume", `{"event":"approval"}`, 409, &got)
+	assert.Equal("run is not suspended: "+started.ID, got["error"])
+}
+
+// brokenWriter is a client that has gone away
+type brokenWriter struct {
+	httptest.ResponseRecorder
+}
+
+func (b *brokenWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("connection reset")
+}
+
+func TestServer_OnError(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("echo", single("greet", &echo{})))
+	sut := httpapi.New(runs.NewRunManager(reg))
+	errs := []error{}
+	sut.OnError = func(err error) { errs = append(errs, err) }
+
+	w := &brokenWriter{ResponseRecorder: *httptest.NewRecorder()}
+	sut.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/scaffs", nil))
+	require.Len(t, errs, 1)
+	assert.EqualError(errs[0], "error writing response: connection reset")
+}
@@ -1,6 +1,9 @@
 package goraff
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Condition is a condition that must be met for a join to be taken
 type FollowIf interface {
@@ -68,6 +71,18 @@ func (j *Joins) Get(from string) []*Join {
 	return j.joins[from]
 }
 
+// All returns every join, grouped by the order their from blocks were added
+func (j *Joins) All() []*Join {
+	result := []*Join{}
+	if j.Blocks == nil {
+		return result
+	}
+	for _, b := range j.Blocks.All() {
+		result = append(result, j.Get(b.Name)...)
+	}
+	return result
+}
+
 // Join connects two blocks in a scaff
 type Join struct {
 	From      *Block
@@ -97,6 +112,10 @@ func (e *followIfKeyMatchesName) Match(s *ReadableGraph) (bool, error) {
 	return n.FirstStr(e.Key) == e.Value, nil
 }
 
+func (e *followIfKeyMatchesName) String() string {
+	return fmt.Sprintf("%s.%s == %q", e.Name, e.Key, e.Value)
+}
+
 func FollowIfKeyMatches(nodeID, key, value string) FollowIf {
 	return &followIfKeyMatchesName{Name: nodeID, Key: key, Value: value}
 }
@@ -121,6 +140,10 @@ func (e *followIfNodesCompleted) Match(s *ReadableGraph) (bool, error) {
 	return true, nil
 }
 
+func (e *followIfNodesCompleted) String() string {
+	return fmt.Sprintf("completed(%s)", strings.Join(e.NodeIDs, ", "))
+}
+
 func FollowIfNodesCompleted(nodeIDs ...string) FollowIf {
 	return &followIfNodesCompleted{NodeIDs: nodeIDs}
 }
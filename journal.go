@@ -0,0 +1,145 @@
+package goraff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// changeSeq orders every write across all graphs in the process
+var changeSeq atomic.Uint64
+
+func nextSeq() uint64 {
+	return changeSeq.Add(1)
+}
+
+// LastSeq returns the sequence number of the most recent change.
+// Every change with a sequence number at or below it has already been applied.
+func LastSeq() uint64 {
+	return changeSeq.Load()
+}
+
+// JournalOp is the kind of write recorded in a node journal
+type JournalOp string
+
+const (
+	JournalOpAdd JournalOp = "add"
+	JournalOpSet JournalOp = "set"
+)
+
+// JournalConfig turns on write journals for nodes and controls their retention
+type JournalConfig struct {
+	// MaxEntries is the number of entries kept per node, oldest first out. Zero keeps everything.
+	MaxEntries int
+	// MaxAge drops entries older than this whenever a new write is recorded. Zero keeps everything.
+	MaxAge time.Duration
+	// HashValues keeps only the hash of each written value, not the value itself
+	HashValues bool
+	// Redact, when set, is applied to values before they are hashed and kept.
+	// redact.Redactor's Bytes method fits.
+	Redact func(nodeName, key string, value []byte) []byte
+}
+
+// JournalEntry records a single write to a node
+type JournalEntry struct {
+	Seq   uint64
+	Op    JournalOp
+	Key   string
+	Value []byte
+	Hash  string
+	Time  time.Time
+}
+
+type journal struct {
+	config  JournalConfig
+	entries []JournalEntry
+}
+
+func (j *journal) record(seq uint64, op JournalOp, nodeName, key string, value []byte) {
+	if j.config.Redact != nil {
+		value = j.config.Redact(nodeName, key, value)
+	}
+	sum := sha256.Sum256(value)
+	e := JournalEntry{
+		Seq:  seq,
+		Op:   op,
+		Key:  key,
+		Hash: hex.EncodeToString(sum[:]),
+		Time: time.Now(),
+	}
+	if !j.config.HashValues {
+		e.Value = append([]byte{}, value...)
+	}
+	j.entries = append(j.entries, e)
+	j.prune(e.Time)
+}
+
+func (j *journal) prune(now time.Time) {
+	drop := 0
+	if j.config.MaxEntries > 0 && len(j.entries) > j.config.MaxEntries {
+		drop = len(j.entries) - j.config.MaxEntries
+	}
+	if j.config.MaxAge > 0 {
+		for drop < len(j.entries) && now.Sub(j.entries[drop].Time) > j.config.MaxAge {
+			drop++
+		}
+	}
+	if drop > 0 {
+		j.entries = append([]JournalEntry{}, j.entries[drop:]...)
+	}
+}
+
+// EnableJournal starts recording writes to this node.
+// Nodes created by a Graph with a Journal config have this enabled already.
+func (n *Node) EnableJournal(config JournalConfig) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	n.journal = &journal{config: config}
+}
+
+// Journal returns every retained write to the node in the order they happened.
+// It is empty unless journalling is enabled.
+func (s *ReadableNode) Journal() []JournalEntry {
+	s.node.mut.Lock()
+	defer s.node.mut.Unlock()
+	if s.node.journal == nil {
+		return []JournalEntry{}
+	}
+	result := make([]JournalEntry, len(s.node.journal.entries))
+	copy(result, s.node.journal.entries)
+	return result
+}
+
+// History returns every retained write to the given key in the order they happened
+func (s *ReadableNode) History(key string) []JournalEntry {
+	result := []JournalEntry{}
+	for _, e := range s.Journal() {
+		if e.Key == key {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Replay rebuilds node state by applying journal entries in order.
+// Pass a prefix of a journal to see the state as it was at that point.
+// Entries recorded with HashValues cannot be replayed.
+func Replay(entries []JournalEntry) (map[string][][]byte, error) {
+	state := map[string][][]byte{}
+	for _, e := range entries {
+		if e.Value == nil && e.Hash != "" {
+			return nil, fmt.Errorf("entry %d for key %s only holds a hash", e.Seq, e.Key)
+		}
+		switch e.Op {
+		case JournalOpAdd:
+			state[e.Key] = append(state[e.Key], e.Value)
+		case JournalOpSet:
+			state[e.Key] = [][]byte{e.Value}
+		default:
+			return nil, fmt.Errorf("entry %d has unknown op %s", e.Seq, e.Op)
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,141 @@
+package goraff_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal_DisabledByDefault(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	n := g.NewNode("node1", nil)
+	n.SetStr("key", "value")
+	assert.Len(n.Get().Journal(), 0)
+}
+
+func TestJournal_RecordsWrites(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{Journal: &goraff.JournalConfig{}}
+	n := g.NewNode("node1", nil)
+	n.SetStr("result", "a")
+	n.SetStr("result", "ab")
+	n.AddStr("tags", "x")
+
+	entries := n.Get().Journal()
+	assert.Len(entries, 3)
+	assert.Equal(goraff.JournalOpSet, entries[0].Op)
+	assert.Equal("result", entries[0].Key)
+	assert.Equal([]byte("a"), entries[0].Value)
+	assert.Equal(goraff.JournalOpAdd, entries[2].Op)
+	assert.Less(entries[0].Seq, entries[1].Seq)
+	assert.Less(entries[1].Seq, entries[2].Seq)
+	assert.False(entries[0].Time.IsZero())
+
+	sum := sha256.Sum256([]byte("ab"))
+	assert.Equal(hex.EncodeToString(sum[:]), entries[1].Hash)
+
+	history := n.Get().History("result")
+	assert.Len(history, 2)
+	assert.Equal([]byte("ab"), history[1].Value)
+}
+
+func TestJournal_HashValues(t *testing.T) {
+	assert := assert.New(t)
+	n := &goraff.Node{}
+	n.EnableJournal(goraff.JournalConfig{HashValues: true})
+	n.SetStr("secret", "value")
+
+	entries := n.Get().Journal()
+	assert.Len(entries, 1)
+	assert.Nil(entries[0].Value)
+	assert.NotEmpty(entries[0].Hash)
+
+	_, err := goraff.Replay(entries)
+	assert.Error(err)
+}
+
+func TestJournal_Redact(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{Journal: &goraff.JournalConfig{
+		Redact: func(nodeName, key string, value []byte) []byte {
+			if key == "api_key" {
+				return []byte("hidden " + nodeName)
+			}
+			return value
+		},
+	}}
+	n := g.NewNode("input", nil)
+	n.SetStr("api_key", "sk-123")
+	n.SetStr("result", "ok")
+
+	entries := n.Get().Journal()
+	assert.Len(entries, 2)
+	assert.Equal([]byte("hidden input"), entries[0].Value)
+	sum := sha256.Sum256([]byte("hidden input"))
+	assert.Equal(hex.EncodeToString(sum[:]), entries[0].Hash)
+	assert.Equal([]byte("ok"), entries[1].Value)
+	assert.Equal("sk-123", n.Get().FirstStr("api_key"))
+}
+
+func TestJournal_MaxEntries(t *testing.T) {
+	assert := assert.New(t)
+	n := &goraff.Node{}
+	n.EnableJournal(goraff.JournalConfig{MaxEntries: 2})
+	n.SetStr("key", "1")
+	n.SetStr("key", "2")
+	n.SetStr("key", "3")
+
+	entries := n.Get().Journal()
+	assert.Len(entries, 2)
+	assert.Equal([]byte("2"), entries[0].Value)
+	assert.Equal([]byte("3"), entries[1].Value)
+}
+
+func TestJournal_MaxAge(t *testing.T) {
+	assert := assert.New(t)
+	n := &goraff.Node{}
+	n.EnableJournal(goraff.JournalConfig{MaxAge: 20 * time.Millisecond})
+	n.SetStr("key", "old")
+	time.Sleep(40 * time.Millisecond)
+	n.SetStr("key", "new")
+
+	entries := n.Get().Journal()
+	assert.Len(entries, 1)
+	assert.Equal([]byte("new"), entries[0].Value)
+}
+
+func TestJournal_SubGraphsInheritConfig(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{Journal: &goraff.JournalConfig{}}
+	n := g.NewNode("node1", nil)
+	sub := &goraff.Graph{}
+	n.AddSubGraph(sub)
+	sn := sub.NewNode("subnode", nil)
+	sn.SetStr("key", "value")
+	assert.Len(sn.Get().Journal(), 1)
+}
+
+func TestReplay(t *testing.T) {
+	assert := assert.New(t)
+	n := &goraff.Node{}
+	n.EnableJournal(goraff.JournalConfig{})
+	n.SetStr("result", "a")
+	n.SetStr("result", "ab")
+	n.AddStr("list", "1")
+	n.AddStr("list", "2")
+
+	entries := n.Get().Journal()
+	state, err := goraff.Replay(entries[:1])
+	assert.NoError(err)
+	assert.Equal(map[string][][]byte{"result": {[]byte("a")}}, state)
+
+	state, err = goraff.Replay(entries)
+	assert.NoError(err)
+	assert.Equal(n.Get().All("result"), state["result"])
+	assert.Equal(n.Get().All("list"), state["list"])
+}
@@ -0,0 +1,12 @@
+// Package keys names the node keys that block actions write and other packages read,
+// so readers such as outputs do not depend on the actions themselves
+package keys
+
+const (
+	// Result holds a block's main output, and is the key templates read by default
+	Result = "result"
+	// LLMSystemMsg holds the exact system message an LLM block sent to its client
+	LLMSystemMsg = "llm_system_msg"
+	// LLMUserMsg holds the exact user message an LLM block sent to its client, including any included outputs
+	LLMUserMsg = "llm_user_msg"
+)
@@ -20,6 +20,17 @@ type Groq struct {
 	Model  string
 }
 
+func (g *Groq) Provider() string {
+	return "groq"
+}
+
+func (g *Groq) ModelName() string {
+	if g.Model == "" {
+		return GROQ_MODEL_LLAMA3_8B_8192
+	}
+	return g.Model
+}
+
 func (g *Groq) Chat(systemMsg, userMsg string, streamCh chan string) (string, error) {
 	if g.Model == "" {
 		// default to smaller model
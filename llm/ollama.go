@@ -7,7 +7,22 @@ import (
 	ollamaApi "github.com/ollama/ollama/api"
 )
 
-type Ollama struct{}
+const OLLAMA_MODEL_LLAMA3_8B = "llama3:8b"
+
+type Ollama struct {
+	Model string
+}
+
+func (o *Ollama) Provider() string {
+	return "ollama"
+}
+
+func (o *Ollama) ModelName() string {
+	if o.Model == "" {
+		return OLLAMA_MODEL_LLAMA3_8B
+	}
+	return o.Model
+}
 
 func (o *Ollama) Chat(systemMsg, userMsg string, streamCh chan string) (string, error) {
 	ollama, err := ollamaApi.ClientFromEnvironment()
@@ -20,7 +35,7 @@ func (o *Ollama) Chat(systemMsg, userMsg string, streamCh chan string) (string,
 		stream = boolptr(true)
 	}
 	req := &ollamaApi.ChatRequest{
-		Model:  "llama3:8b",
+		Model:  o.ModelName(),
 		Stream: stream,
 	}
 	// Messages
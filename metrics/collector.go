@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lordtatty/goraff"
+)
+
+// Collector records run metrics into a registry through graph hooks
+type Collector struct {
+	Registry *Registry
+
+	Runs          *Counter
+	RunDuration   *Histogram
+	Blocks        *Counter
+	BlockDuration *Histogram
+	Joins         *Counter
+	Retries       *Counter
+	Calls         *Counter
+	CallDuration  *Histogram
+	Tokens        *Counter
+	CallTokens    *Histogram
+}
+
+// NewCollector registers the run metrics in r, creating a registry if r is nil
+func NewCollector(r *Registry) *Collector {
+	if r == nil {
+		r = NewRegistry()
+	}
+	return &Collector{
+		Registry:      r,
+		Runs:          r.NewCounter("goraff_runs_total", "Scaff runs finished, by outcome.", "status"),
+		RunDuration:   r.NewHistogram("goraff_run_duration_seconds", "Time taken by scaff runs.", DefaultBuckets),
+		Blocks:        r.NewCounter("goraff_blocks_total", "Blocks executed, by block name and final node status.", "block", "status"),
+		BlockDuration: r.NewHistogram("goraff_block_duration_seconds", "Time taken by blocks.", DefaultBuckets, "block"),
+		Joins:         r.NewCounter("goraff_join_evaluations_total", "Join conditions checked, by outcome.", "from", "to", "result"),
+		Retries:       r.NewCounter("goraff_retries_total", "Attempts retried by blocks.", "block"),
+		Calls:         r.NewCounter("goraff_calls_total", "External calls made by blocks.", "kind", "provider", "model", "status"),
+		CallDuration:  r.NewHistogram("goraff_call_duration_seconds", "Latency of external calls made by blocks.", DefaultBuckets, "kind", "provider", "model"),
+		Tokens:        r.NewCounter("goraff_llm_tokens_total", "LLM tokens used, by direction. Estimated for clients that do not report usage.", "provider", "model", "direction"),
+		CallTokens:    r.NewHistogram("goraff_llm_call_tokens", "LLM tokens used by each call, by direction.", TokenBuckets, "provider", "model", "direction"),
+	}
+}
+
+// WatchDropped exposes a count of dropped notifications, such as notifiers.AsyncNotifier.Dropped
+func (c *Collector) WatchDropped(name string, dropped func() uint64) {
+	c.Registry.NewCounterFunc(name, "Notifications dropped because a listener queue was full.", func() float64 {
+		return float64(dropped())
+	})
+}
+
+// Hooks returns the hooks to add to a graph to collect its metrics
+func (c *Collector) Hooks() *goraff.Hooks {
+	runs := newRunClock()
+	return &goraff.Hooks{
+		RunStarted: func(g *goraff.ReadableGraph) {
+			runs.start(g.ID())
+		},
+		RunFinished: func(g *goraff.ReadableGraph, err error) {
+			if d, ok := runs.stop(g.ID()); ok {
+				c.RunDuration.Observe(d.Seconds())
+			}
+			c.Runs.Inc(outcome(err))
+		},
+		BlockFinished: func(g *goraff.ReadableGraph, n *goraff.ReadableNode, err error) {
+			c.Blocks.Inc(n.Name(), outcome(err))
+			c.BlockDuration.Observe(time.Since(n.StartedAt()).Seconds(), n.Name())
+		},
+		JoinEvaluated: func(g *goraff.ReadableGraph, j *goraff.Join, met bool, err error) {
+			result := "false"
+			switch {
+			case err != nil:
+				result = "error"
+			case met:
+				result = "true"
+			}
+			c.Joins.Inc(j.From.Name, j.To.Name, result)
+		},
+		Retry: func(n *goraff.ReadableNode, attempt int, err error) {
+			c.Retries.Inc(n.Name())
+		},
+		Call: func(n *goraff.ReadableNode, call goraff.Call) {
+			c.Calls.Inc(call.Kind, call.Provider, call.Model, outcome(call.Err))
+			c.CallDuration.Observe(call.End.Sub(call.Start).Seconds(), call.Kind, call.Provider, call.Model)
+			if call.InputTokens > 0 {
+				c.Tokens.Add(float64(call.InputTokens), call.Provider, call.Model, "input")
+				c.CallTokens.Observe(float64(call.InputTokens), call.Provider, call.Model, "input")
+			}
+			if call.OutputTokens > 0 {
+				c.Tokens.Add(float64(call.OutputTokens), call.Provider, call.Model, "output")
+				c.CallTokens.Observe(float64(call.OutputTokens), call.Provider, call.Model, "output")
+			}
+		},
+	}
+}
+
+func outcome(err error) string {
+	if errors.As(err, &goraff.ErrBlockSuspended{}) || errors.As(err, &goraff.ErrRunSuspended{}) {
+		return string(goraff.NodeStatusSuspended)
+	}
+	if err != nil {
+		return string(goraff.NodeStatusFailed)
+	}
+	return string(goraff.NodeStatusDone)
+}
+
+// runClock remembers when each graph's run started, as graphs do not record it themselves
+type runClock struct {
+	mu      sync.Mutex
+	started map[string]time.Time
+}
+
+func newRunClock() *runClock {
+	return &runClock{started: map[string]time.Time{}}
+}
+
+func (r *runClock) start(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started[id] = time.Now()
+}
+
+func (r *runClock) stop(id string) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.started[id]
+	delete(r.started, id)
+	return time.Since(t), ok
+}
@@ -0,0 +1,82 @@
+package metrics_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type action struct {
+	key, value string
+	retries    int
+	call       *goraff.Call
+	err        error
+}
+
+func (a *action) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	for i := 0; i < a.retries; i++ {
+		n.RecordRetry(i+2, fmt.Errorf("try again"))
+	}
+	if a.call != nil {
+		n.RecordCall(*a.call)
+	}
+	if a.key != "" {
+		n.SetStr(a.key, a.value)
+	}
+	return a.err
+}
+
+func TestCollector(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Now()
+	s := goraff.NewScaff()
+	first := s.Blocks().Add("first", &action{key: "route", value: "left", retries: 2})
+	left := s.Blocks().Add("left", &action{call: &goraff.Call{
+		Kind: "llm", Provider: "groq", Model: "llama3", Start: start, End: start.Add(300 * time.Millisecond),
+		InputTokens: 10, OutputTokens: 4,
+	}})
+	right := s.Blocks().Add("right", &action{})
+	broken := s.Blocks().Add("broken", &action{err: fmt.Errorf("boom")})
+	s.SetEntrypoint(first)
+	s.Joins().Add(first, left, goraff.FollowIfKeyMatches(first, "route", "left"))
+	s.Joins().Add(first, right, goraff.FollowIfKeyMatches(first, "route", "right"))
+	s.Joins().Add(left, broken, nil)
+
+	sut := metrics.NewCollector(nil)
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{sut.Hooks()}}
+	assert.Error(s.Go(g))
+
+	assert.Equal(1.0, sut.Runs.Value("failed"))
+	assert.Equal(uint64(1), sut.RunDuration.Count())
+	assert.Equal(1.0, sut.Blocks.Value("first", "done"))
+	assert.Equal(1.0, sut.Blocks.Value("left", "done"))
+	assert.Equal(1.0, sut.Blocks.Value("broken", "failed"))
+	assert.Equal(0.0, sut.Blocks.Value("right", "done"))
+	assert.Equal(uint64(1), sut.BlockDuration.Count("first"))
+	assert.Equal(1.0, sut.Joins.Value("first", "left", "true"))
+	assert.Equal(1.0, sut.Joins.Value("first", "right", "false"))
+	assert.Equal(1.0, sut.Joins.Value("left", "broken", "true"))
+	assert.Equal(2.0, sut.Retries.Value("first"))
+	assert.Equal(1.0, sut.Calls.Value("llm", "groq", "llama3", "done"))
+	assert.Equal(uint64(1), sut.CallDuration.Count("llm", "groq", "llama3"))
+	assert.Equal(10.0, sut.Tokens.Value("groq", "llama3", "input"))
+	assert.Equal(4.0, sut.Tokens.Value("groq", "llama3", "output"))
+	assert.Equal(uint64(1), sut.CallTokens.Count("groq", "llama3", "input"))
+	assert.Equal(uint64(1), sut.CallTokens.Count("groq", "llama3", "output"))
+}
+
+func TestCollector_WatchDropped(t *testing.T) {
+	assert := assert.New(t)
+	sut := metrics.NewCollector(metrics.NewRegistry())
+	dropped := uint64(3)
+	sut.WatchDropped("goraff_notifications_dropped_total", func() uint64 { return dropped })
+
+	rec := &bytes.Buffer{}
+	assert.NoError(sut.Registry.WriteText(rec))
+	assert.Contains(rec.String(), "goraff_notifications_dropped_total 3\n")
+}
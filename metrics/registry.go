@@ -0,0 +1,298 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit durations in seconds, from a fast block to a slow LLM call
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// TokenBuckets suit token counts of prompts and responses
+var TokenBuckets = []float64{16, 64, 256, 1024, 4096, 16384, 65536}
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeHistogram metricType = "histogram"
+)
+
+// Registry holds metrics and writes them in the Prometheus text exposition format
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]writer
+}
+
+type writer interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(name string, m writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.families == nil {
+		r.families = map[string]writer{}
+	}
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metric %s already registered", name))
+	}
+	r.families[name] = m
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, labels: labels}, values: map[string]*series{}}
+	r.register(name, c)
+	return c
+}
+
+// NewCounterFunc registers a counter whose value is read from fn at scrape time,
+// for counts kept elsewhere such as notifiers.AsyncNotifier.Dropped
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &counterFunc{desc: desc{name: name, help: help}, fn: fn})
+}
+
+// NewHistogram registers a histogram with the given upper bounds and label names.
+// Buckets are sorted; DefaultBuckets are used when none are given.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	h := &Histogram{desc: desc{name: name, help: help, labels: labels}, buckets: b, values: map[string]*series{}}
+	r.register(name, h)
+	return h
+}
+
+// WriteText writes every metric, sorted by name, in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]writer, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("error writing metrics: %w", err)
+	}
+	return nil
+}
+
+// Handler serves the registry for Prometheus to scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) header(w *bufio.Writer, t metricType) {
+	if d.help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, t)
+}
+
+// key joins label values into a map key; \xff cannot appear in valid UTF-8
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s wants %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func sortedSeries(values map[string]*series) []*series {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]*series, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, values[k])
+	}
+	return result
+}
+
+// Counter is a value that only goes up, split by label values
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds v to the series with the given label values. Negative values are ignored.
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		return
+	}
+	k := c.key(labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[k]
+	if !ok {
+		s = &series{labels: append([]string{}, labels...)}
+		c.values[k] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value of the series with the given label values
+func (c *Counter) Value(labels ...string) float64 {
+	k := c.key(labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[k]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.header(w, typeCounter)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range sortedSeries(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, s.labels, "", ""), formatFloat(s.value))
+	}
+}
+
+type counterFunc struct {
+	desc
+	fn func() float64
+}
+
+func (c *counterFunc) write(w *bufio.Writer) {
+	c.header(w, typeCounter)
+	fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.fn()))
+}
+
+// Histogram counts observations into buckets, split by label values
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*series
+}
+
+// Observe records v against the series with the given label values
+func (h *Histogram) Observe(v float64, labels ...string) {
+	k := h.key(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[k]
+	if !ok {
+		s = &series{labels: append([]string{}, labels...), counts: make([]uint64, len(h.buckets))}
+		h.values[k] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count returns how many observations the series with the given label values has
+func (h *Histogram) Count(labels ...string) uint64 {
+	k := h.key(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[k]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.header(w, typeHistogram)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range sortedSeries(h.values) {
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labels, "le", formatFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labels, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labels, "", ""), s.count)
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+1)
+	for i, n := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, n, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,64 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lordtatty/goraff/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	assert := assert.New(t)
+	sut := metrics.NewRegistry()
+	c := sut.NewCounter("b_total", "A counter\nwith a newline.", "name")
+	c.Inc("second")
+	c.Add(2.5, `fir"st`)
+	c.Add(-1, "second")
+	h := sut.NewHistogram("a_seconds", "A histogram.", []float64{1, 0.5}, "name")
+	h.Observe(0.25, "x")
+	h.Observe(0.75, "x")
+	h.Observe(3, "x")
+	sut.NewCounterFunc("c_total", "", func() float64 { return 7 })
+
+	b := &bytes.Buffer{}
+	assert.NoError(sut.WriteText(b))
+	want := `# HELP a_seconds A histogram.
+# TYPE a_seconds histogram
+a_seconds_bucket{name="x",le="0.5"} 1
+a_seconds_bucket{name="x",le="1"} 2
+a_seconds_bucket{name="x",le="+Inf"} 3
+a_seconds_sum{name="x"} 4
+a_seconds_count{name="x"} 3
+# HELP b_total A counter\nwith a newline.
+# TYPE b_total counter
+b_total{name="fir\"st"} 2.5
+b_total{name="second"} 1
+# TYPE c_total counter
+c_total 7
+`
+	assert.Equal(want, b.String())
+	assert.Equal(1.0, c.Value("second"))
+	assert.Equal(uint64(3), h.Count("x"))
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	assert := assert.New(t)
+	sut := metrics.NewRegistry()
+	c := sut.NewCounter("a_total", "", "one", "two")
+	assert.Panics(func() { c.Inc("only one") })
+	assert.Panics(func() { sut.NewCounter("a_total", "") })
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	assert := assert.New(t)
+	sut := metrics.NewRegistry()
+	sut.NewCounter("a_total", "").Inc()
+
+	rec := httptest.NewRecorder()
+	sut.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(200, rec.Code)
+	assert.Equal("text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal("# TYPE a_total counter\na_total 1\n", rec.Body.String())
+}
@@ -2,32 +2,122 @@ package goraff
 
 import (
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// NodeStatus describes where a node is in its lifecycle
+type NodeStatus string
+
+const (
+	NodeStatusRunning NodeStatus = "running"
+	NodeStatusDone    NodeStatus = "done"
+	NodeStatusFailed  NodeStatus = "failed"
+	// NodeStatusSuspended is a node whose block is waiting for an event, see Suspend
+	NodeStatusSuspended NodeStatus = "suspended"
+)
+
 // Node state represents a key value store for an individual node
 type Node struct {
 	id          string
 	name        string
 	state       map[string][][]byte
 	done        bool
-	notifier    ChangeNotifier
+	err         error
+	waitingFor  string
+	startedAt   time.Time
+	finishedAt  time.Time
+	graph       *Graph
 	subGraphs   []*ReadableGraph
 	mut         sync.Mutex
 	triggeredBy []*ReadableNode
+	journal     *journal
 }
 
 func (n *Node) AddSubGraph(s *Graph) {
 	n.mut.Lock()
-	defer n.mut.Unlock()
-	s.Notifier = n.notifier
+	r := n.attach(s)
+	seq := nextSeq()
+	n.mut.Unlock()
+	n.notify(seq, ChangeOpSubGraph, "", 0, []byte(r.ID()))
+}
+
+// attach makes s a sub-graph of the node. It must be called with n.mut held.
+func (n *Node) attach(s *Graph) *ReadableGraph {
+	s.Notifier = n.notifier()
+	s.setParent(n)
+	if s.Hooks == nil && n.graph != nil {
+		s.Hooks = n.graph.Hooks
+	}
+	if s.Journal == nil && n.journal != nil {
+		cfg := n.journal.config
+		s.Journal = &cfg
+	}
 	r := NewReadableGraph(s)
 	n.subGraphs = append(n.subGraphs, r)
+	return r
 }
 
 func (n *Node) MarkDone() {
+	n.mut.Lock()
 	n.done = true
+	if n.finishedAt.IsZero() {
+		n.finishedAt = time.Now()
+	}
+	seq := nextSeq()
+	n.mut.Unlock()
+	n.notify(seq, ChangeOpStatus, "", 0, []byte(NodeStatusDone))
+}
+
+// MarkFailed records the error that stopped the node's block from completing
+func (n *Node) MarkFailed(err error) {
+	n.mut.Lock()
+	n.err = err
+	n.finishedAt = time.Now()
+	seq := nextSeq()
+	n.mut.Unlock()
+	n.notify(seq, ChangeOpStatus, "", 0, []byte(NodeStatusFailed))
+}
+
+// suspend records that the node's block is waiting for the event
+func (n *Node) suspend(event string) {
+	n.mut.Lock()
+	n.waitingFor = event
+	seq := nextSeq()
+	n.mut.Unlock()
+	n.notify(seq, ChangeOpStatus, "", 0, []byte(NodeStatusSuspended))
+}
+
+// resume clears the event the node was waiting for, so its block can carry on
+func (n *Node) resume() {
+	n.mut.Lock()
+	n.waitingFor = ""
+	seq := nextSeq()
+	n.mut.Unlock()
+	n.notify(seq, ChangeOpStatus, "", 0, []byte(NodeStatusRunning))
+}
+
+func (n *Node) notifier() ChangeNotifier {
+	if n.graph == nil {
+		return nil
+	}
+	return n.graph.Notifier
+}
+
+func (n *Node) notify(seq uint64, op ChangeOp, key string, index int, value []byte) {
+	if n.notifier() == nil {
+		return
+	}
+	n.graph.notify(GraphChangeNotification{
+		Seq:      seq,
+		NodeID:   n.id,
+		NodeName: n.name,
+		Key:      key,
+		Op:       op,
+		Index:    index,
+		Value:    value,
+	})
 }
 
 func (n *Node) Add(key string, value []byte) {
@@ -36,10 +126,13 @@ func (n *Node) Add(key string, value []byte) {
 		n.state = make(map[string][][]byte)
 	}
 	n.state[key] = append(n.state[key], value)
-	n.mut.Unlock()
-	if n.notifier != nil {
-		n.notifier.Notify(GraphChangeNotification{NodeID: n.name})
+	index := len(n.state[key]) - 1
+	seq := nextSeq()
+	if n.journal != nil {
+		n.journal.record(seq, JournalOpAdd, n.name, key, value)
 	}
+	n.mut.Unlock()
+	n.notify(seq, ChangeOpAdd, key, index, value)
 }
 
 func (n *Node) AddStr(key, value string) {
@@ -58,10 +151,12 @@ func (n *Node) Set(key string, value []byte) {
 		n.state = make(map[string][][]byte)
 	}
 	n.state[key] = [][]byte{value}
-	n.mut.Unlock()
-	if n.notifier != nil {
-		n.notifier.Notify(GraphChangeNotification{NodeID: n.name})
+	seq := nextSeq()
+	if n.journal != nil {
+		n.journal.record(seq, JournalOpSet, n.name, key, value)
 	}
+	n.mut.Unlock()
+	n.notify(seq, ChangeOpSet, key, 0, value)
 }
 
 func (n *Node) SetStr(key, value string) {
@@ -78,6 +173,8 @@ type ReadableNode struct {
 }
 
 func (n *ReadableNode) Keys() []string {
+	n.node.mut.Lock()
+	defer n.node.mut.Unlock()
 	keys := []string{}
 	for k := range n.node.state {
 		keys = append(keys, k)
@@ -86,10 +183,14 @@ func (n *ReadableNode) Keys() []string {
 }
 
 func (n *ReadableNode) SubGraph() []*ReadableGraph {
+	n.node.mut.Lock()
+	defer n.node.mut.Unlock()
 	if n.node.subGraphs == nil {
 		return nil
 	}
-	return n.node.subGraphs
+	result := make([]*ReadableGraph, len(n.node.subGraphs))
+	copy(result, n.node.subGraphs)
+	return result
 }
 
 func (s *ReadableNode) First(key string) []byte {
@@ -140,9 +241,50 @@ func (s *ReadableNode) Name() string {
 }
 
 func (s *ReadableNode) Done() bool {
+	s.node.mut.Lock()
+	defer s.node.mut.Unlock()
 	return s.node.done
 }
 
+// Err returns the error the node failed with, if any
+func (s *ReadableNode) Err() error {
+	s.node.mut.Lock()
+	defer s.node.mut.Unlock()
+	return s.node.err
+}
+
+// WaitingFor returns the event the node's block is suspended until, or "" if it is not suspended
+func (s *ReadableNode) WaitingFor() string {
+	s.node.mut.Lock()
+	defer s.node.mut.Unlock()
+	return s.node.waitingFor
+}
+
+func (s *ReadableNode) Status() NodeStatus {
+	if s.Err() != nil {
+		return NodeStatusFailed
+	}
+	if s.Done() {
+		return NodeStatusDone
+	}
+	if s.WaitingFor() != "" {
+		return NodeStatusSuspended
+	}
+	return NodeStatusRunning
+}
+
+// StartedAt is when the node was created by its graph
+func (s *ReadableNode) StartedAt() time.Time {
+	return s.node.startedAt
+}
+
+// FinishedAt is when the node was marked done or failed, or zero if it is still running
+func (s *ReadableNode) FinishedAt() time.Time {
+	s.node.mut.Lock()
+	defer s.node.mut.Unlock()
+	return s.node.finishedAt
+}
+
 func (n *ReadableNode) TriggeredBy() []*ReadableNode {
 	return n.node.triggeredBy
 }
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/lordtatty/goraff"
 	"github.com/stretchr/testify/assert"
@@ -279,3 +280,20 @@ type MockNotifier struct {
 func (m *MockNotifier) Notify(notification goraff.GraphChangeNotification) {
 	m.Notified = true
 }
+
+func TestNode_Timestamps(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	before := time.Now()
+	n := g.NewNode("node1", nil)
+	assert.False(n.Get().StartedAt().Before(before))
+	assert.True(n.Get().FinishedAt().IsZero())
+
+	n.MarkDone()
+	finished := n.Get().FinishedAt()
+	assert.False(finished.Before(n.Get().StartedAt()))
+
+	// Marking done again keeps the first finish time
+	n.MarkDone()
+	assert.Equal(finished, n.Get().FinishedAt())
+}
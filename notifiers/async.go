@@ -0,0 +1,202 @@
+package notifiers
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/lordtatty/goraff"
+)
+
+const defaultQueueSize = 256
+
+// OverflowPolicy decides what an AsyncNotifier does when a subscriber's queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Notify wait until the subscriber has room
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued notification to make room
+	OverflowDropOldest
+	// OverflowCoalesce removes the newest queued notification with the same node, op and key and
+	// queues the new one at the back, so only the latest write to a key is delivered and
+	// notifications stay in the order they were sent. Node, status and sub-graph changes are
+	// never replaced. When there is nothing to replace, the oldest queued value change is dropped,
+	// or the oldest notification if only node, status and sub-graph changes are queued.
+	// Appended values in replaced notifications are lost, so listeners must re-read node state.
+	OverflowCoalesce
+)
+
+// AsyncNotifier delivers notifications to each listener on its own goroutine,
+// so slow listeners do not hold up the nodes being written to.
+// Each listener has a bounded queue and receives notifications in the order they were sent.
+type AsyncNotifier struct {
+	// QueueSize bounds each listener's queue. Defaults to 256.
+	QueueSize int
+	Overflow  OverflowPolicy
+	mu        sync.Mutex
+	subs      []*asyncListener
+	closed    bool
+	dropped   atomic.Uint64
+	wg        sync.WaitGroup
+}
+
+func NewAsyncNotifier(queueSize int, overflow OverflowPolicy) *AsyncNotifier {
+	return &AsyncNotifier{QueueSize: queueSize, Overflow: overflow}
+}
+
+// Listen starts delivering notifications to the callback on a new goroutine
+func (n *AsyncNotifier) Listen(callback func(goraff.GraphChangeNotification)) {
+	if callback == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	size := n.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	l := &asyncListener{callback: callback, size: size}
+	l.cond = sync.NewCond(&l.mu)
+	n.subs = append(n.subs, l)
+	n.wg.Add(1)
+	go l.run(&n.wg)
+}
+
+// Notify queues the notification for every listener.
+// Notifications sent after Close are discarded.
+func (n *AsyncNotifier) Notify(notification goraff.GraphChangeNotification) {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	subs := make([]*asyncListener, len(n.subs))
+	copy(subs, n.subs)
+	n.mu.Unlock()
+	for _, l := range subs {
+		if l.push(notification, n.Overflow) {
+			n.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of notifications discarded or coalesced because a queue was full
+func (n *AsyncNotifier) Dropped() uint64 {
+	return n.dropped.Load()
+}
+
+// Close stops accepting notifications and waits until every queued one has been delivered
+func (n *AsyncNotifier) Close() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	subs := n.subs
+	n.mu.Unlock()
+	for _, l := range subs {
+		l.close()
+	}
+	n.wg.Wait()
+}
+
+type asyncListener struct {
+	callback func(goraff.GraphChangeNotification)
+	size     int
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []goraff.GraphChangeNotification
+	closed   bool
+}
+
+// push queues the notification, returning true if a notification was dropped to fit it in
+func (l *asyncListener) push(c goraff.GraphChangeNotification, policy OverflowPolicy) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for policy == OverflowBlock && len(l.queue) >= l.size && !l.closed {
+		l.cond.Wait()
+	}
+	if l.closed {
+		return false
+	}
+	dropped := false
+	if len(l.queue) >= l.size {
+		dropped = true
+		if policy == OverflowCoalesce {
+			if i := l.lastForKey(c); i >= 0 {
+				// queued at the back, so a newer seq is never delivered before an older one
+				l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			} else {
+				l.dropOldestValue()
+			}
+		} else {
+			l.queue = l.queue[1:]
+		}
+	}
+	l.queue = append(l.queue, c)
+	l.cond.Broadcast()
+	return dropped
+}
+
+// lastForKey returns the index of the newest queued write to the same key of the same node,
+// or -1 if there is none or c is a structural change
+func (l *asyncListener) lastForKey(c goraff.GraphChangeNotification) int {
+	if structural(c.Op) {
+		return -1
+	}
+	for i := len(l.queue) - 1; i >= 0; i-- {
+		q := l.queue[i]
+		if q.GraphID == c.GraphID && q.NodeID == c.NodeID && q.Op == c.Op && q.Key == c.Key {
+			return i
+		}
+	}
+	return -1
+}
+
+// dropOldestValue removes the oldest queued value change, or the oldest notification
+// when every queued one is structural
+func (l *asyncListener) dropOldestValue() {
+	for i, q := range l.queue {
+		if !structural(q.Op) {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return
+		}
+	}
+	l.queue = l.queue[1:]
+}
+
+// structural reports whether op changes a graph's shape or a node's status, which
+// listeners cannot work out from later notifications if it is lost
+func structural(op goraff.ChangeOp) bool {
+	return op == goraff.ChangeOpNode || op == goraff.ChangeOpStatus || op == goraff.ChangeOpSubGraph
+}
+
+func (l *asyncListener) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	l.cond.Broadcast()
+}
+
+func (l *asyncListener) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		l.mu.Lock()
+		for len(l.queue) == 0 && !l.closed {
+			l.cond.Wait()
+		}
+		if len(l.queue) == 0 {
+			l.mu.Unlock()
+			return
+		}
+		c := l.queue[0]
+		l.queue = l.queue[1:]
+		l.cond.Broadcast()
+		l.mu.Unlock()
+		l.callback(c)
+	}
+}
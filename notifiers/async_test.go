@@ -0,0 +1,258 @@
+package notifiers_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedListener records notifications, holding up delivery after the first until released
+type gatedListener struct {
+	mu       sync.Mutex
+	received []goraff.GraphChangeNotification
+	first    chan struct{}
+	gate     chan struct{}
+	once     sync.Once
+}
+
+func newGatedListener() *gatedListener {
+	return &gatedListener{first: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (g *gatedListener) callback(c goraff.GraphChangeNotification) {
+	g.mu.Lock()
+	g.received = append(g.received, c)
+	g.mu.Unlock()
+	g.once.Do(func() {
+		close(g.first)
+		<-g.gate
+	})
+}
+
+func (g *gatedListener) nodeSeqs() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	result := []string{}
+	for _, c := range g.received {
+		result = append(result, c.NodeID+string(c.Value))
+	}
+	return result
+}
+
+func change(nodeID, value string) goraff.GraphChangeNotification {
+	return goraff.GraphChangeNotification{NodeID: nodeID, Value: []byte(value)}
+}
+
+func TestAsyncNotifier_DeliversInOrder(t *testing.T) {
+	assert := assert.New(t)
+	sut := notifiers.NewAsyncNotifier(0, notifiers.OverflowBlock)
+	var mu sync.Mutex
+	got := []string{}
+	sut.Listen(func(c goraff.GraphChangeNotification) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, string(c.Value))
+	})
+	want := []string{}
+	for _, v := range []string{"1", "2", "3", "4", "5"} {
+		sut.Notify(change("a", v))
+		want = append(want, v)
+	}
+	sut.Close()
+	assert.Equal(want, got)
+	assert.Equal(uint64(0), sut.Dropped())
+}
+
+func TestAsyncNotifier_DropOldest(t *testing.T) {
+	assert := assert.New(t)
+	sut := notifiers.NewAsyncNotifier(2, notifiers.OverflowDropOldest)
+	l := newGatedListener()
+	sut.Listen(l.callback)
+
+	sut.Notify(change("a", "1"))
+	<-l.first
+	start := time.Now()
+	for _, v := range []string{"2", "3", "4", "5", "6"} {
+		sut.Notify(change("a", v))
+	}
+	assert.Less(time.Since(start), 100*time.Millisecond, "Notify should not wait for a slow listener")
+	close(l.gate)
+	sut.Close()
+
+	assert.Equal([]string{"a1", "a5", "a6"}, l.nodeSeqs())
+	assert.Equal(uint64(3), sut.Dropped())
+}
+
+func TestAsyncNotifier_Coalesce(t *testing.T) {
+	assert := assert.New(t)
+	sut := notifiers.NewAsyncNotifier(2, notifiers.OverflowCoalesce)
+	l := newGatedListener()
+	sut.Listen(l.callback)
+
+	sut.Notify(change("a", "1"))
+	<-l.first
+	sut.Notify(change("a", "2"))
+	sut.Notify(change("b", "1"))
+	sut.Notify(change("a", "3")) // replaces a2
+	sut.Notify(change("b", "2")) // replaces b1
+	sut.Notify(change("c", "1")) // nothing to coalesce, drops a3
+	close(l.gate)
+	sut.Close()
+
+	assert.Equal([]string{"a1", "b2", "c1"}, l.nodeSeqs())
+	assert.Equal(uint64(3), sut.Dropped())
+}
+
+func TestAsyncNotifier_CoalesceKeepsOrder(t *testing.T) {
+	assert := assert.New(t)
+	sut := notifiers.NewAsyncNotifier(3, notifiers.OverflowCoalesce)
+	l := newGatedListener()
+	sut.Listen(l.callback)
+
+	seq := uint64(0)
+	set := func(nodeID string) {
+		seq++
+		sut.Notify(goraff.GraphChangeNotification{Seq: seq, NodeID: nodeID, Op: goraff.ChangeOpSet, Key: "result"})
+	}
+	set("a")
+	<-l.first
+	set("a")
+	set("b")
+	set("c")
+	set("a") // coalesces with the queued a, which was sent before b and c
+	set("b")
+	close(l.gate)
+	sut.Close()
+
+	got := []uint64{}
+	for _, c := range l.received {
+		got = append(got, c.Seq)
+	}
+	assert.Equal([]uint64{1, 4, 5, 6}, got)
+	assert.IsIncreasing(got)
+}
+
+func TestAsyncNotifier_CoalesceKeepsStatus(t *testing.T) {
+	assert := assert.New(t)
+	sut := notifiers.NewAsyncNotifier(3, notifiers.OverflowCoalesce)
+	l := newGatedListener()
+	sut.Listen(l.callback)
+
+	set := func(key, value string) goraff.GraphChangeNotification {
+		return goraff.GraphChangeNotification{NodeID: "a", Op: goraff.ChangeOpSet, Key: key, Value: []byte(value)}
+	}
+	status := goraff.GraphChangeNotification{NodeID: "a", Op: goraff.ChangeOpStatus, Value: []byte(goraff.NodeStatusDone)}
+	sub := goraff.GraphChangeNotification{NodeID: "a", Op: goraff.ChangeOpSubGraph, Value: []byte("g2")}
+
+	sut.Notify(set("result", "0"))
+	<-l.first
+	sut.Notify(set("result", "1"))
+	sut.Notify(status)
+	sut.Notify(sub)
+	sut.Notify(set("result", "2")) // replaces result 1, not the status or sub-graph
+	sut.Notify(set("other", "1"))  // nothing to replace, drops result 2 rather than the status
+	close(l.gate)
+	sut.Close()
+
+	got := []string{}
+	for _, c := range l.received {
+		got = append(got, string(c.Op)+" "+c.Key+" "+string(c.Value))
+	}
+	assert.Equal([]string{"set result 0", "status  done", "subgraph  g2", "set other 1"}, got)
+	assert.Equal(uint64(2), sut.Dropped())
+}
+
+func TestAsyncNotifier_Block(t *testing.T) {
+	assert := assert.New(t)
+	sut := notifiers.NewAsyncNotifier(1, notifiers.OverflowBlock)
+	l := newGatedListener()
+	sut.Listen(l.callback)
+
+	sut.Notify(change("a", "1"))
+	<-l.first
+	sut.Notify(change("a", "2"))
+	returned := make(chan struct{})
+	go func() {
+		sut.Notify(change("a", "3"))
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("Notify should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(l.gate)
+	<-returned
+	sut.Close()
+
+	assert.Equal([]string{"a1", "a2", "a3"}, l.nodeSeqs())
+	assert.Equal(uint64(0), sut.Dropped())
+}
+
+func TestAsyncNotifier_CloseFlushes(t *testing.T) {
+	assert := assert.New(t)
+	sut := &notifiers.AsyncNotifier{}
+	var mu sync.Mutex
+	count := 0
+	sut.Listen(func(c goraff.GraphChangeNotification) {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+	})
+	for i := 0; i < 10; i++ {
+		sut.Notify(change("a", "x"))
+	}
+	sut.Close()
+	assert.Equal(10, count)
+
+	// Notifications after close are discarded
+	assert.NotPanics(func() {
+		sut.Notify(change("a", "x"))
+		sut.Close()
+	})
+	assert.Equal(10, count)
+}
+
+func TestAsyncNotifier_CallbackCanUseNotifier(t *testing.T) {
+	assert := assert.New(t)
+	sut := &notifiers.AsyncNotifier{}
+	done := make(chan struct{})
+	sut.Listen(func(c goraff.GraphChangeNotification) {
+		if string(c.Value) == "first" {
+			sut.Notify(change("a", "second"))
+			return
+		}
+		close(done)
+	})
+	sut.Notify(change("a", "first"))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail("callback notifying the notifier should not deadlock")
+	}
+	sut.Close()
+}
+
+func TestAsyncNotifier_WithGraph(t *testing.T) {
+	assert := assert.New(t)
+	sut := &notifiers.AsyncNotifier{}
+	var mu sync.Mutex
+	got := []string{}
+	sut.Listen(func(c goraff.GraphChangeNotification) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, c.NodeName+":"+string(c.Value))
+	})
+	g := &goraff.Graph{Notifier: sut}
+	n := g.NewNode("node1", nil)
+	n.SetStr("result", "a")
+	n.SetStr("result", "ab")
+	sut.Close()
+	// the first notification is for the node being created
+	assert.Equal([]string{"node1:", "node1:a", "node1:ab"}, got)
+}
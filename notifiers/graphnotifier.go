@@ -1,14 +1,23 @@
 package notifiers
 
 import (
+	"slices"
 	"sync"
 
 	"github.com/lordtatty/goraff"
 )
 
+const defaultChannelBuffer = 64
+
 type GraphNotifier struct {
 	mu        sync.Mutex
 	callbacks []func(goraff.GraphChangeNotification)
+	subs      map[uint64]*subscriber
+	nextSubID uint64
+	// ChannelBuffer sizes the channels returned by Subscribe. Defaults to 64.
+	// A subscriber whose channel is full when a change is notified is unsubscribed,
+	// so graph writers never wait on a slow subscriber.
+	ChannelBuffer int
 }
 
 // Register adds a new callback function.
@@ -24,11 +33,126 @@ func (n *GraphNotifier) Listen(callback func(goraff.GraphChangeNotification)) {
 	n.callbacks = append(n.callbacks, callback)
 }
 
+// Subscribe returns a channel receiving every notification the filter matches.
+// The channel is closed by Unsubscribe, or when the subscriber falls ChannelBuffer
+// notifications behind, after which it can subscribe again and catch up from a snapshot.
+func (n *GraphNotifier) Subscribe(filter Filter) (Subscription, <-chan goraff.GraphChangeNotification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subs == nil {
+		n.subs = make(map[uint64]*subscriber)
+	}
+	size := n.ChannelBuffer
+	if size <= 0 {
+		size = defaultChannelBuffer
+	}
+	n.nextSubID++
+	s := &subscriber{
+		filter: filter,
+		ch:     make(chan goraff.GraphChangeNotification, size),
+	}
+	n.subs[n.nextSubID] = s
+	return Subscription{id: n.nextSubID}, s.ch
+}
+
+// Unsubscribe stops delivery to the subscription and closes its channel.
+// Unsubscribing more than once is a no-op.
+func (n *GraphNotifier) Unsubscribe(sub Subscription) {
+	n.mu.Lock()
+	s, ok := n.subs[sub.id]
+	delete(n.subs, sub.id)
+	n.mu.Unlock()
+	if ok {
+		s.close()
+	}
+}
+
 // Notify triggers all registered callbacks with the given notification.
+// Callbacks run on the caller's goroutine, outside the lock, so they may use the notifier themselves.
 func (n *GraphNotifier) Notify(notification goraff.GraphChangeNotification) {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-	for _, callback := range n.callbacks {
+	callbacks := make([]func(goraff.GraphChangeNotification), len(n.callbacks))
+	copy(callbacks, n.callbacks)
+	subs := make(map[uint64]*subscriber, len(n.subs))
+	for id, s := range n.subs {
+		subs[id] = s
+	}
+	n.mu.Unlock()
+	for _, callback := range callbacks {
 		callback(notification)
 	}
+	for id, s := range subs {
+		if !s.send(notification) {
+			n.Unsubscribe(Subscription{id: id})
+		}
+	}
+}
+
+// Subscription identifies a channel returned by Subscribe
+type Subscription struct {
+	id uint64
+}
+
+// Filter selects the notifications a subscription receives.
+// Empty fields match everything.
+type Filter struct {
+	GraphID string
+	// IncludeDescendants also matches changes in sub-graphs nested anywhere below GraphID
+	IncludeDescendants bool
+	NodeName           string
+	Key                string
+	Ops                []goraff.ChangeOp
+}
+
+func (f Filter) Match(c goraff.GraphChangeNotification) bool {
+	if f.GraphID != "" && c.GraphID != f.GraphID {
+		if !f.IncludeDescendants || !slices.Contains(c.ParentGraphIDs, f.GraphID) {
+			return false
+		}
+	}
+	if f.NodeName != "" && c.NodeName != f.NodeName {
+		return false
+	}
+	if f.Key != "" && c.Key != f.Key {
+		return false
+	}
+	if len(f.Ops) > 0 && !slices.Contains(f.Ops, c.Op) {
+		return false
+	}
+	return true
+}
+
+type subscriber struct {
+	filter Filter
+	mu     sync.Mutex
+	ch     chan goraff.GraphChangeNotification
+	closed bool
+}
+
+// send delivers the notification if the filter matches it, without waiting,
+// and reports false when the channel is full
+func (s *subscriber) send(c goraff.GraphChangeNotification) bool {
+	if !s.filter.Match(c) {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	select {
+	case s.ch <- c:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
 }
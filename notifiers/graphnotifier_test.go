@@ -243,3 +243,17 @@ func TestGraphNotifier_CallbackOrder(t *testing.T) {
 	expectedOrder := []int{1, 2}
 	assert.Equal(t, expectedOrder, callOrder)
 }
+
+func TestGraphNotifier_CallbackCanUseNotifier(t *testing.T) {
+	notifier := &notifiers.GraphNotifier{}
+	called := false
+	notifier.Listen(func(notification goraff.GraphChangeNotification) {
+		// Registering from inside a callback used to deadlock
+		notifier.Listen(func(goraff.GraphChangeNotification) {
+			called = true
+		})
+	})
+	notifier.Notify(goraff.GraphChangeNotification{NodeID: "node123"})
+	notifier.Notify(goraff.GraphChangeNotification{NodeID: "node123"})
+	assert.True(t, called)
+}
@@ -0,0 +1,118 @@
+package notifiers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_Match(t *testing.T) {
+	c := goraff.GraphChangeNotification{
+		GraphID:        "sub",
+		ParentGraphIDs: []string{"root", "middle"},
+		NodeName:       "node1",
+		Key:            "result",
+		Op:             goraff.ChangeOpSet,
+	}
+	tests := []struct {
+		name   string
+		filter notifiers.Filter
+		want   bool
+	}{
+		{name: "empty", filter: notifiers.Filter{}, want: true},
+		{name: "graph", filter: notifiers.Filter{GraphID: "sub"}, want: true},
+		{name: "other graph", filter: notifiers.Filter{GraphID: "other"}, want: false},
+		{name: "ancestor without descendants", filter: notifiers.Filter{GraphID: "root"}, want: false},
+		{name: "ancestor with descendants", filter: notifiers.Filter{GraphID: "root", IncludeDescendants: true}, want: true},
+		{name: "node name", filter: notifiers.Filter{NodeName: "node1"}, want: true},
+		{name: "other node name", filter: notifiers.Filter{NodeName: "node2"}, want: false},
+		{name: "key", filter: notifiers.Filter{Key: "result"}, want: true},
+		{name: "other key", filter: notifiers.Filter{Key: "other"}, want: false},
+		{name: "ops", filter: notifiers.Filter{Ops: []goraff.ChangeOp{goraff.ChangeOpAdd, goraff.ChangeOpSet}}, want: true},
+		{name: "other ops", filter: notifiers.Filter{Ops: []goraff.ChangeOp{goraff.ChangeOpStatus}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(c))
+		})
+	}
+}
+
+func TestGraphNotifier_Subscribe(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	fanout := g.NewNode("fanout", nil)
+	sub := &goraff.Graph{}
+	fanout.AddSubGraph(sub)
+	subID := goraff.NewReadableGraph(sub).ID()
+
+	_, subCh := notifier.Subscribe(notifiers.Filter{GraphID: subID, Ops: []goraff.ChangeOp{goraff.ChangeOpSet}})
+	_, keyCh := notifier.Subscribe(notifiers.Filter{Key: "result"})
+
+	g.NewNode("other", nil).SetStr("result", "top")
+	sub.NewNode("item", nil).SetStr("result", "inner")
+	sub.NewNode("item", nil).SetStr("ignored", "inner")
+
+	got := <-subCh
+	assert.Equal(subID, got.GraphID)
+	assert.Equal("result", got.Key)
+	got = <-subCh
+	assert.Equal("ignored", got.Key)
+	assert.Len(subCh, 0)
+
+	assert.Equal("top", string((<-keyCh).Value))
+	assert.Equal("inner", string((<-keyCh).Value))
+	assert.Len(keyCh, 0)
+}
+
+func TestGraphNotifier_Unsubscribe(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	sub, ch := notifier.Subscribe(notifiers.Filter{})
+	notifier.Notify(goraff.GraphChangeNotification{NodeID: "node123"})
+	notifier.Unsubscribe(sub)
+	notifier.Notify(goraff.GraphChangeNotification{NodeID: "node456"})
+
+	got := []string{}
+	for c := range ch {
+		got = append(got, c.NodeID)
+	}
+	assert.Equal([]string{"node123"}, got)
+	assert.NotPanics(func() { notifier.Unsubscribe(sub) })
+}
+
+func TestGraphNotifier_SlowSubscriberIsDisconnected(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{ChannelBuffer: 2}
+	sub, stuck := notifier.Subscribe(notifiers.Filter{})
+	_, other := notifier.Subscribe(notifiers.Filter{NodeName: "kept"})
+	g := &goraff.Graph{Notifier: notifier}
+
+	// a subscriber that never reads does not hold up the graph's writers
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		n := g.NewNode("node", nil)
+		for i := 0; i < 10; i++ {
+			n.SetStr("result", "value")
+		}
+		g.NewNode("kept", nil)
+	}()
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("graph writes blocked on a full subscriber")
+	}
+
+	got := 0
+	for range stuck {
+		got++
+	}
+	assert.Equal(2, got)
+	assert.Equal("kept", (<-other).NodeName)
+	assert.NotPanics(func() { notifier.Unsubscribe(sub) })
+}
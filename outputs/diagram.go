@@ -0,0 +1,137 @@
+package outputs
+
+import (
+	"fmt"
+
+	"github.com/lordtatty/goraff"
+)
+
+// maxScaffDepth stops runaway recursion for scaffs that contain themselves
+const maxScaffDepth = 10
+
+// diagram is the shape shared by the Mermaid and DOT exporters
+type diagram struct {
+	root  *cluster
+	edges []diagramEdge
+	ids   int
+}
+
+type cluster struct {
+	id       string
+	label    string
+	nodes    []*diagramNode
+	clusters []*cluster
+}
+
+type diagramNode struct {
+	id         string
+	label      string
+	status     goraff.NodeStatus
+	entrypoint bool
+}
+
+type diagramEdge struct {
+	from   string
+	to     string
+	label  string
+	dotted bool
+}
+
+func (d *diagram) nextID(prefix string) string {
+	d.ids++
+	return fmt.Sprintf("%s%d", prefix, d.ids)
+}
+
+func (d *diagram) addNode(c *cluster, n *diagramNode) *diagramNode {
+	n.id = d.nextID("n")
+	c.nodes = append(c.nodes, n)
+	return n
+}
+
+func (d *diagram) addCluster(parent *cluster, label string) *cluster {
+	c := &cluster{id: d.nextID("c"), label: label}
+	parent.clusters = append(parent.clusters, c)
+	return c
+}
+
+func scaffDiagram(s *goraff.Scaff) *diagram {
+	d := &diagram{root: &cluster{}}
+	d.addScaff(d.root, s, 0)
+	return d
+}
+
+// addScaff adds the scaff's blocks to the cluster, returning the diagram id of its entrypoint
+func (d *diagram) addScaff(c *cluster, s *goraff.Scaff, depth int) string {
+	ids := map[string]string{}
+	entry := ""
+	for _, b := range s.Blocks().All() {
+		isEntry := s.Entrypoint() == b
+		n := d.addNode(c, &diagramNode{label: b.Name, entrypoint: isEntry})
+		ids[b.Name] = n.id
+		if isEntry {
+			entry = n.id
+		}
+		subs, ok := b.Action.(goraff.SubScaffer)
+		if !ok || depth >= maxScaffDepth {
+			continue
+		}
+		for _, sub := range subs.SubScaffs() {
+			if sub == nil {
+				continue
+			}
+			sc := d.addCluster(c, fmt.Sprintf("%s (%T)", b.Name, b.Action))
+			if subEntry := d.addScaff(sc, sub, depth+1); subEntry != "" {
+				d.edges = append(d.edges, diagramEdge{from: n.id, to: subEntry, label: "runs", dotted: true})
+			}
+		}
+	}
+	for _, j := range s.Joins().All() {
+		d.edges = append(d.edges, diagramEdge{
+			from:  ids[j.From.Name],
+			to:    ids[j.To.Name],
+			label: describeCondition(j.Condition),
+		})
+	}
+	return entry
+}
+
+func describeCondition(c goraff.FollowIf) string {
+	if c == nil {
+		return ""
+	}
+	if s, ok := c.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+func graphDiagram(r *goraff.ReadableGraph) *diagram {
+	d := &diagram{root: &cluster{}}
+	ids := map[string]string{}
+	d.addGraph(d.root, r, ids)
+	return d
+}
+
+// addGraph adds the graph's nodes to the cluster, returning the diagram id of its first node
+func (d *diagram) addGraph(c *cluster, r *goraff.ReadableGraph, ids map[string]string) string {
+	first := ""
+	for _, n := range r.Nodes() {
+		dn := d.addNode(c, &diagramNode{label: n.Name(), status: n.Status()})
+		ids[n.ID()] = dn.id
+		if first == "" {
+			first = dn.id
+		}
+		for _, t := range n.TriggeredBy() {
+			if from, ok := ids[t.ID()]; ok {
+				d.edges = append(d.edges, diagramEdge{from: from, to: dn.id})
+			}
+		}
+		for i, sub := range n.SubGraph() {
+			sc := d.addCluster(c, fmt.Sprintf("%s #%d", n.Name(), i))
+			if subFirst := d.addGraph(sc, sub, ids); subFirst != "" {
+				d.edges = append(d.edges, diagramEdge{from: dn.id, to: subFirst, dotted: true})
+			}
+		}
+	}
+	return first
+}
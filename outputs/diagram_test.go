@@ -0,0 +1,130 @@
+package outputs_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+func testScaff() *goraff.Scaff {
+	sub := goraff.NewScaff()
+	rev := sub.Blocks().Add("reverse", &blockactions.Print{})
+	sub.SetEntrypoint(rev)
+
+	s := goraff.NewScaff()
+	in := s.Blocks().Add("input", &blockactions.Input{Value: "x"})
+	fan := s.Blocks().Add("fanout", &blockactions.FanOut{Scaff: sub})
+	out := s.Blocks().Add("print", &blockactions.Print{})
+	s.SetEntrypoint(in)
+	s.Joins().Add(in, fan, nil)
+	s.Joins().Add(fan, out, goraff.FollowIfKeyMatches(fan, "result", `say "hi"`))
+	return s
+}
+
+func testGraph() *goraff.Graph {
+	g := &goraff.Graph{}
+	in := g.NewNode("input", nil)
+	in.MarkDone()
+	fan := g.NewNode("fanout", []*goraff.ReadableNode{in.Get()})
+	sub := &goraff.Graph{}
+	sub.NewNode("reverse", nil).MarkFailed(fmt.Errorf("boom"))
+	fan.AddSubGraph(sub)
+	return g
+}
+
+func TestScaffMermaid(t *testing.T) {
+	assert := assert.New(t)
+	want := `flowchart TD
+    classDef entrypoint stroke-width:3px
+    classDef running fill:#fff3cd,stroke:#d4a106
+    classDef done fill:#d4edda,stroke:#28a745
+    classDef failed fill:#f8d7da,stroke:#dc3545
+    classDef suspended fill:#e2d9f3,stroke:#6f42c1
+    n1["input"]
+    class n1 entrypoint
+    n2["fanout"]
+    n5["print"]
+    subgraph c3["fanout (*blockactions.FanOut)"]
+        n4["reverse"]
+        class n4 entrypoint
+    end
+    n2 -.->|"runs"| n4
+    n1 --> n2
+    n2 -->|"fanout.result == #quot;say \#quot;hi\#quot;#quot;"| n5
+`
+	assert.Equal(want, outputs.ScaffMermaid(testScaff()))
+}
+
+func TestScaffDOT(t *testing.T) {
+	assert := assert.New(t)
+	want := `digraph goraff {
+    node [shape=box, style=rounded];
+    n1 [label="input", penwidth=3];
+    n2 [label="fanout"];
+    n5 [label="print"];
+    subgraph cluster_c3 {
+        label="fanout (*blockactions.FanOut)";
+        n4 [label="reverse", penwidth=3];
+    }
+    n2 -> n4 [label="runs", style=dashed];
+    n1 -> n2;
+    n2 -> n5 [label="fanout.result == \"say \\\"hi\\\"\""];
+}
+`
+	assert.Equal(want, outputs.ScaffDOT(testScaff()))
+}
+
+func TestGraphMermaid(t *testing.T) {
+	assert := assert.New(t)
+	want := `flowchart TD
+    classDef entrypoint stroke-width:3px
+    classDef running fill:#fff3cd,stroke:#d4a106
+    classDef done fill:#d4edda,stroke:#28a745
+    classDef failed fill:#f8d7da,stroke:#dc3545
+    classDef suspended fill:#e2d9f3,stroke:#6f42c1
+    n1["input"]
+    class n1 done
+    n2["fanout"]
+    class n2 running
+    subgraph c3["fanout #0"]
+        n4["reverse"]
+        class n4 failed
+    end
+    n1 --> n2
+    n2 -.-> n4
+`
+	assert.Equal(want, outputs.GraphMermaid(goraff.NewReadableGraph(testGraph())))
+}
+
+func TestGraphDOT(t *testing.T) {
+	assert := assert.New(t)
+	want := `digraph goraff {
+    node [shape=box, style=rounded];
+    n1 [label="input", style="rounded,filled", fillcolor="#d4edda"];
+    n2 [label="fanout", style="rounded,filled", fillcolor="#fff3cd"];
+    subgraph cluster_c3 {
+        label="fanout #0";
+        n4 [label="reverse", style="rounded,filled", fillcolor="#f8d7da"];
+    }
+    n1 -> n2;
+    n2 -> n4 [style=dashed];
+}
+`
+	assert.Equal(want, outputs.GraphDOT(goraff.NewReadableGraph(testGraph())))
+}
+
+func TestGraphDOT_LineageFromScaffRun(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	a := s.Blocks().Add("a", &blockactions.Input{Value: "x"})
+	b := s.Blocks().Add("b", &blockactions.Input{Value: "y"})
+	s.SetEntrypoint(a)
+	s.Joins().Add(a, b, nil)
+	g := &goraff.Graph{}
+	assert.NoError(s.Go(g))
+	assert.Contains(outputs.GraphDOT(goraff.NewReadableGraph(g)), "n1 -> n2;")
+}
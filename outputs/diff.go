@@ -0,0 +1,253 @@
+package outputs
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/redact"
+)
+
+// DiffSchema is the JSON schema for the messages produced by Differ
+//
+//go:embed diff.schema.json
+var DiffSchema string
+
+const (
+	MessageTypeSnapshot = "snapshot"
+	MessageTypePatch    = "patch"
+)
+
+// PatchOp is the kind of change a Patch describes
+type PatchOp string
+
+const (
+	PatchNodeAdded        PatchOp = "node_added"
+	PatchKeyAppended      PatchOp = "key_appended"
+	PatchKeySet           PatchOp = "key_set"
+	PatchStatusChanged    PatchOp = "status_changed"
+	PatchSubGraphAttached PatchOp = "subgraph_attached"
+)
+
+// Message is either a full snapshot of a graph or a patch to apply to one.
+// Clients apply every patch with a Seq greater than the snapshot's Seq, in Seq order.
+// Patches are idempotent, so a patch for a change already in the snapshot is harmless.
+type Message struct {
+	Type     string  `json:"type"`
+	Seq      uint64  `json:"seq"`
+	Snapshot *Output `json:"snapshot,omitempty"`
+	Patch    *Patch  `json:"patch,omitempty"`
+}
+
+type Patch struct {
+	Op      PatchOp `json:"op"`
+	GraphID string  `json:"graph_id"`
+	// ParentGraphIDs lists the graphs GraphID is nested in, outermost first
+	ParentGraphIDs []string `json:"parent_graph_ids,omitempty"`
+	NodeID         string   `json:"node_id"`
+	NodeName       string   `json:"node_name,omitempty"`
+	Key            string   `json:"key,omitempty"`
+	// Index is the position of an appended value within the key
+	Index  int    `json:"index,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Status string `json:"status,omitempty"`
+	// Error is the node's error when Status is failed
+	Error string `json:"error,omitempty"`
+	// SubGraph holds the state of an attached sub-graph at the time it was attached
+	SubGraph *Output `json:"subgraph,omitempty"`
+}
+
+// Differ turns change notifications into patches against an initial snapshot
+type Differ struct {
+	// Redactor, when set, hides sensitive values in snapshots and patches
+	Redactor *redact.Redactor
+	graph    *goraff.ReadableGraph
+}
+
+func NewDiffer(r *goraff.ReadableGraph) *Differ {
+	return &Differ{graph: r}
+}
+
+func (d *Differ) outputter() *Outputter {
+	return &Outputter{Redactor: d.Redactor}
+}
+
+// Snapshot returns the current state of the whole graph
+func (d *Differ) Snapshot() Message {
+	// Read the sequence first: every change at or below it is in the snapshot
+	seq := goraff.LastSeq()
+	return Message{
+		Type:     MessageTypeSnapshot,
+		Seq:      seq,
+		Snapshot: d.outputter().Output(d.graph),
+	}
+}
+
+// Patch converts a notification into a patch message.
+// It returns false for notifications from outside the graph.
+func (d *Differ) Patch(c goraff.GraphChangeNotification) (Message, bool) {
+	id := d.graph.ID()
+	if c.GraphID != id && !slices.Contains(c.ParentGraphIDs, id) {
+		return Message{}, false
+	}
+	p := &Patch{
+		GraphID:  c.GraphID,
+		NodeID:   c.NodeID,
+		NodeName: c.NodeName,
+		Key:      c.Key,
+	}
+	if len(c.ParentGraphIDs) > 0 {
+		p.ParentGraphIDs = slices.Clone(c.ParentGraphIDs)
+	}
+	switch c.Op {
+	case goraff.ChangeOpNode:
+		p.Op = PatchNodeAdded
+	case goraff.ChangeOpAdd:
+		p.Op = PatchKeyAppended
+		p.Index = c.Index
+		p.Value = d.Redactor.Value(c.NodeName, c.Key, string(c.Value))
+	case goraff.ChangeOpSet:
+		p.Op = PatchKeySet
+		p.Value = d.Redactor.Value(c.NodeName, c.Key, string(c.Value))
+	case goraff.ChangeOpStatus:
+		p.Op = PatchStatusChanged
+		p.Status = string(c.Value)
+		if p.Status == string(goraff.NodeStatusFailed) {
+			p.Error = d.nodeErr(c.GraphID, c.NodeID)
+		}
+	case goraff.ChangeOpSubGraph:
+		p.Op = PatchSubGraphAttached
+		sub, err := d.graph.FindGraph(string(c.Value))
+		if err != nil {
+			return Message{}, false
+		}
+		p.SubGraph = d.outputter().Output(sub)
+	default:
+		return Message{}, false
+	}
+	return Message{Type: MessageTypePatch, Seq: c.Seq, Patch: p}, true
+}
+
+// GraphIDs returns the graphs the message is about: a snapshot's graph, or the patched
+// graph and the graphs it is nested in, so subscribers to any of them receive it
+func (m Message) GraphIDs() []string {
+	if m.Patch != nil {
+		return append(slices.Clone(m.Patch.ParentGraphIDs), m.Patch.GraphID)
+	}
+	if m.Snapshot != nil {
+		return []string{m.Snapshot.PrimaryStateID}
+	}
+	return nil
+}
+
+func (d *Differ) nodeErr(graphID, nodeID string) string {
+	g, err := d.graph.FindGraph(graphID)
+	if err != nil {
+		return ""
+	}
+	n, err := g.Node(nodeID)
+	if err != nil || n.Err() == nil {
+		return ""
+	}
+	return d.Redactor.String(n.Err().Error())
+}
+
+// Apply updates out with a patch message, as a client would.
+// Snapshot messages replace out entirely.
+func Apply(out *Output, m Message) error {
+	if m.Type == MessageTypeSnapshot {
+		if m.Snapshot == nil {
+			return fmt.Errorf("snapshot message %d has no snapshot", m.Seq)
+		}
+		*out = *m.Snapshot
+		return nil
+	}
+	p := m.Patch
+	if m.Type != MessageTypePatch || p == nil {
+		return fmt.Errorf("message %d is not a patch", m.Seq)
+	}
+	if p.Op == PatchNodeAdded {
+		addNode(out, p.GraphID, NodeOutput{
+			ID:          p.NodeID,
+			Name:        p.NodeName,
+			Status:      string(goraff.NodeStatusRunning),
+			Vals:        []NodeOutputVal{},
+			SubGraphIDs: []string{},
+		})
+		return nil
+	}
+	n := findNode(out, p.NodeID)
+	if n == nil {
+		return fmt.Errorf("patch %d is for unknown node %s", m.Seq, p.NodeID)
+	}
+	switch p.Op {
+	case PatchKeyAppended:
+		v := findVal(n, p.Key)
+		for len(v.Values) <= p.Index {
+			v.Values = append(v.Values, "")
+		}
+		v.Values[p.Index] = p.Value
+	case PatchKeySet:
+		findVal(n, p.Key).Values = []string{p.Value}
+	case PatchStatusChanged:
+		n.Status = p.Status
+		n.Error = p.Error
+	case PatchSubGraphAttached:
+		if p.SubGraph == nil {
+			return fmt.Errorf("patch %d has no sub-graph", m.Seq)
+		}
+		if !slices.Contains(n.SubGraphIDs, p.SubGraph.PrimaryStateID) {
+			n.SubGraphIDs = append(n.SubGraphIDs, p.SubGraph.PrimaryStateID)
+		}
+		for _, st := range p.SubGraph.States {
+			for _, id := range st.NodeIDs {
+				for _, sn := range p.SubGraph.Nodes {
+					if sn.ID == id {
+						addNode(out, st.ID, sn)
+					}
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("patch %d has unknown op %s", m.Seq, p.Op)
+	}
+	return nil
+}
+
+func addNode(out *Output, graphID string, n NodeOutput) {
+	if findNode(out, n.ID) != nil {
+		return
+	}
+	var st *GraphOutput
+	for i := range out.States {
+		if out.States[i].ID == graphID {
+			st = &out.States[i]
+		}
+	}
+	if st == nil {
+		out.States = append(out.States, GraphOutput{ID: graphID, NodeIDs: []string{}})
+		st = &out.States[len(out.States)-1]
+	}
+	st.NodeIDs = append(st.NodeIDs, n.ID)
+	out.Nodes = append(out.Nodes, n)
+}
+
+func findNode(out *Output, id string) *NodeOutput {
+	for i := range out.Nodes {
+		if out.Nodes[i].ID == id {
+			return &out.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func findVal(n *NodeOutput, key string) *NodeOutputVal {
+	for i := range n.Vals {
+		if n.Vals[i].Name == key {
+			return &n.Vals[i]
+		}
+	}
+	n.Vals = append(n.Vals, NodeOutputVal{Name: key, Values: []string{}})
+	return &n.Vals[len(n.Vals)-1]
+}
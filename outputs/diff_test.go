@@ -0,0 +1,168 @@
+package outputs_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+// normalise sorts an output so two outputs of the same graph compare equal
+func normalise(o *outputs.Output) *outputs.Output {
+	sort.Slice(o.States, func(i, j int) bool { return o.States[i].ID < o.States[j].ID })
+	sort.Slice(o.Nodes, func(i, j int) bool { return o.Nodes[i].ID < o.Nodes[j].ID })
+	for i := range o.Nodes {
+		sort.Slice(o.Nodes[i].Vals, func(a, b int) bool { return o.Nodes[i].Vals[a].Name < o.Nodes[i].Vals[b].Name })
+	}
+	return o
+}
+
+func TestDiffer_PatchesRebuildGraph(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	r := goraff.NewReadableGraph(g)
+
+	// Some state exists before the snapshot
+	n1 := g.NewNode("node1", nil)
+	n1.SetStr("key", "before")
+
+	sut := outputs.NewDiffer(r)
+	msgs := []outputs.Message{sut.Snapshot()}
+	notifier.Listen(func(c goraff.GraphChangeNotification) {
+		m, ok := sut.Patch(c)
+		assert.True(ok)
+		// messages must survive the wire
+		b, err := json.Marshal(m)
+		assert.NoError(err)
+		var decoded outputs.Message
+		assert.NoError(json.Unmarshal(b, &decoded))
+		msgs = append(msgs, decoded)
+	})
+
+	n1.SetStr("key", "after")
+	n1.AddStr("list", "a")
+	n1.AddStr("list", "b")
+	n1.MarkDone()
+	n2 := g.NewNode("node2", nil)
+	sub := &goraff.Graph{}
+	// sub-graphs may already have nodes when attached, as with FanOut
+	sub.NewNode("seed", nil).SetStr("result", "seeded")
+	n2.AddSubGraph(sub)
+	sn := sub.NewNode("subnode", nil)
+	sn.AddStr("result", "streamed")
+	sn.MarkFailed(fmt.Errorf("boom"))
+
+	got := &outputs.Output{}
+	for _, m := range msgs {
+		assert.NoError(outputs.Apply(got, m))
+	}
+	want := (&outputs.Outputter{}).Output(r)
+	assert.Equal(normalise(want), normalise(got))
+}
+
+func TestDiffer_PatchesAreIdempotent(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	r := goraff.NewReadableGraph(g)
+	sut := outputs.NewDiffer(r)
+	msgs := []outputs.Message{}
+	notifier.Listen(func(c goraff.GraphChangeNotification) {
+		m, _ := sut.Patch(c)
+		msgs = append(msgs, m)
+	})
+
+	n := g.NewNode("node1", nil)
+	n.AddStr("list", "a")
+	n.AddStr("list", "b")
+
+	// A snapshot taken after the changes, followed by the same changes again
+	got := &outputs.Output{}
+	assert.NoError(outputs.Apply(got, sut.Snapshot()))
+	for _, m := range msgs {
+		assert.NoError(outputs.Apply(got, m))
+	}
+	assert.Equal(normalise((&outputs.Outputter{}).Output(r)), normalise(got))
+}
+
+func TestDiffer_IgnoresOtherGraphs(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	sut := outputs.NewDiffer(goraff.NewReadableGraph(g))
+	_, ok := sut.Patch(goraff.GraphChangeNotification{GraphID: "other", Op: goraff.ChangeOpSet})
+	assert.False(ok)
+}
+
+func TestDiffer_PatchFormat(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	r := goraff.NewReadableGraph(g)
+	sut := outputs.NewDiffer(r)
+	m, ok := sut.Patch(goraff.GraphChangeNotification{
+		Seq:      7,
+		GraphID:  r.ID(),
+		NodeID:   "node-id",
+		NodeName: "node1",
+		Key:      "list",
+		Op:       goraff.ChangeOpAdd,
+		Index:    2,
+		Value:    []byte("c"),
+	})
+	assert.True(ok)
+	b, err := json.Marshal(m)
+	assert.NoError(err)
+	want := fmt.Sprintf(`{"type":"patch","seq":7,"patch":{"op":"key_appended","graph_id":"%s","node_id":"node-id","node_name":"node1","key":"list","index":2,"value":"c"}}`, r.ID())
+	assert.JSONEq(want, string(b))
+}
+
+func TestMessage_GraphIDs(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	r := goraff.NewReadableGraph(g)
+	sut := outputs.NewDiffer(r)
+	msgs := []outputs.Message{}
+	notifier.Listen(func(c goraff.GraphChangeNotification) {
+		m, ok := sut.Patch(c)
+		assert.True(ok)
+		msgs = append(msgs, m)
+	})
+
+	fan := g.NewNode("fanout", nil)
+	sub := &goraff.Graph{}
+	fan.AddSubGraph(sub)
+	inner := &goraff.Graph{}
+	sub.NewNode("branch", nil).AddSubGraph(inner)
+	inner.NewNode("leaf", nil)
+
+	subID := goraff.NewReadableGraph(sub).ID()
+	innerID := goraff.NewReadableGraph(inner).ID()
+	assert.Equal([]string{r.ID()}, sut.Snapshot().GraphIDs())
+	assert.Equal([]string{r.ID()}, msgs[0].GraphIDs())
+	last := msgs[len(msgs)-1]
+	assert.Equal([]string{r.ID(), subID}, last.Patch.ParentGraphIDs)
+	assert.Equal([]string{r.ID(), subID, innerID}, last.GraphIDs())
+}
+
+func TestApply_UnknownNode(t *testing.T) {
+	assert := assert.New(t)
+	err := outputs.Apply(&outputs.Output{}, outputs.Message{
+		Type:  outputs.MessageTypePatch,
+		Seq:   3,
+		Patch: &outputs.Patch{Op: outputs.PatchKeySet, NodeID: "missing"},
+	})
+	assert.EqualError(err, "patch 3 is for unknown node missing")
+}
+
+func TestDiffSchema(t *testing.T) {
+	assert := assert.New(t)
+	var schema map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(outputs.DiffSchema), &schema))
+	assert.Equal("goraff graph update message", schema["title"])
+}
@@ -0,0 +1,75 @@
+package outputs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lordtatty/goraff"
+)
+
+var dotStatusColours = map[goraff.NodeStatus]string{
+	goraff.NodeStatusRunning:   "#fff3cd",
+	goraff.NodeStatusDone:      "#d4edda",
+	goraff.NodeStatusFailed:    "#f8d7da",
+	goraff.NodeStatusSuspended: "#e2d9f3",
+}
+
+// ScaffDOT renders the blueprint of a scaff as a Graphviz DOT digraph.
+// Scaffs run by ScaffNode and FanOut blocks are drawn as nested clusters.
+func ScaffDOT(s *goraff.Scaff) string {
+	return scaffDiagram(s).dot()
+}
+
+// GraphDOT renders an executed graph as a Graphviz DOT digraph,
+// with nodes coloured by status and sub-graphs drawn as nested clusters.
+func GraphDOT(r *goraff.ReadableGraph) string {
+	return graphDiagram(r).dot()
+}
+
+func (d *diagram) dot() string {
+	b := &strings.Builder{}
+	b.WriteString("digraph goraff {\n")
+	b.WriteString("    node [shape=box, style=rounded];\n")
+	d.dotCluster(b, d.root, 1)
+	for _, e := range d.edges {
+		attrs := []string{}
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		if e.dotted {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(b, "    %s -> %s;\n", e.from, e.to)
+			continue
+		}
+		fmt.Fprintf(b, "    %s -> %s [%s];\n", e.from, e.to, strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (d *diagram) dotCluster(b *strings.Builder, c *cluster, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for _, n := range c.nodes {
+		attrs := []string{"label=" + dotQuote(n.label)}
+		if n.entrypoint {
+			attrs = append(attrs, "penwidth=3")
+		}
+		if colour, ok := dotStatusColours[n.status]; ok {
+			attrs = append(attrs, `style="rounded,filled"`, "fillcolor="+dotQuote(colour))
+		}
+		fmt.Fprintf(b, "%s%s [%s];\n", indent, n.id, strings.Join(attrs, ", "))
+	}
+	for _, sc := range c.clusters {
+		fmt.Fprintf(b, "%ssubgraph cluster_%s {\n", indent, sc.id)
+		fmt.Fprintf(b, "%s    label=%s;\n", indent, dotQuote(sc.label))
+		d.dotCluster(b, sc, depth+1)
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,258 @@
+package outputs
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/redact"
+)
+
+const defaultFoldAfter = 280
+
+// HTMLReport renders a run as a single HTML page with no external assets,
+// suitable for handing to someone who does not read code
+type HTMLReport struct {
+	Title string
+	// Scaff, when set, is drawn at the top of the report
+	Scaff *goraff.Scaff
+	// FoldAfter is the length after which values are folded away. Defaults to 280.
+	FoldAfter int
+	// Redactor, when set, hides sensitive values and scrubs error messages
+	Redactor *redact.Redactor
+}
+
+type reportData struct {
+	Title     string
+	Generated string
+	Diagram   template.HTML
+	Timeline  []timelineRow
+	Graph     reportGraph
+	Failed    int
+}
+
+type timelineRow struct {
+	Name     string
+	Indent   int
+	Status   string
+	Offset   float64
+	Width    float64
+	Duration string
+}
+
+type reportGraph struct {
+	ID    string
+	Nodes []reportNode
+}
+
+type reportNode struct {
+	ID        string
+	Name      string
+	Status    string
+	Error     string
+	Duration  string
+	Vals      []reportVal
+	SubGraphs []reportGraph
+}
+
+type reportVal struct {
+	Key    string
+	Values []reportValue
+}
+
+type reportValue struct {
+	Short  string
+	Full   string
+	Folded bool
+}
+
+// Render writes the report for the graph and all of its sub-graphs
+func (h *HTMLReport) Render(w io.Writer, r *goraff.ReadableGraph) error {
+	now := time.Now()
+	data := reportData{
+		Title:     h.Title,
+		Generated: now.Format(time.RFC1123),
+	}
+	if data.Title == "" {
+		data.Title = "goraff run " + r.ID()
+	}
+	if h.Scaff != nil {
+		data.Diagram = template.HTML(ScaffSVG(h.Scaff))
+	}
+	data.Timeline = timeline(r, now)
+	data.Graph = h.graph(r)
+	data.Failed = len(r.FindAll(func(n *goraff.ReadableNode) bool {
+		return n.Status() == goraff.NodeStatusFailed
+	}))
+	if err := reportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("error rendering report: %w", err)
+	}
+	return nil
+}
+
+func (h *HTMLReport) graph(r *goraff.ReadableGraph) reportGraph {
+	g := reportGraph{ID: r.ID()}
+	for _, n := range r.Nodes() {
+		rn := reportNode{
+			ID:       n.ID(),
+			Name:     n.Name(),
+			Status:   string(n.Status()),
+			Duration: duration(n),
+		}
+		if err := n.Err(); err != nil {
+			rn.Error = h.Redactor.String(err.Error())
+		}
+		for _, key := range sortedKeys(n) {
+			rv := reportVal{Key: key}
+			for _, v := range n.AllStr(key) {
+				rv.Values = append(rv.Values, h.value(h.Redactor.Value(n.Name(), key, v)))
+			}
+			rn.Vals = append(rn.Vals, rv)
+		}
+		for _, sub := range n.SubGraph() {
+			rn.SubGraphs = append(rn.SubGraphs, h.graph(sub))
+		}
+		g.Nodes = append(g.Nodes, rn)
+	}
+	return g
+}
+
+func (h *HTMLReport) value(v string) reportValue {
+	fold := h.FoldAfter
+	if fold <= 0 {
+		fold = defaultFoldAfter
+	}
+	if len([]rune(v)) <= fold {
+		return reportValue{Full: v}
+	}
+	return reportValue{Short: truncate(v, fold), Full: v, Folded: true}
+}
+
+func timeline(r *goraff.ReadableGraph, now time.Time) []timelineRow {
+	type entry struct {
+		node  *goraff.ReadableNode
+		depth int
+	}
+	entries := []entry{}
+	var walk func(g *goraff.ReadableGraph, depth int)
+	walk = func(g *goraff.ReadableGraph, depth int) {
+		for _, n := range g.Nodes() {
+			entries = append(entries, entry{node: n, depth: depth})
+			for _, sub := range n.SubGraph() {
+				walk(sub, depth+1)
+			}
+		}
+	}
+	walk(r, 0)
+	if len(entries) == 0 {
+		return nil
+	}
+	start, end := entries[0].node.StartedAt(), now
+	for _, e := range entries {
+		if e.node.StartedAt().Before(start) {
+			start = e.node.StartedAt()
+		}
+	}
+	total := end.Sub(start)
+	if total <= 0 {
+		total = time.Millisecond
+	}
+	rows := []timelineRow{}
+	for _, e := range entries {
+		finished := e.node.FinishedAt()
+		if finished.IsZero() {
+			finished = end
+		}
+		offset := float64(e.node.StartedAt().Sub(start)) / float64(total) * 100
+		width := float64(finished.Sub(e.node.StartedAt())) / float64(total) * 100
+		rows = append(rows, timelineRow{
+			Name:     e.node.Name(),
+			Indent:   e.depth,
+			Status:   string(e.node.Status()),
+			Offset:   offset,
+			Width:    max(width, 0.5),
+			Duration: duration(e.node),
+		})
+	}
+	return rows
+}
+
+func sortedKeys(n *goraff.ReadableNode) []string {
+	keys := n.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+func duration(n *goraff.ReadableNode) string {
+	if n.FinishedAt().IsZero() {
+		return "running"
+	}
+	return n.FinishedAt().Sub(n.StartedAt()).Round(time.Millisecond).String()
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #666; margin-bottom: 1.5em; }
+section { margin-bottom: 2em; }
+.diagram { overflow-x: auto; border: 1px solid #ddd; padding: 1em; }
+.timeline { width: 100%; border-collapse: collapse; }
+.timeline td { padding: 2px 6px; font-size: 13px; white-space: nowrap; }
+.timeline .track { width: 70%; position: relative; background: #f4f4f4; }
+.bar { position: relative; height: 14px; border-radius: 3px; }
+.running .bar, .bar.running { background: #e0b50f; }
+.done .bar, .bar.done { background: #28a745; }
+.failed .bar, .bar.failed { background: #dc3545; }
+.suspended .bar, .bar.suspended { background: #6f42c1; }
+.node { border: 1px solid #ccc; border-left: 6px solid #999; border-radius: 4px; padding: 0.5em 1em; margin: 0.5em 0; }
+.node.done { border-left-color: #28a745; }
+.node.running { border-left-color: #e0b50f; }
+.node.failed { border-left-color: #dc3545; background: #fdf0f1; }
+.node.suspended { border-left-color: #6f42c1; }
+.status { font-size: 12px; padding: 1px 6px; border-radius: 8px; background: #eee; }
+.error { color: #a71d2a; font-weight: bold; white-space: pre-wrap; }
+.key { font-weight: bold; margin-top: 0.5em; }
+pre { white-space: pre-wrap; word-break: break-word; background: #f8f8f8; padding: 0.5em; margin: 0.25em 0; }
+details.subgraph { margin-left: 1.5em; }
+summary { cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">Generated {{.Generated}}{{if .Failed}} &middot; <span class="error">{{.Failed}} failed</span>{{end}}</div>
+{{if .Diagram}}<section><h2>Scaff</h2><div class="diagram">{{.Diagram}}</div></section>{{end}}
+<section>
+<h2>Timeline</h2>
+<table class="timeline">
+{{range .Timeline}}<tr class="{{.Status}}">
+<td style="padding-left: {{.Indent}}.5em">{{.Name}}</td>
+<td>{{.Duration}}</td>
+<td class="track"><div class="bar" style="left: {{printf "%.2f" .Offset}}%; width: {{printf "%.2f" .Width}}%"></div></td>
+</tr>
+{{end}}</table>
+</section>
+<section>
+<h2>Nodes</h2>
+{{template "graph" .Graph}}
+</section>
+</body>
+</html>
+{{define "graph"}}{{range .Nodes}}<div class="node {{.Status}}" id="node-{{.ID}}">
+<div><strong>{{.Name}}</strong> <span class="status">{{.Status}}</span> <small>{{.Duration}}</small></div>
+{{if .Error}}<div class="error">{{.Error}}</div>{{end}}
+{{range .Vals}}<div class="key">{{.Key}}</div>
+{{range .Values}}{{if .Folded}}<details><summary><pre>{{.Short}}</pre></summary><pre>{{.Full}}</pre></details>{{else}}<pre>{{.Full}}</pre>{{end}}
+{{end}}{{end}}
+{{range $i, $g := .SubGraphs}}<details class="subgraph"><summary>Sub-graph {{$i}} ({{len $g.Nodes}} nodes)</summary>
+{{template "graph" $g}}
+</details>
+{{end}}</div>
+{{end}}{{end}}`))
@@ -0,0 +1,68 @@
+package outputs_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLReport_Render(t *testing.T) {
+	assert := assert.New(t)
+	g := testGraph()
+	long := strings.Repeat("word ", 100)
+	g.FirstNodeByName("input").SetStr("result", long)
+	g.FirstNodeByName("input").SetStr("script", "<script>alert(1)</script>")
+	r := goraff.NewReadableGraph(g)
+
+	sut := &outputs.HTMLReport{Title: "My run", Scaff: testScaff(), FoldAfter: 50}
+	b := &bytes.Buffer{}
+	err := sut.Render(b, r)
+	assert.NoError(err)
+	page := b.String()
+
+	assert.Contains(page, "<title>My run</title>")
+	// the scaff diagram is inline
+	assert.Contains(page, "<svg")
+	assert.Contains(page, ">fanout</text>")
+	// timeline rows for every node, including sub-graph nodes
+	assert.Equal(3, strings.Count(page, `<td class="track">`))
+	// sub-graphs are collapsible
+	assert.Contains(page, `<details class="subgraph"><summary>Sub-graph 0 (1 nodes)</summary>`)
+	// long values are folded
+	assert.Contains(page, "<details><summary><pre>"+strings.Repeat("word ", 9)+"word…</pre></summary>")
+	// errors are highlighted
+	assert.Contains(page, `<div class="error">boom</div>`)
+	assert.Contains(page, `<span class="error">1 failed</span>`)
+	// values are escaped
+	assert.Contains(page, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.NotContains(page, "<script>")
+	// no external assets
+	assert.NotContains(page, "src=")
+	assert.NotContains(page, "<link")
+}
+
+func TestHTMLReport_DefaultTitle(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	g.NewNode("node1", nil).MarkFailed(fmt.Errorf("boom"))
+	r := goraff.NewReadableGraph(g)
+	b := &bytes.Buffer{}
+	assert.NoError((&outputs.HTMLReport{}).Render(b, r))
+	assert.Contains(b.String(), "<title>goraff run "+r.ID()+"</title>")
+	assert.NotContains(b.String(), "<svg")
+}
+
+func TestScaffSVG(t *testing.T) {
+	assert := assert.New(t)
+	svg := outputs.ScaffSVG(testScaff())
+	assert.True(strings.HasPrefix(svg, "<svg "))
+	assert.Equal(4, strings.Count(svg, "<rect "))
+	assert.Equal(3, strings.Count(svg, "<line "))
+	// nested scaff blocks are dashed
+	assert.Contains(svg, "<title>fanout (*blockactions.FanOut) / reverse</title>")
+}
@@ -0,0 +1,67 @@
+package outputs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lordtatty/goraff"
+)
+
+// ScaffMermaid renders the blueprint of a scaff as a Mermaid flowchart.
+// Scaffs run by ScaffNode and FanOut blocks are drawn as nested subgraphs.
+func ScaffMermaid(s *goraff.Scaff) string {
+	return scaffDiagram(s).mermaid()
+}
+
+// GraphMermaid renders an executed graph as a Mermaid flowchart,
+// with nodes coloured by status and sub-graphs drawn as nested subgraphs.
+func GraphMermaid(r *goraff.ReadableGraph) string {
+	return graphDiagram(r).mermaid()
+}
+
+func (d *diagram) mermaid() string {
+	b := &strings.Builder{}
+	b.WriteString("flowchart TD\n")
+	b.WriteString("    classDef entrypoint stroke-width:3px\n")
+	b.WriteString("    classDef running fill:#fff3cd,stroke:#d4a106\n")
+	b.WriteString("    classDef done fill:#d4edda,stroke:#28a745\n")
+	b.WriteString("    classDef failed fill:#f8d7da,stroke:#dc3545\n")
+	b.WriteString("    classDef suspended fill:#e2d9f3,stroke:#6f42c1\n")
+	d.mermaidCluster(b, d.root, 1)
+	for _, e := range d.edges {
+		arrow := "-->"
+		if e.dotted {
+			arrow = "-.->"
+		}
+		if e.label != "" {
+			fmt.Fprintf(b, "    %s %s|%s| %s\n", e.from, arrow, mermaidLabel(e.label), e.to)
+			continue
+		}
+		fmt.Fprintf(b, "    %s %s %s\n", e.from, arrow, e.to)
+	}
+	return b.String()
+}
+
+func (d *diagram) mermaidCluster(b *strings.Builder, c *cluster, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for _, n := range c.nodes {
+		fmt.Fprintf(b, "%s%s[%s]\n", indent, n.id, mermaidLabel(n.label))
+		if n.entrypoint {
+			fmt.Fprintf(b, "%sclass %s entrypoint\n", indent, n.id)
+		}
+		if n.status != "" {
+			fmt.Fprintf(b, "%sclass %s %s\n", indent, n.id, n.status)
+		}
+	}
+	for _, sc := range c.clusters {
+		fmt.Fprintf(b, "%ssubgraph %s[%s]\n", indent, sc.id, mermaidLabel(sc.label))
+		d.mermaidCluster(b, sc, depth+1)
+		fmt.Fprintf(b, "%send\n", indent)
+	}
+}
+
+// mermaidLabel quotes a label, escaping characters Mermaid would otherwise parse
+func mermaidLabel(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "\n", " ")
+	return `"` + r.Replace(s) + `"`
+}
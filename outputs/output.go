@@ -5,12 +5,15 @@ import (
 	"fmt"
 
 	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/redact"
 	"github.com/lordtatty/goraff/websocket"
 )
 
 type NodeOutput struct {
 	ID          string          `json:"id"`
 	Name        string          `json:"name"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
 	Vals        []NodeOutputVal `json:"vals"`
 	SubGraphIDs []string        `json:"subgraph_ids"`
 }
@@ -32,6 +35,8 @@ type GraphOutput struct {
 }
 
 type Outputter struct {
+	// Redactor, when set, hides sensitive values in the output
+	Redactor *redact.Redactor
 }
 
 func (o *Outputter) Output(s *goraff.ReadableGraph) *Output {
@@ -102,7 +107,7 @@ func (o *Outputter) allNodes(s *goraff.ReadableGraph) ([]NodeOutput, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error getting node state: %w", err)
 		}
-		nodes = append(nodes, *o.node(n))
+		nodes = append(nodes, *o.Node(n))
 		if n.SubGraph() == nil {
 			continue
 		}
@@ -117,48 +122,79 @@ func (o *Outputter) allNodes(s *goraff.ReadableGraph) ([]NodeOutput, error) {
 	return nodes, nil
 }
 
-func (o *Outputter) node(ns *goraff.ReadableNode) *NodeOutput {
+// Node returns the output for a single node
+func (o *Outputter) Node(ns *goraff.ReadableNode) *NodeOutput {
 	vals := []NodeOutputVal{}
 	for _, key := range ns.Keys() {
+		values := []string{}
+		for _, v := range ns.AllStr(key) {
+			values = append(values, o.Redactor.Value(ns.Name(), key, v))
+		}
 		vals = append(vals, NodeOutputVal{
 			Name:   key,
-			Values: ns.AllStr(key),
+			Values: values,
 		})
 	}
 	subIDs := []string{}
 	for _, ns := range ns.SubGraph() {
 		subIDs = append(subIDs, ns.ID())
 	}
-	return &NodeOutput{
+	out := &NodeOutput{
 		ID:          ns.ID(),
 		Name:        ns.Name(),
+		Status:      string(ns.Status()),
 		Vals:        vals,
 		SubGraphIDs: subIDs,
 	}
+	if err := ns.Err(); err != nil {
+		out.Error = o.Redactor.String(err.Error())
+	}
+	return out
 }
 
 type ChangeListener interface {
 	Listen(func(goraff.GraphChangeNotification))
 }
 
-// TODO - test this
-func BroadcastChanges(l ChangeListener, r *goraff.ReadableGraph, ws *websocket.WebSocketServer) {
-	l.Listen(func(c goraff.GraphChangeNotification) {
-		out := Outputter{}
-		o := out.Output(r)
-		snd, err := json.Marshal(o)
+// BroadcastChanges sends a patch message for every change to the websocket's clients.
+// Clients are sent a snapshot when they connect, or the patches they missed when they
+// reconnect with ?since=<seq>. See DiffSchema for the message format.
+func BroadcastChanges(l ChangeListener, r *goraff.ReadableGraph, ws *websocket.WebSocketServer) *Stream {
+	return BroadcastDiffs(l, NewDiffer(r), ws)
+}
+
+// BroadcastDiffs is BroadcastChanges for a Differ that has already been set up, such as one with a Redactor
+func BroadcastDiffs(l ChangeListener, d *Differ, ws *websocket.WebSocketServer) *Stream {
+	st := NewStream(d, 0)
+	BroadcastStream(l, st, ws)
+	return st
+}
+
+// BroadcastStream records every change in the stream and sends it on to the websocket's clients
+// that have subscribed to the graph it changes or one it is nested in, or that have no subscriptions.
+// The stream becomes the websocket's Source.
+func BroadcastStream(l ChangeListener, st *Stream, ws *websocket.WebSocketServer) {
+	ws.Source = st
+	st.Listen(func(m Message) {
+		snd, err := json.Marshal(m)
 		if err != nil {
 			fmt.Println("error marshalling state")
 			return
 		}
-		ws.Send(string(snd))
+		ws.SendGraphs(m.GraphIDs(), string(snd))
 	})
+	l.Listen(st.Notify)
 }
 
 // TODO - test this
 func PrintUpdatesToConsole(l ChangeListener, r *goraff.ReadableGraph) {
+	out := &Outputter{}
+	out.PrintUpdatesToConsole(l, r)
+}
+
+// PrintUpdatesToConsole prints the whole graph on every change
+func (out *Outputter) PrintUpdatesToConsole(l ChangeListener, r *goraff.ReadableGraph) {
 	l.Listen(func(c goraff.GraphChangeNotification) {
-		out := Outputter{}
 		o := out.Output(r)
 		fmt.Println("##########################################")
 		fmt.Println("##########################################")
@@ -0,0 +1,67 @@
+package outputs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/keys"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/redact"
+	"github.com/stretchr/testify/assert"
+)
+
+// Every exporter must hide the same values
+func TestRedaction(t *testing.T) {
+	assert := assert.New(t)
+	red := redact.New("api_key")
+	red.SensitiveBlocks = []string{"vault"}
+
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	r := goraff.NewReadableGraph(g)
+	in := g.NewNode("input", nil)
+	in.SetStr("api_key", "sk-live-secret-value-1234")
+
+	d := outputs.NewDiffer(r)
+	d.Redactor = red
+	msgs := []outputs.Message{d.Snapshot()}
+	notifier.Listen(func(c goraff.GraphChangeNotification) {
+		if m, ok := d.Patch(c); ok {
+			msgs = append(msgs, m)
+		}
+	})
+	g.NewNode("vault", nil).SetStr("result", "the combination")
+	ask := llmNode(g, "ask", "", "email me at jo@example.com", "will do, jo@example.com")
+	ask.MarkFailed(fmt.Errorf("rejected key sk-abcdefghijklmnopqrstu"))
+
+	secrets := []string{"sk-live-secret-value-1234", "the combination", "jo@example.com", "sk-abcdefghijklmnopqrstu"}
+	check := func(name, s string) {
+		for _, secret := range secrets {
+			assert.NotContains(s, secret, name)
+		}
+		assert.Contains(s, "[REDACTED]", name)
+	}
+
+	o := &outputs.Outputter{Redactor: red}
+	b, err := json.Marshal(o.Output(r))
+	assert.NoError(err)
+	check("outputter", string(b))
+
+	b, err = json.Marshal(msgs)
+	assert.NoError(err)
+	check("diff", string(b))
+
+	html := &bytes.Buffer{}
+	assert.NoError((&outputs.HTMLReport{Redactor: red}).Render(html, r))
+	check("html", html.String())
+
+	check("transcript", outputs.RedactedTranscript(r, red))
+
+	// nothing is hidden without a redactor
+	assert.Contains(outputs.Transcript(r), "jo@example.com")
+	assert.Equal("email me at jo@example.com", ask.Get().FirstStr(keys.LLMUserMsg))
+}
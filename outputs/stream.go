@@ -0,0 +1,149 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/lordtatty/goraff"
+)
+
+const defaultStreamSize = 1024
+
+// Stream keeps the most recent patches for a graph so clients that connect late,
+// or reconnect after a blip, can be brought up to date
+type Stream struct {
+	// OnError, when set, is called with messages Replay cannot encode, which it leaves out
+	OnError func(err error)
+	differ  *Differ
+	size    int
+
+	mu        sync.Mutex
+	buf       []Message
+	evictedTo uint64
+	listeners []func(Message)
+	subs      map[chan Message]struct{}
+}
+
+// NewStream buffers up to size patches from the differ's graph. A size of zero buffers 1024.
+func NewStream(d *Differ, size int) *Stream {
+	if size <= 0 {
+		size = defaultStreamSize
+	}
+	// changes made before the stream existed are only available in snapshots
+	return &Stream{differ: d, size: size, evictedTo: goraff.LastSeq()}
+}
+
+// GraphID returns the ID of the graph the stream follows
+func (s *Stream) GraphID() string {
+	return s.differ.graph.ID()
+}
+
+// Contains reports whether id is the stream's graph or a sub-graph nested in it
+func (s *Stream) Contains(id string) bool {
+	_, err := s.differ.graph.FindGraph(id)
+	return err == nil
+}
+
+// Listen registers a callback for every patch the stream records.
+// Callbacks are called in the order patches are recorded and must not call back into the stream.
+func (s *Stream) Listen(callback func(Message)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, callback)
+}
+
+// Notify records the change as a patch, if it belongs to the stream's graph
+func (s *Stream) Notify(c goraff.GraphChangeNotification) {
+	m, ok := s.differ.Patch(c)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(s.buf, m)
+	if over := len(s.buf) - s.size; over > 0 {
+		for _, old := range s.buf[:over] {
+			s.evictedTo = max(s.evictedTo, old.Seq)
+		}
+		s.buf = append([]Message{}, s.buf[over:]...)
+	}
+	for _, l := range s.listeners {
+		l(m)
+	}
+	for ch := range s.subs {
+		select {
+		case ch <- m:
+		default:
+			// a subscriber that falls behind is cut off and can resume from its last seq
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the messages since the given seq, as Since does, and a channel of
+// every patch recorded after them. The channel holds up to buffer messages and is closed
+// if the subscriber falls further behind than that. Call cancel when done.
+func (s *Stream) Subscribe(since uint64, buffer int) (backlog []Message, ch <-chan Message, cancel func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := make(chan Message, max(buffer, 1))
+	if s.subs == nil {
+		s.subs = map[chan Message]struct{}{}
+	}
+	s.subs[c] = struct{}{}
+	cancel = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[c]; ok {
+			delete(s.subs, c)
+			close(c)
+		}
+	}
+	return s.since(since), c, cancel
+}
+
+// Since returns the messages that bring a client that has seen every change up to since
+// up to date. That is the buffered patches after since when the buffer still holds them all,
+// or otherwise a fresh snapshot followed by any patches newer than it.
+// A since of zero always starts with a snapshot.
+func (s *Stream) Since(since uint64) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.since(since)
+}
+
+func (s *Stream) since(since uint64) []Message {
+	if since > 0 && since >= s.evictedTo && since <= goraff.LastSeq() {
+		return s.after(since)
+	}
+	snap := s.differ.Snapshot()
+	return append([]Message{snap}, s.after(snap.Seq)...)
+}
+
+func (s *Stream) after(seq uint64) []Message {
+	result := []Message{}
+	for _, m := range s.buf {
+		if m.Seq > seq {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// Replay is Since encoded as JSON, for websocket.WebSocketServer.Source
+func (s *Stream) Replay(since uint64) []string {
+	result := []string{}
+	for _, m := range s.Since(since) {
+		b, err := json.Marshal(m)
+		if err != nil {
+			if s.OnError != nil {
+				s.OnError(fmt.Errorf("error marshalling message %d: %w", m.Seq, err))
+			}
+			continue
+		}
+		result = append(result, string(b))
+	}
+	return result
+}
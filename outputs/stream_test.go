@@ -0,0 +1,151 @@
+package outputs_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_Since(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	n := g.NewNode("node1", nil)
+	n.SetStr("key", "before")
+
+	sut := outputs.NewStream(outputs.NewDiffer(goraff.NewReadableGraph(g)), 3)
+	notifier.Listen(sut.Notify)
+	recorded := []outputs.Message{}
+	sut.Listen(func(m outputs.Message) { recorded = append(recorded, m) })
+
+	// nothing seen yet: snapshot only
+	msgs := sut.Since(0)
+	assert.Len(msgs, 1)
+	assert.Equal(outputs.MessageTypeSnapshot, msgs[0].Type)
+	base := msgs[0].Seq
+
+	n.SetStr("key", "one")
+	n.SetStr("key", "two")
+	assert.Len(recorded, 2)
+
+	// resuming within the buffer gets only what was missed
+	msgs = sut.Since(base)
+	assert.Len(msgs, 2)
+	assert.Equal("one", msgs[0].Patch.Value)
+	msgs = sut.Since(recorded[0].Seq)
+	assert.Len(msgs, 1)
+	assert.Equal("two", msgs[0].Patch.Value)
+	assert.Empty(sut.Since(recorded[1].Seq))
+
+	// resuming from before the stream started needs a snapshot
+	msgs = sut.Since(base - 1)
+	assert.Equal(outputs.MessageTypeSnapshot, msgs[0].Type)
+
+	// once patches are evicted, older positions get a snapshot
+	n.SetStr("key", "three")
+	n.SetStr("key", "four")
+	msgs = sut.Since(base)
+	assert.Equal(outputs.MessageTypeSnapshot, msgs[0].Type)
+	assert.Equal("four", msgs[0].Snapshot.Nodes[0].Vals[0].Values[0])
+	assert.Len(sut.Since(recorded[1].Seq), 2)
+
+	// positions from the future, such as from before a restart, get a snapshot
+	msgs = sut.Since(goraff.LastSeq() + 100)
+	assert.Equal(outputs.MessageTypeSnapshot, msgs[0].Type)
+}
+
+func readMessage(t *testing.T, conn *gws.Conn) outputs.Message {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m outputs.Message
+	require.NoError(t, conn.ReadJSON(&m))
+	return m
+}
+
+func TestBroadcastChanges_ReplayOnConnect(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	r := goraff.NewReadableGraph(g)
+	ws := websocket.NewWebSocketServer("")
+	outputs.BroadcastChanges(notifier, r, ws)
+	srv := httptest.NewServer(ws.Handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	n := g.NewNode("node1", nil)
+	n.SetStr("key", "mid-run")
+
+	// joining mid-run starts with the current state
+	conn, _, err := gws.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	snap := readMessage(t, conn)
+	assert.Equal(outputs.MessageTypeSnapshot, snap.Type)
+	assert.Equal("mid-run", snap.Snapshot.Nodes[0].Vals[0].Values[0])
+
+	n.SetStr("key", "seen")
+	seen := readMessage(t, conn)
+	assert.Equal("seen", seen.Patch.Value)
+	conn.Close()
+	assert.Eventually(func() bool { return ws.Clients() == 0 }, time.Second, 5*time.Millisecond)
+
+	// changes while disconnected are replayed on reconnect
+	n.SetStr("key", "missed")
+	n.MarkDone()
+	conn, _, err = gws.DefaultDialer.Dial(url+"?since="+strconv.FormatUint(seen.Seq, 10), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	out := snap.Snapshot
+	for _, want := range []string{outputs.MessageTypePatch, outputs.MessageTypePatch} {
+		m := readMessage(t, conn)
+		assert.Equal(want, m.Type)
+		assert.NoError(outputs.Apply(out, m))
+	}
+	b, _ := json.Marshal(out)
+	assert.Contains(string(b), `"values":["missed"]`)
+	assert.Equal("done", out.Nodes[0].Status)
+}
+
+func TestStream_Subscribe(t *testing.T) {
+	assert := assert.New(t)
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	n := g.NewNode("node1", nil)
+	sut := outputs.NewStream(outputs.NewDiffer(goraff.NewReadableGraph(g)), 0)
+	notifier.Listen(sut.Notify)
+
+	backlog, ch, cancel := sut.Subscribe(0, 2)
+	assert.Len(backlog, 1)
+	assert.Equal(outputs.MessageTypeSnapshot, backlog[0].Type)
+
+	n.SetStr("key", "one")
+	m := <-ch
+	assert.Equal("one", m.Patch.Value)
+
+	// a subscriber that falls behind is closed
+	n.SetStr("key", "two")
+	n.SetStr("key", "three")
+	n.SetStr("key", "four")
+	assert.Equal("two", (<-ch).Patch.Value)
+	assert.Equal("three", (<-ch).Patch.Value)
+	_, ok := <-ch
+	assert.False(ok)
+	cancel()
+
+	// resuming picks up where it left off
+	backlog, _, cancel = sut.Subscribe(m.Seq, 2)
+	defer cancel()
+	assert.Len(backlog, 3)
+	assert.Equal("four", backlog[2].Patch.Value)
+}
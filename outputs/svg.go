@@ -0,0 +1,143 @@
+package outputs
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/lordtatty/goraff"
+)
+
+const (
+	svgNodeWidth  = 150
+	svgNodeHeight = 36
+	svgGapX       = 30
+	svgGapY       = 50
+	svgMargin     = 20
+	svgLabelChars = 20
+)
+
+// ScaffSVG renders the blueprint of a scaff as a standalone SVG image.
+// Blocks from nested scaffs are drawn with dashed borders.
+func ScaffSVG(s *goraff.Scaff) string {
+	return scaffDiagram(s).svg()
+}
+
+type svgNode struct {
+	*diagramNode
+	cluster string
+	level   int
+	x, y    int
+}
+
+func (d *diagram) svg() string {
+	nodes, byID := d.svgLayout()
+	width, height := svgMargin*2, svgMargin*2
+	for _, n := range nodes {
+		width = max(width, n.x+svgNodeWidth+svgMargin)
+		height = max(height, n.y+svgNodeHeight+svgMargin)
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`, width, height, width, height)
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#555"/></marker></defs>`)
+	for _, e := range d.edges {
+		from, to := byID[e.from], byID[e.to]
+		if from == nil || to == nil {
+			continue
+		}
+		x1, y1 := from.x+svgNodeWidth/2, from.y+svgNodeHeight
+		x2, y2 := to.x+svgNodeWidth/2, to.y
+		dash := ""
+		if e.dotted {
+			dash = ` stroke-dasharray="4 3"`
+		}
+		fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#555"%s marker-end="url(#arrow)"/>`, x1, y1, x2, y2, dash)
+		if e.label != "" {
+			fmt.Fprintf(b, `<text x="%d" y="%d" fill="#333" font-size="10"><title>%s</title>%s</text>`, (x1+x2)/2+4, (y1+y2)/2, html.EscapeString(e.label), html.EscapeString(truncate(e.label, svgLabelChars*2)))
+		}
+	}
+	for _, n := range nodes {
+		fill := "#ffffff"
+		if colour, ok := dotStatusColours[n.status]; ok {
+			fill = colour
+		}
+		stroke := `stroke="#333"`
+		if n.entrypoint {
+			stroke += ` stroke-width="3"`
+		}
+		if n.cluster != "" {
+			stroke += ` stroke-dasharray="5 3"`
+		}
+		title := n.label
+		if n.cluster != "" {
+			title = n.cluster + " / " + n.label
+		}
+		fmt.Fprintf(b, `<g><title>%s</title><rect x="%d" y="%d" width="%d" height="%d" rx="6" fill="%s" %s/>`, html.EscapeString(title), n.x, n.y, svgNodeWidth, svgNodeHeight, fill, stroke)
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle">%s</text></g>`, n.x+svgNodeWidth/2, n.y+svgNodeHeight/2, html.EscapeString(truncate(n.label, svgLabelChars)))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// svgLayout places nodes in layers so that edges point downwards where possible
+func (d *diagram) svgLayout() ([]*svgNode, map[string]*svgNode) {
+	nodes := []*svgNode{}
+	var collect func(c *cluster, label string)
+	collect = func(c *cluster, label string) {
+		for _, n := range c.nodes {
+			nodes = append(nodes, &svgNode{diagramNode: n, cluster: label})
+		}
+		for _, sc := range c.clusters {
+			collect(sc, sc.label)
+		}
+	}
+	collect(d.root, "")
+	byID := map[string]*svgNode{}
+	for _, n := range nodes {
+		byID[n.id] = n
+	}
+
+	// longest path layering, ignoring edges that would form cycles
+	indegree := map[string]int{}
+	out := map[string][]string{}
+	for _, e := range d.edges {
+		if byID[e.from] == nil || byID[e.to] == nil {
+			continue
+		}
+		indegree[e.to]++
+		out[e.from] = append(out[e.from], e.to)
+	}
+	queue := []string{}
+	for _, n := range nodes {
+		if indegree[n.id] == 0 {
+			queue = append(queue, n.id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, to := range out[id] {
+			byID[to].level = max(byID[to].level, byID[id].level+1)
+			indegree[to]--
+			if indegree[to] == 0 {
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	perLevel := map[int]int{}
+	for _, n := range nodes {
+		n.x = svgMargin + perLevel[n.level]*(svgNodeWidth+svgGapX)
+		n.y = svgMargin + n.level*(svgNodeHeight+svgGapY)
+		perLevel[n.level]++
+	}
+	return nodes, byID
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,87 @@
+package outputs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/keys"
+	"github.com/lordtatty/goraff/redact"
+)
+
+type transcriptGroup struct {
+	title     string
+	exchanges []*goraff.ReadableNode
+}
+
+// Transcript renders every LLM exchange in a run as Markdown.
+// Exchanges are grouped by the graph they ran in, and ordered by when they started.
+func Transcript(r *goraff.ReadableGraph) string {
+	return RedactedTranscript(r, nil)
+}
+
+// RedactedTranscript is Transcript with sensitive values hidden by red
+func RedactedTranscript(r *goraff.ReadableGraph, red *redact.Redactor) string {
+	groups := []*transcriptGroup{}
+	var walk func(g *goraff.ReadableGraph, title string)
+	walk = func(g *goraff.ReadableGraph, title string) {
+		group := &transcriptGroup{title: title}
+		for _, n := range g.Nodes() {
+			if len(n.All(keys.LLMUserMsg)) > 0 {
+				group.exchanges = append(group.exchanges, n)
+			}
+		}
+		if len(group.exchanges) > 0 {
+			sort.SliceStable(group.exchanges, func(i, j int) bool {
+				return group.exchanges[i].StartedAt().Before(group.exchanges[j].StartedAt())
+			})
+			groups = append(groups, group)
+		}
+		for _, n := range g.Nodes() {
+			for i, sub := range n.SubGraph() {
+				walk(sub, fmt.Sprintf("%s / %s #%d", title, n.Name(), i))
+			}
+		}
+	}
+	walk(r, "Run")
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].exchanges[0].StartedAt().Before(groups[j].exchanges[0].StartedAt())
+	})
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# LLM transcript for run %s\n", r.ID())
+	if len(groups) == 0 {
+		b.WriteString("\nNo LLM exchanges were recorded.\n")
+	}
+	for _, g := range groups {
+		fmt.Fprintf(b, "\n## %s\n", g.title)
+		for _, n := range g.exchanges {
+			fmt.Fprintf(b, "\n### %s\n\n", n.Name())
+			fmt.Fprintf(b, "Node `%s`, started %s, %s", n.ID(), n.StartedAt().Format(time.RFC3339), duration(n))
+			if err := n.Err(); err != nil {
+				fmt.Fprintf(b, ", **failed: %s**", red.String(err.Error()))
+			}
+			b.WriteString("\n")
+			value := func(key string) string {
+				return red.Value(n.Name(), key, n.FirstStr(key))
+			}
+			if n.FirstStr(keys.LLMSystemMsg) != "" {
+				writeMarkdownBlock(b, "System", value(keys.LLMSystemMsg))
+			}
+			writeMarkdownBlock(b, "User", value(keys.LLMUserMsg))
+			writeMarkdownBlock(b, "Response", value(keys.Result))
+		}
+	}
+	return b.String()
+}
+
+// writeMarkdownBlock writes a fenced block, with a fence longer than any backtick run in the text
+func writeMarkdownBlock(b *strings.Builder, heading, text string) {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(b, "\n**%s**\n\n%s\n%s\n%s\n", heading, fence, text, fence)
+}
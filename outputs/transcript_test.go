@@ -0,0 +1,64 @@
+package outputs_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/keys"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+func llmNode(g *goraff.Graph, name, system, user, response string) *goraff.Node {
+	n := g.NewNode(name, nil)
+	if system != "" {
+		n.SetStr(keys.LLMSystemMsg, system)
+	}
+	n.SetStr(keys.LLMUserMsg, user)
+	n.SetStr(keys.Result, response)
+	n.MarkDone()
+	return n
+}
+
+func TestTranscript(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	g.NewNode("input", nil).SetStr("result", "not an llm")
+	first := llmNode(g, "draft", "be brief", "write a poem", "roses are red")
+	time.Sleep(time.Millisecond)
+	fan := g.NewNode("fanout", nil)
+	sub := &goraff.Graph{}
+	fan.AddSubGraph(sub)
+	llmNode(sub, "review", "", "review ```this```", "looks good")
+	time.Sleep(time.Millisecond)
+	last := llmNode(g, "final", "", "finish", "done")
+	last.MarkFailed(fmt.Errorf("too slow"))
+	r := goraff.NewReadableGraph(g)
+
+	got := outputs.Transcript(r)
+
+	assert.Contains(got, "# LLM transcript for run "+r.ID()+"\n")
+	assert.Contains(got, "\n### draft\n\nNode `"+first.Get().ID()+"`, started ")
+	assert.Contains(got, "\n**System**\n\n```\nbe brief\n```\n")
+	assert.Contains(got, "\n**User**\n\n```\nwrite a poem\n```\n")
+	assert.Contains(got, "\n**Response**\n\n```\nroses are red\n```\n")
+	// fences are longer than any backticks in the text
+	assert.Contains(got, "\n````\nreview ```this```\n````\n")
+	assert.Contains(got, "**failed: too slow**")
+	assert.NotContains(got, "input")
+
+	// root exchanges come first, grouped, then the sub-graph group
+	assert.Less(strings.Index(got, "## Run\n"), strings.Index(got, "### draft"))
+	assert.Less(strings.Index(got, "### draft"), strings.Index(got, "### final"))
+	assert.Less(strings.Index(got, "### final"), strings.Index(got, "## Run / fanout #0\n"))
+	assert.Less(strings.Index(got, "## Run / fanout #0\n"), strings.Index(got, "### review"))
+}
+
+func TestTranscript_Empty(t *testing.T) {
+	assert := assert.New(t)
+	r := goraff.NewReadableGraph(&goraff.Graph{})
+	assert.Contains(outputs.Transcript(r), "No LLM exchanges were recorded.")
+}
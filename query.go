@@ -0,0 +1,170 @@
+package goraff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathWildcard matches any node name or any sub-graph in a path query
+const PathWildcard = "*"
+
+// Nodes returns every node in the graph in the order they were created
+func (s *ReadableGraph) Nodes() []*ReadableNode {
+	return readable(s.graph.allNodes())
+}
+
+// NodesByName returns every node with the given name in the order they were created
+func (s *ReadableGraph) NodesByName(name string) []*ReadableNode {
+	return readable(s.graph.NodeByName(name))
+}
+
+// LatestNodeByName returns the most recently created node with the given name
+func (s *ReadableGraph) LatestNodeByName(name string) (*ReadableNode, error) {
+	n := s.graph.LatestNodeByName(name)
+	if n == nil {
+		return nil, fmt.Errorf("Node with name %s not found", name)
+	}
+	return n.Get(), nil
+}
+
+// NodesByStatus returns every node currently in the given status
+func (s *ReadableGraph) NodesByStatus(status NodeStatus) []*ReadableNode {
+	return s.NodesWhere(func(n *ReadableNode) bool {
+		return n.Status() == status
+	})
+}
+
+// NodesWhere returns every node in this graph the match func accepts
+func (s *ReadableGraph) NodesWhere(match func(n *ReadableNode) bool) []*ReadableNode {
+	result := []*ReadableNode{}
+	for _, n := range s.graph.allNodes() {
+		r := n.Get()
+		if match(r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// NodesWhereKey returns every node where any value stored under key is accepted by match
+func (s *ReadableGraph) NodesWhereKey(key string, match func(value []byte) bool) []*ReadableNode {
+	return s.NodesWhere(func(n *ReadableNode) bool {
+		for _, v := range n.All(key) {
+			if match(v) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FindAll searches this graph and every nested sub-graph, depth first,
+// returning the nodes the match func accepts
+func (s *ReadableGraph) FindAll(match func(n *ReadableNode) bool) []*ReadableNode {
+	result := []*ReadableNode{}
+	s.Walk(func(g *ReadableGraph, n *ReadableNode) {
+		if match(n) {
+			result = append(result, n)
+		}
+	})
+	return result
+}
+
+// FindByName searches this graph and every nested sub-graph for nodes with the given name
+func (s *ReadableGraph) FindByName(name string) []*ReadableNode {
+	return s.FindAll(func(n *ReadableNode) bool {
+		return n.Name() == name
+	})
+}
+
+// FindGraph searches this graph and every nested sub-graph for the graph with the given id
+func (s *ReadableGraph) FindGraph(id string) (*ReadableGraph, error) {
+	if s.ID() == id {
+		return s, nil
+	}
+	for _, n := range s.graph.allNodes() {
+		for _, sub := range n.Get().SubGraph() {
+			found, err := sub.FindGraph(id)
+			if err == nil {
+				return found, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Graph with id %s not found", id)
+}
+
+// Walk calls fn for every node in this graph and, depth first, every nested sub-graph.
+// Sub-graph nodes are visited directly after the node that owns them.
+func (s *ReadableGraph) Walk(fn func(g *ReadableGraph, n *ReadableNode)) {
+	for _, n := range s.graph.allNodes() {
+		r := n.Get()
+		fn(s, r)
+		for _, sub := range r.SubGraph() {
+			sub.Walk(fn)
+		}
+	}
+}
+
+// Query addresses nodes in nested sub-graphs by path.
+// A path alternates node names and sub-graph selectors, separated by "/".
+// Node names may be "*" to match any node, and sub-graph selectors are
+// either a zero based index or "*" to match every sub-graph of the node.
+// eg. "fanout/*/reverse" returns the reverse nodes from every sub-graph of the fanout node
+func (s *ReadableGraph) Query(path string) ([]*ReadableNode, error) {
+	segments := strings.Split(path, "/")
+	if path == "" || len(segments)%2 == 0 {
+		return nil, fmt.Errorf("invalid path %q: must alternate node names and sub-graph selectors", path)
+	}
+	graphs := []*ReadableGraph{s}
+	for i := 0; ; i += 2 {
+		nodes := []*ReadableNode{}
+		for _, g := range graphs {
+			nodes = append(nodes, g.matchName(segments[i])...)
+		}
+		if i == len(segments)-1 {
+			return nodes, nil
+		}
+		next, err := selectSubGraphs(nodes, segments[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", path, err)
+		}
+		graphs = next
+	}
+}
+
+func (s *ReadableGraph) matchName(name string) []*ReadableNode {
+	if name == PathWildcard {
+		return s.Nodes()
+	}
+	return s.NodesByName(name)
+}
+
+func selectSubGraphs(nodes []*ReadableNode, selector string) ([]*ReadableGraph, error) {
+	result := []*ReadableGraph{}
+	if selector == PathWildcard {
+		for _, n := range nodes {
+			result = append(result, n.SubGraph()...)
+		}
+		return result, nil
+	}
+	idx, err := strconv.Atoi(selector)
+	if err != nil || idx < 0 {
+		return nil, fmt.Errorf("sub-graph selector %q is not an index or %s", selector, PathWildcard)
+	}
+	for _, n := range nodes {
+		subs := n.SubGraph()
+		if idx < len(subs) {
+			result = append(result, subs[idx])
+		}
+	}
+	return result, nil
+}
+
+func readable(nodes []*Node) []*ReadableNode {
+	result := make([]*ReadableNode, 0, len(nodes))
+	for _, n := range nodes {
+		result = append(result, n.Get())
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package goraff_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildFanOutGraph builds a graph shaped like a FanOut run:
+// input -> fanout, where fanout has one sub-graph per item containing item and reverse nodes
+func buildFanOutGraph(items ...string) (*goraff.Graph, []*goraff.Graph) {
+	g := &goraff.Graph{}
+	g.NewNode("input", nil).SetStr("result", "in")
+	fanout := g.NewNode("fanout", nil)
+	subs := []*goraff.Graph{}
+	for _, item := range items {
+		sub := &goraff.Graph{}
+		sub.NewNode("item", nil).SetStr("result", item)
+		sub.NewNode("reverse", nil).SetStr("result", strings.ToUpper(item))
+		fanout.AddSubGraph(sub)
+		subs = append(subs, sub)
+	}
+	return g, subs
+}
+
+func TestReadableGraph_NodesByName(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	n1 := g.NewNode("node", nil)
+	g.NewNode("other", nil)
+	n2 := g.NewNode("node", nil)
+	r := goraff.NewReadableGraph(g)
+
+	nodes := r.NodesByName("node")
+	assert.Len(nodes, 2)
+	assert.Equal(n1.Get().ID(), nodes[0].ID())
+	assert.Equal(n2.Get().ID(), nodes[1].ID())
+	assert.Len(r.NodesByName("missing"), 0)
+}
+
+func TestReadableGraph_LatestNodeByName(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	g.NewNode("node", nil).SetStr("key", "first")
+	g.NewNode("node", nil).SetStr("key", "latest")
+	r := goraff.NewReadableGraph(g)
+
+	n, err := r.LatestNodeByName("node")
+	assert.NoError(err)
+	assert.Equal("latest", n.FirstStr("key"))
+
+	_, err = r.LatestNodeByName("missing")
+	assert.EqualError(err, "Node with name missing not found")
+}
+
+func TestReadableGraph_NodesByStatus(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	g.NewNode("running", nil)
+	g.NewNode("done", nil).MarkDone()
+	g.NewNode("failed", nil).MarkFailed(fmt.Errorf("boom"))
+	r := goraff.NewReadableGraph(g)
+
+	assert.Equal([]string{"running"}, names(r.NodesByStatus(goraff.NodeStatusRunning)))
+	assert.Equal([]string{"done"}, names(r.NodesByStatus(goraff.NodeStatusDone)))
+	failed := r.NodesByStatus(goraff.NodeStatusFailed)
+	assert.Equal([]string{"failed"}, names(failed))
+	assert.EqualError(failed[0].Err(), "boom")
+}
+
+func TestReadableGraph_NodesWhereKey(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	g.NewNode("a", nil).AddStrs("tags", []string{"red", "blue"})
+	g.NewNode("b", nil).AddStrs("tags", []string{"green"})
+	g.NewNode("c", nil)
+	r := goraff.NewReadableGraph(g)
+
+	nodes := r.NodesWhereKey("tags", func(v []byte) bool { return string(v) == "blue" })
+	assert.Equal([]string{"a"}, names(nodes))
+}
+
+func TestReadableGraph_Query(t *testing.T) {
+	assert := assert.New(t)
+	g, _ := buildFanOutGraph("one", "two", "three")
+	r := goraff.NewReadableGraph(g)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "input", want: []string{"in"}},
+		{path: "fanout/*/reverse", want: []string{"ONE", "TWO", "THREE"}},
+		{path: "fanout/1/reverse", want: []string{"TWO"}},
+		{path: "fanout/5/reverse", want: []string{}},
+		{path: "fanout/*/*", want: []string{"one", "ONE", "two", "TWO", "three", "THREE"}},
+		{path: "missing/*/reverse", want: []string{}},
+	}
+	for _, tt := range tests {
+		nodes, err := r.Query(tt.path)
+		assert.NoError(err, tt.path)
+		got := []string{}
+		for _, n := range nodes {
+			got = append(got, n.FirstStr("result"))
+		}
+		assert.Equal(tt.want, got, tt.path)
+	}
+}
+
+func TestReadableGraph_Query_InvalidPath(t *testing.T) {
+	assert := assert.New(t)
+	g, _ := buildFanOutGraph("one")
+	r := goraff.NewReadableGraph(g)
+
+	_, err := r.Query("")
+	assert.Error(err)
+	_, err = r.Query("fanout/*")
+	assert.Error(err)
+	_, err = r.Query("fanout/first/reverse")
+	assert.EqualError(err, `invalid path "fanout/first/reverse": sub-graph selector "first" is not an index or *`)
+}
+
+func TestReadableGraph_FindAll(t *testing.T) {
+	assert := assert.New(t)
+	g, _ := buildFanOutGraph("one", "two")
+	r := goraff.NewReadableGraph(g)
+
+	assert.Equal([]string{"input", "fanout", "item", "reverse", "item", "reverse"}, names(r.FindAll(func(n *goraff.ReadableNode) bool { return true })))
+	assert.Len(r.FindByName("reverse"), 2)
+	assert.Len(r.FindByName("input"), 1)
+}
+
+func TestReadableGraph_FindGraph(t *testing.T) {
+	assert := assert.New(t)
+	g, subs := buildFanOutGraph("one", "two")
+	r := goraff.NewReadableGraph(g)
+	want := goraff.NewReadableGraph(subs[1])
+
+	found, err := r.FindGraph(want.ID())
+	assert.NoError(err)
+	assert.Equal(want.ID(), found.ID())
+	n, err := found.FirstNodeByName("item")
+	assert.NoError(err)
+	assert.Equal("two", n.FirstStr("result"))
+
+	_, err = r.FindGraph("missing")
+	assert.EqualError(err, "Graph with id missing not found")
+}
+
+func TestGraph_NewNode_IndexesLargeGraphs(t *testing.T) {
+	assert := assert.New(t)
+	g := &goraff.Graph{}
+	var last *goraff.Node
+	for i := 0; i < 10000; i++ {
+		last = g.NewNode(fmt.Sprintf("node%d", i%100), nil)
+	}
+	assert.Equal(last, g.NodeByID(last.Get().ID()))
+	assert.Equal(last, g.LatestNodeByName("node99"))
+	assert.Len(g.NodeByName("node99"), 100)
+}
+
+func names(nodes []*goraff.ReadableNode) []string {
+	result := []string{}
+	for _, n := range nodes {
+		result = append(result, n.Name())
+	}
+	return result
+}
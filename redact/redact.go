@@ -0,0 +1,137 @@
+package redact
+
+import (
+	"path"
+	"regexp"
+
+	"github.com/lordtatty/goraff"
+)
+
+// DefaultReplacement stands in for anything removed
+const DefaultReplacement = "[REDACTED]"
+
+// Scrubber removes matches of a pattern from any value
+type Scrubber struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Replacement is used for each match. Defaults to the Redactor's replacement.
+	Replacement string
+}
+
+// DefaultScrubbers match email addresses and common API token formats
+func DefaultScrubbers() []Scrubber {
+	return []Scrubber{
+		{Name: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+		{Name: "bearer", Pattern: regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/\-]+=*`)},
+		{Name: "jwt", Pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`)},
+		{Name: "groq", Pattern: regexp.MustCompile(`gsk_[A-Za-z0-9]{20,}`)},
+		{Name: "openai", Pattern: regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`)},
+		{Name: "github", Pattern: regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{30,}`)},
+		{Name: "aws", Pattern: regexp.MustCompile(`AKIA[0-9A-Z]{16}`)},
+	}
+}
+
+// Redactor hides sensitive values before they leave the process.
+// A nil Redactor leaves everything unchanged, so it can be an optional field.
+type Redactor struct {
+	// SensitiveKeys are node keys whose values are replaced entirely.
+	// Entries may be path.Match patterns, such as "*_api_key".
+	SensitiveKeys []string
+	// SensitiveBlocks are block names whose nodes have every value replaced entirely.
+	// Entries may be path.Match patterns.
+	SensitiveBlocks []string
+	// Scrubbers remove matches from all other values
+	Scrubbers   []Scrubber
+	Replacement string
+}
+
+// New returns a redactor for the given keys that also applies DefaultScrubbers
+func New(sensitiveKeys ...string) *Redactor {
+	return &Redactor{SensitiveKeys: sensitiveKeys, Scrubbers: DefaultScrubbers()}
+}
+
+func (r *Redactor) replacement() string {
+	if r.Replacement == "" {
+		return DefaultReplacement
+	}
+	return r.Replacement
+}
+
+// Sensitive reports whether values of the key on nodes of the named block are hidden entirely
+func (r *Redactor) Sensitive(nodeName, key string) bool {
+	if r == nil {
+		return false
+	}
+	return matchAny(r.SensitiveBlocks, nodeName) || matchAny(r.SensitiveKeys, key)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok || p == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the value as it may be shown for the key on a node of the named block
+func (r *Redactor) Value(nodeName, key, value string) string {
+	if r == nil {
+		return value
+	}
+	if r.Sensitive(nodeName, key) {
+		return r.replacement()
+	}
+	return r.String(value)
+}
+
+// Bytes is Value for raw node values. It matches goraff.JournalConfig.Redact.
+func (r *Redactor) Bytes(nodeName, key string, value []byte) []byte {
+	if r == nil {
+		return value
+	}
+	return []byte(r.Value(nodeName, key, string(value)))
+}
+
+// String applies the scrubbers to free text, such as error messages
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, sc := range r.Scrubbers {
+		if sc.Pattern == nil {
+			continue
+		}
+		repl := sc.Replacement
+		if repl == "" {
+			repl = r.replacement()
+		}
+		s = sc.Pattern.ReplaceAllLiteralString(s, repl)
+	}
+	return s
+}
+
+// Notification returns a copy of c with any written value redacted
+func (r *Redactor) Notification(c goraff.GraphChangeNotification) goraff.GraphChangeNotification {
+	if r == nil {
+		return c
+	}
+	if c.Op == goraff.ChangeOpAdd || c.Op == goraff.ChangeOpSet {
+		c.Value = r.Bytes(c.NodeName, c.Key, c.Value)
+	}
+	return c
+}
+
+// Notifier redacts notifications before passing them on.
+// Set it as a graph's Notifier in front of whatever broadcasts changes.
+type Notifier struct {
+	Next     goraff.ChangeNotifier
+	Redactor *Redactor
+}
+
+func (n *Notifier) Notify(c goraff.GraphChangeNotification) {
+	if n.Next == nil {
+		return
+	}
+	n.Next.Notify(n.Redactor.Notification(c))
+}
@@ -0,0 +1,712 @@
+package runs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/websocket"
+)
+
+// InputsNode is the name of the node holding a run's inputs, see goraff.InputsNode
+const InputsNode = goraff.InputsNode
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+	// StatusSuspended is a run whose blocks are waiting for events, see RunManager.Resume
+	StatusSuspended Status = "suspended"
+)
+
+type ErrRunNotFound struct {
+	ID string
+}
+
+func (e ErrRunNotFound) Error() string {
+	return "run not found: " + e.ID
+}
+
+type ErrRunNotSuspended struct {
+	ID string
+}
+
+func (e ErrRunNotSuspended) Error() string {
+	return "run is not suspended: " + e.ID
+}
+
+// Run is one execution of a registered scaff. Its ID is the ID of its root graph.
+type Run struct {
+	id        string
+	scaff     string
+	inputs    map[string]string
+	graph     *goraff.Graph
+	blueprint *goraff.Scaff
+	stream    *outputs.Stream
+	// async feeds the stream when the manager has a StreamQueue
+	async *notifiers.AsyncNotifier
+	done  chan struct{}
+
+	mu sync.Mutex
+	// work is what the run does next, starting the scaff or resuming it
+	work   func(ctx context.Context) error
+	ctx    context.Context
+	cancel context.CancelFunc
+	// stopped is closed when the run finishes or suspends
+	stopped    chan struct{}
+	status     Status
+	err        error
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+func (r *Run) ID() string {
+	return r.id
+}
+
+// Scaff returns the name the run's scaff is registered under
+func (r *Run) Scaff() string {
+	return r.scaff
+}
+
+func (r *Run) Inputs() map[string]string {
+	result := map[string]string{}
+	for k, v := range r.inputs {
+		result[k] = v
+	}
+	return result
+}
+
+func (r *Run) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Err returns the error the run failed with, if any.
+// A suspended run's error is a goraff.ErrRunSuspended.
+func (r *Run) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// CreatedAt returns when the run was requested
+func (r *Run) CreatedAt() time.Time {
+	return r.createdAt
+}
+
+// StartedAt returns when the run left the queue, or the zero time if it has not
+func (r *Run) StartedAt() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.startedAt
+}
+
+// FinishedAt returns when the run finished, or the zero time if it has not
+func (r *Run) FinishedAt() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finishedAt
+}
+
+// Graph returns the run's graph, which may still be changing
+func (r *Run) Graph() *goraff.ReadableGraph {
+	return goraff.NewReadableGraph(r.graph)
+}
+
+// Stream returns the run's updates, for websocket.WebSocketServer and sse.Handler
+func (r *Run) Stream() *outputs.Stream {
+	return r.stream
+}
+
+// Done is closed when the run finishes
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// WaitingFor returns the events a suspended run is waiting for
+func (r *Run) WaitingFor() []string {
+	if r.Status() != StatusSuspended {
+		return []string{}
+	}
+	return r.Graph().WaitingFor()
+}
+
+// Finished reports whether the run has reached a final status
+func (r *Run) Finished() bool {
+	switch r.Status() {
+	case StatusSucceeded, StatusFailed, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// queue sets the work the run does when it is launched. It must be called with r.mu held.
+func (r *Run) queue(work func(ctx context.Context) error) {
+	r.work = work
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.stopped = make(chan struct{})
+	r.status = StatusQueued
+}
+
+func (r *Run) begin() (context.Context, context.CancelFunc, func(ctx context.Context) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = StatusRunning
+	if r.startedAt.IsZero() {
+		r.startedAt = time.Now()
+	}
+	return r.ctx, r.cancel, r.work
+}
+
+func (r *Run) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// runs restored by Recover have nothing to cancel until they are resumed
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+func (r *Run) stoppedCh() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopped
+}
+
+// finish records how the run's work ended
+func (r *Run) finish(err error, cancelled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finishLocked(err, cancelled)
+}
+
+// suspends reports whether work that ended with err leaves the run suspended
+func suspends(err error, cancelled bool) bool {
+	return !cancelled && errors.As(err, &goraff.ErrRunSuspended{})
+}
+
+func (r *Run) finishLocked(err error, cancelled bool) {
+	r.err = err
+	select {
+	case <-r.stopped:
+		// a suspended run being cancelled has already stopped
+	default:
+		close(r.stopped)
+	}
+	if suspends(err, cancelled) {
+		r.status = StatusSuspended
+		return
+	}
+	r.finishedAt = time.Now()
+	if r.async != nil {
+		// delivers what is queued without holding up the caller
+		go r.async.Close()
+	}
+	switch {
+	case cancelled && errors.Is(err, context.Canceled):
+		r.status = StatusCancelled
+	case err != nil:
+		r.status = StatusFailed
+	default:
+		r.status = StatusSucceeded
+	}
+	close(r.done)
+}
+
+func (r *Run) saved() SavedRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return SavedRun{
+		ID:        r.id,
+		Scaff:     r.scaff,
+		Inputs:    r.Inputs(),
+		CreatedAt: r.createdAt,
+		StartedAt: r.startedAt,
+		SavedAt:   time.Now(),
+		Graph:     r.Graph().State(),
+	}
+}
+
+// RunManager starts registered scaffs in the background and keeps track of their runs
+type RunManager struct {
+	Scaffs *Registry
+	// Hooks are added to the graph of every run
+	Hooks []*goraff.Hooks
+	// Differ, when set, makes the differ behind each run's stream, such as to add a Redactor
+	Differ func(r *goraff.ReadableGraph) *outputs.Differ
+	// MaxConcurrent limits how many runs execute at once. Further runs wait in a queue,
+	// first in first out. Zero means no limit.
+	MaxConcurrent int
+	// Retention is how long finished runs are kept. Zero keeps them until the manager is dropped.
+	Retention time.Duration
+	// Inbox, when set, is the inbox the scaffs' blockactions.HumanInput blocks ask through,
+	// so questions can be listed and answered through the manager. Its questions are in memory
+	// only: the runs asking them are running, not suspended, so Store and Recover do not keep them.
+	Inbox *blockactions.Inbox
+	// Store, when set, keeps suspended runs so they can be resumed after a restart, see Recover.
+	// Without it suspended runs are only kept in memory.
+	Store Store
+	// OnError is called when a run cannot be saved to or deleted from the Store,
+	// or a change cannot be encoded for the websockets. Defaults to printing the error to stderr.
+	OnError func(err error)
+	// StreamQueue, when above zero, delivers each run's changes to its stream, and so to the
+	// websockets, through a notifiers.AsyncNotifier with a queue this long, so slow clients
+	// cannot hold up the run's blocks. StreamOverflow decides what happens when it fills, see Dropped.
+	StreamQueue    int
+	StreamOverflow notifiers.OverflowPolicy
+
+	mu         sync.Mutex
+	runs       map[string]*Run
+	queue      []*Run
+	running    int
+	websockets []*websocket.WebSocketServer
+	// dropped counts the notifications dropped by runs that have been pruned
+	dropped uint64
+}
+
+func NewRunManager(scaffs *Registry) *RunManager {
+	return &RunManager{Scaffs: scaffs}
+}
+
+// Start runs the named scaff in the background, with the inputs on its InputsNode
+func (m *RunManager) Start(scaff string, inputs map[string]string) (*Run, error) {
+	if m.Scaffs == nil {
+		return nil, ErrScaffNotFound{Name: scaff}
+	}
+	s, err := m.Scaffs.Get(scaff)
+	if err != nil {
+		return nil, err
+	}
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier, Hooks: m.Hooks}
+	run := &Run{
+		id:        goraff.NewReadableGraph(g).ID(),
+		scaff:     scaff,
+		inputs:    map[string]string{},
+		graph:     g,
+		blueprint: s,
+		done:      make(chan struct{}),
+		createdAt: time.Now(),
+	}
+	run.stream, run.async = m.stream(g, notifier)
+	run.queue(func(ctx context.Context) error {
+		return s.GoCtx(ctx, g)
+	})
+	keys := make([]string, 0, len(inputs))
+	for k, v := range inputs {
+		run.inputs[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	in := g.NewNode(InputsNode, nil)
+	for _, k := range keys {
+		in.SetStr(k, inputs[k])
+	}
+	in.MarkDone()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+	if m.runs == nil {
+		m.runs = map[string]*Run{}
+	}
+	m.runs[run.id] = run
+	m.schedule(run)
+	return run, nil
+}
+
+// stream makes the stream of the graph's updates and sends them to the websockets.
+// The async notifier feeding it is nil without a StreamQueue.
+func (m *RunManager) stream(g *goraff.Graph, notifier *notifiers.GraphNotifier) (*outputs.Stream, *notifiers.AsyncNotifier) {
+	readable := goraff.NewReadableGraph(g)
+	differ := outputs.NewDiffer(readable)
+	if m.Differ != nil {
+		differ = m.Differ(readable)
+	}
+	stream := outputs.NewStream(differ, 0)
+	stream.OnError = m.report
+	m.mu.Lock()
+	for _, ws := range m.websockets {
+		m.broadcast(stream, ws)
+	}
+	m.mu.Unlock()
+	if m.StreamQueue <= 0 {
+		notifier.Listen(stream.Notify)
+		return stream, nil
+	}
+	async := notifiers.NewAsyncNotifier(m.StreamQueue, m.StreamOverflow)
+	async.Listen(stream.Notify)
+	notifier.Listen(async.Notify)
+	return stream, async
+}
+
+// Dropped returns the number of changes dropped from runs' stream queues, see StreamQueue.
+// Dropped changes never reach the stream, so clients only see them in a later snapshot.
+func (m *RunManager) Dropped() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := m.dropped
+	for _, r := range m.runs {
+		if r.async != nil {
+			total += r.async.Dropped()
+		}
+	}
+	return total
+}
+
+// schedule launches the run, or queues it if there is no room. It must be called with m.mu held.
+func (m *RunManager) schedule(run *Run) {
+	if m.MaxConcurrent > 0 && m.running >= m.MaxConcurrent {
+		m.queue = append(m.queue, run)
+		return
+	}
+	m.launch(run)
+}
+
+// launch must be called with m.mu held
+func (m *RunManager) launch(run *Run) {
+	m.running++
+	ctx, cancel, work := run.begin()
+	go func() {
+		defer cancel()
+		err := work(ctx)
+		cancelled := ctx.Err() != nil
+		// the Store is up to date before anyone waiting on the run carries on
+		m.save(run, suspends(err, cancelled))
+		run.finish(err, cancelled)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.running--
+		m.next()
+	}()
+}
+
+// save keeps a suspended run in the Store, and forgets it once it has finished
+func (m *RunManager) save(run *Run, suspended bool) {
+	if m.Store == nil {
+		return
+	}
+	var err error
+	if suspended {
+		err = m.Store.Save(run.saved())
+	} else {
+		err = m.Store.Delete(run.id)
+	}
+	if err == nil {
+		return
+	}
+	m.report(fmt.Errorf("error saving run %s: %w", run.id, err))
+}
+
+// report passes err to OnError, or prints it to stderr when OnError is not set
+func (m *RunManager) report(err error) {
+	if m.OnError != nil {
+		m.OnError(err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+}
+
+// next starts queued runs while there is room. It must be called with m.mu held.
+func (m *RunManager) next() {
+	for len(m.queue) > 0 && (m.MaxConcurrent <= 0 || m.running < m.MaxConcurrent) {
+		run := m.queue[0]
+		m.queue = m.queue[1:]
+		m.launch(run)
+	}
+}
+
+// prune forgets runs that finished longer ago than Retention. It must be called with m.mu held.
+func (m *RunManager) prune(now time.Time) {
+	if m.Retention <= 0 {
+		return
+	}
+	for id, r := range m.runs {
+		if f := r.FinishedAt(); !f.IsZero() && now.Sub(f) > m.Retention {
+			if r.async != nil {
+				m.dropped += r.async.Dropped()
+			}
+			delete(m.runs, id)
+		}
+	}
+}
+
+func (m *RunManager) Get(id string) (*Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+	r, ok := m.runs[id]
+	if !ok {
+		return nil, ErrRunNotFound{ID: id}
+	}
+	return r, nil
+}
+
+// List returns every retained run, in the order they were requested
+func (m *RunManager) List() []*Run {
+	m.mu.Lock()
+	m.prune(time.Now())
+	result := make([]*Run, 0, len(m.runs))
+	for _, r := range m.runs {
+		result = append(result, r)
+	}
+	m.mu.Unlock()
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt().Before(result[j].CreatedAt())
+	})
+	return result
+}
+
+// Cancel stops the run from starting any more blocks, or takes it out of the queue.
+// A suspended run is finished as cancelled. Cancelling a finished run does nothing.
+func (m *RunManager) Cancel(id string) error {
+	r, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	r.stop()
+	m.mu.Lock()
+	for i, q := range m.queue {
+		if q == r {
+			m.queue = append(m.queue[:i:i], m.queue[i+1:]...)
+			r.finish(fmt.Errorf("run cancelled before it started: %w", context.Canceled), true)
+			break
+		}
+	}
+	m.mu.Unlock()
+	if r.cancelSuspended() {
+		m.save(r, false)
+	}
+	return nil
+}
+
+// cancelSuspended finishes a suspended run as cancelled, and reports whether it was suspended
+func (r *Run) cancelSuspended() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status != StatusSuspended {
+		return false
+	}
+	r.finishLocked(fmt.Errorf("run cancelled while suspended: %w", context.Canceled), true)
+	return true
+}
+
+// Resume carries on a suspended run, resuming the blocks waiting for the event.
+// The run goes back through the queue. A run saved in the Store by another process is
+// loaded first, as Recover would.
+func (m *RunManager) Resume(id string, event goraff.Event) error {
+	r, err := m.Get(id)
+	if errors.As(err, &ErrRunNotFound{}) && m.Store != nil {
+		r, err = m.recover(id)
+	}
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	if r.status != StatusSuspended {
+		r.mu.Unlock()
+		return ErrRunNotSuspended{ID: id}
+	}
+	if !slices.Contains(r.Graph().WaitingFor(), event.Name) {
+		r.mu.Unlock()
+		return goraff.ErrEventNotAwaited{Event: event.Name}
+	}
+	r.queue(func(ctx context.Context) error {
+		return r.blueprint.ResumeCtx(ctx, r.graph, event)
+	})
+	r.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedule(r)
+	return nil
+}
+
+// Recover loads every run in the Store that the manager does not have yet, so they are
+// listed and can be resumed. Call it once at startup. Runs whose scaff is no longer
+// registered are skipped and reported in the error.
+func (m *RunManager) Recover() error {
+	if m.Store == nil {
+		return nil
+	}
+	saved, err := m.Store.List()
+	if err != nil {
+		return fmt.Errorf("error listing saved runs: %w", err)
+	}
+	var errs []error
+	for _, s := range saved {
+		if _, err := m.add(s); err != nil {
+			errs = append(errs, fmt.Errorf("error recovering run %s: %w", s.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *RunManager) recover(id string) (*Run, error) {
+	s, err := m.Store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	return m.add(s)
+}
+
+// add restores a saved run as suspended, unless the manager already has it
+func (m *RunManager) add(s SavedRun) (*Run, error) {
+	if r, err := m.Get(s.ID); err == nil {
+		return r, nil
+	}
+	if m.Scaffs == nil {
+		return nil, ErrScaffNotFound{Name: s.Scaff}
+	}
+	blueprint, err := m.Scaffs.Get(s.Scaff)
+	if err != nil {
+		return nil, err
+	}
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier, Hooks: m.Hooks}
+	if err := g.Restore(s.Graph); err != nil {
+		return nil, err
+	}
+	run := &Run{
+		id:        s.ID,
+		scaff:     s.Scaff,
+		inputs:    map[string]string{},
+		graph:     g,
+		blueprint: blueprint,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		status:    StatusSuspended,
+		createdAt: s.CreatedAt,
+		startedAt: s.StartedAt,
+	}
+	for k, v := range s.Inputs {
+		run.inputs[k] = v
+	}
+	close(run.stopped)
+	run.err = goraff.ErrRunSuspended{Events: run.Graph().WaitingFor()}
+	run.stream, run.async = m.stream(g, notifier)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.runs[run.id]; ok {
+		return r, nil
+	}
+	if m.runs == nil {
+		m.runs = map[string]*Run{}
+	}
+	m.runs[run.id] = run
+	return run, nil
+}
+
+// Wait blocks until the run finishes or suspends, or ctx is done, and returns the run
+func (m *RunManager) Wait(ctx context.Context, id string) (*Run, error) {
+	r, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-r.stoppedCh():
+		return r, nil
+	case <-ctx.Done():
+		return r, fmt.Errorf("error waiting for run %s: %w", id, ctx.Err())
+	}
+}
+
+// Stream returns the updates of the run with the given ID, for sse.Handler's Lookup
+func (m *RunManager) Stream(id string) (*outputs.Stream, error) {
+	r, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.stream, nil
+}
+
+// Broadcast sends the updates of every run, including runs started later, to the websocket's
+// clients that have subscribed to the run's graph or the sub-graph a change is in,
+// or that have no subscriptions.
+// The manager becomes the websocket's Source.
+func (m *RunManager) Broadcast(ws *websocket.WebSocketServer) {
+	ws.Source = m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.websockets = append(m.websockets, ws)
+	for _, r := range m.runs {
+		m.broadcast(r.stream, ws)
+	}
+}
+
+func (m *RunManager) broadcast(st *outputs.Stream, ws *websocket.WebSocketServer) {
+	st.Listen(func(msg outputs.Message) {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			m.report(fmt.Errorf("error marshalling message %d: %w", msg.Seq, err))
+			return
+		}
+		ws.SendGraphs(msg.GraphIDs(), string(b))
+	})
+}
+
+// Replay brings a websocket client up to date with every retained run, for websocket.WebSocketServer.Source
+func (m *RunManager) Replay(since uint64) []string {
+	result := []string{}
+	for _, r := range m.List() {
+		result = append(result, r.stream.Replay(since)...)
+	}
+	return result
+}
+
+// Questions returns the questions the run is waiting on, including those asked in sub-graphs
+func (m *RunManager) Questions(id string) ([]blockactions.Question, error) {
+	r, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	result := []blockactions.Question{}
+	if m.Inbox == nil {
+		return result, nil
+	}
+	g := r.Graph()
+	for _, q := range m.Inbox.Pending() {
+		if _, err := g.FindGraph(q.GraphID); err == nil {
+			result = append(result, q)
+		}
+	}
+	return result, nil
+}
+
+// StartRun, CancelRun and Answer let websocket clients start and cancel runs and answer questions
+func (m *RunManager) StartRun(scaff string, inputs map[string]string) (string, error) {
+	r, err := m.Start(scaff, inputs)
+	if err != nil {
+		return "", err
+	}
+	return r.ID(), nil
+}
+
+func (m *RunManager) CancelRun(id string) error {
+	return m.Cancel(id)
+}
+
+func (m *RunManager) Answer(questionID, answer string) error {
+	if m.Inbox == nil {
+		return blockactions.ErrQuestionNotFound{ID: questionID}
+	}
+	return m.Inbox.Answer(questionID, answer)
+}
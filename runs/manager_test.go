@@ -0,0 +1,548 @@
+package runs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/lordtatty/goraff/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echo copies the "topic" input to its result
+type echo struct{}
+
+func (e *echo) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	in, err := r.FirstNodeByName(runs.InputsNode)
+	if err != nil {
+		return err
+	}
+	n.SetStr("result", in.FirstStr("topic"))
+	return nil
+}
+
+// wait blocks until its run is cancelled
+type wait struct {
+	started chan struct{}
+}
+
+func (w *wait) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	close(w.started)
+	<-r.Context().Done()
+	return nil
+}
+
+type fail struct{}
+
+func (f *fail) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	return fmt.Errorf("boom")
+}
+
+func single(name string, a goraff.BlockAction) *goraff.Scaff {
+	s := goraff.NewScaff()
+	s.SetEntrypoint(s.Blocks().Add(name, a))
+	return s
+}
+
+func waitDone(t *testing.T, r *runs.Run) {
+	t.Helper()
+	select {
+	case <-r.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("run did not finish")
+	}
+}
+
+func TestRunManager_Start(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("echo", single("echo", &echo{})))
+	sut := runs.NewRunManager(reg)
+
+	run, err := sut.Start("echo", map[string]string{"topic": "cats"})
+	require.NoError(t, err)
+	waitDone(t, run)
+
+	assert.Equal(run.Graph().ID(), run.ID())
+	assert.Equal("echo", run.Scaff())
+	assert.Equal(map[string]string{"topic": "cats"}, run.Inputs())
+	assert.Equal(runs.StatusSucceeded, run.Status())
+	assert.NoError(run.Err())
+	assert.False(run.FinishedAt().Before(run.StartedAt()))
+	n, err := run.Graph().FirstNodeByName("echo")
+	assert.NoError(err)
+	assert.Equal("cats", n.FirstStr("result"))
+	in, _ := run.Graph().FirstNodeByName(runs.InputsNode)
+	assert.Equal(goraff.NodeStatusDone, in.Status())
+
+	got, err := sut.Get(run.ID())
+	assert.NoError(err)
+	assert.Same(run, got)
+	st, err := sut.Stream(run.ID())
+	assert.NoError(err)
+	assert.Equal(run.ID(), st.GraphID())
+}
+
+// TestRunManager_ConcurrentRuns runs one registered scaff many times at once,
+// so go test -race catches state shared between its runs
+func TestRunManager_ConcurrentRuns(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	s.SetEntrypoint(s.Blocks().Add("echo", &echo{}))
+	s.Blocks().Add("again", &echo{})
+	require.NoError(t, s.Joins().Add("echo", "again", nil))
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("echo", s))
+	sut := runs.NewRunManager(reg)
+
+	started := make([]*runs.Run, 20)
+	var wg sync.WaitGroup
+	for i := range started {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run, err := sut.Start("echo", map[string]string{"topic": strconv.Itoa(i)})
+			assert.NoError(err)
+			started[i] = run
+		}(i)
+	}
+	wg.Wait()
+	for i, run := range started {
+		waitDone(t, run)
+		assert.Equal(runs.StatusSucceeded, run.Status())
+		n, err := run.Graph().FirstNodeByName("again")
+		assert.NoError(err)
+		assert.Equal(strconv.Itoa(i), n.FirstStr("result"))
+	}
+}
+
+// burst writes count values as fast as it can once released
+type burst struct {
+	release chan struct{}
+	count   int
+}
+
+func (b *burst) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	<-b.release
+	for i := 0; i < b.count; i++ {
+		n.SetStr("result", strconv.Itoa(i))
+	}
+	return nil
+}
+
+func TestRunManager_StreamQueue(t *testing.T) {
+	assert := assert.New(t)
+	b := &burst{release: make(chan struct{}), count: 20}
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("burst", single("burst", b)))
+	sut := runs.NewRunManager(reg)
+	sut.StreamQueue = 1
+	sut.StreamOverflow = notifiers.OverflowDropOldest
+
+	run, err := sut.Start("burst", nil)
+	require.NoError(t, err)
+	st, err := sut.Stream(run.ID())
+	require.NoError(t, err)
+	// a slow listener holds up the stream, and not the block
+	unblock := make(chan struct{})
+	var once sync.Once
+	var mu sync.Mutex
+	var last outputs.Message
+	st.Listen(func(m outputs.Message) {
+		once.Do(func() { <-unblock })
+		mu.Lock()
+		defer mu.Unlock()
+		last = m
+	})
+	close(b.release)
+	waitDone(t, run)
+	assert.Equal(runs.StatusSucceeded, run.Status())
+	assert.Greater(sut.Dropped(), uint64(0))
+
+	close(unblock)
+	// the run's last change still reaches the stream once the queue drains
+	assert.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return last.Patch != nil && last.Patch.Status == string(goraff.NodeStatusDone)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRunManager_Failed(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("fail", single("fail", &fail{})))
+	sut := runs.NewRunManager(reg)
+
+	run, err := sut.Start("fail", nil)
+	require.NoError(t, err)
+	waitDone(t, run)
+	assert.Equal(runs.StatusFailed, run.Status())
+	assert.EqualError(run.Err(), "error running block: boom")
+}
+
+func TestRunManager_Cancel(t *testing.T) {
+	assert := assert.New(t)
+	w := &wait{started: make(chan struct{})}
+	s := single("wait", w)
+	s.Blocks().Add("after", &echo{})
+	s.Joins().Add("wait", "after", nil)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("wait", s))
+	sut := runs.NewRunManager(reg)
+
+	run, err := sut.Start("wait", nil)
+	require.NoError(t, err)
+	<-w.started
+	assert.Equal(runs.StatusRunning, run.Status())
+	assert.NoError(sut.CancelRun(run.ID()))
+	waitDone(t, run)
+	assert.Equal(runs.StatusCancelled, run.Status())
+	_, err = run.Graph().FirstNodeByName("after")
+	assert.Error(err)
+
+	// cancelling again does nothing
+	assert.NoError(sut.Cancel(run.ID()))
+}
+
+func TestRunManager_NotFound(t *testing.T) {
+	assert := assert.New(t)
+	sut := runs.NewRunManager(&runs.Registry{})
+	_, err := sut.Start("missing", nil)
+	assert.Equal(runs.ErrScaffNotFound{Name: "missing"}, err)
+	_, err = sut.StartRun("missing", nil)
+	assert.Equal(runs.ErrScaffNotFound{Name: "missing"}, err)
+	_, err = sut.Get("nope")
+	assert.Equal(runs.ErrRunNotFound{ID: "nope"}, err)
+	assert.Equal(runs.ErrRunNotFound{ID: "nope"}, sut.Cancel("nope"))
+	_, err = sut.Stream("nope")
+	assert.Error(err)
+}
+
+func TestRunManager_List(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("echo", single("echo", &echo{})))
+	sut := runs.NewRunManager(reg)
+
+	ids := []string{}
+	for i := 0; i < 3; i++ {
+		id, err := sut.StartRun("echo", nil)
+		assert.NoError(err)
+		ids = append(ids, id)
+		time.Sleep(time.Millisecond)
+	}
+	got := []string{}
+	for _, r := range sut.List() {
+		got = append(got, r.ID())
+	}
+	assert.Equal(ids, got)
+}
+
+func TestRunManager_MaxConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	first := &wait{started: make(chan struct{})}
+	second := &wait{started: make(chan struct{})}
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("first", single("first", first)))
+	require.NoError(t, reg.Register("second", single("second", second)))
+	sut := runs.NewRunManager(reg)
+	sut.MaxConcurrent = 1
+
+	r1, err := sut.Start("first", nil)
+	require.NoError(t, err)
+	r2, err := sut.Start("second", nil)
+	require.NoError(t, err)
+	<-first.started
+	assert.Equal(runs.StatusRunning, r1.Status())
+	assert.Equal(runs.StatusQueued, r2.Status())
+	assert.True(r2.StartedAt().IsZero())
+	assert.False(r2.CreatedAt().IsZero())
+	assert.False(r2.Finished())
+
+	// finishing the first run starts the next in the queue
+	assert.NoError(sut.Cancel(r1.ID()))
+	waitDone(t, r1)
+	select {
+	case <-second.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("queued run did not start")
+	}
+	assert.Equal(runs.StatusRunning, r2.Status())
+	assert.NoError(sut.Cancel(r2.ID()))
+	waitDone(t, r2)
+	assert.True(r2.Finished())
+}
+
+func TestRunManager_CancelQueued(t *testing.T) {
+	assert := assert.New(t)
+	w := &wait{started: make(chan struct{})}
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("wait", single("wait", w)))
+	require.NoError(t, reg.Register("echo", single("echo", &echo{})))
+	sut := runs.NewRunManager(reg)
+	sut.MaxConcurrent = 1
+
+	running, err := sut.Start("wait", nil)
+	require.NoError(t, err)
+	queued, err := sut.Start("echo", nil)
+	require.NoError(t, err)
+	assert.NoError(sut.Cancel(queued.ID()))
+	waitDone(t, queued)
+	assert.Equal(runs.StatusCancelled, queued.Status())
+	assert.True(errors.Is(queued.Err(), context.Canceled))
+	assert.True(queued.StartedAt().IsZero())
+
+	assert.NoError(sut.Cancel(running.ID()))
+	waitDone(t, running)
+	_, err = queued.Graph().FirstNodeByName("echo")
+	assert.Error(err)
+}
+
+func TestRunManager_Retention(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("echo", single("echo", &echo{})))
+	sut := runs.NewRunManager(reg)
+	sut.Retention = 20 * time.Millisecond
+
+	run, err := sut.Start("echo", nil)
+	require.NoError(t, err)
+	waitDone(t, run)
+	_, err = sut.Get(run.ID())
+	assert.NoError(err)
+	assert.Eventually(func() bool {
+		_, err := sut.Get(run.ID())
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(sut.List())
+}
+
+func TestRunManager_Wait(t *testing.T) {
+	assert := assert.New(t)
+	w := &wait{started: make(chan struct{})}
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("wait", single("wait", w)))
+	sut := runs.NewRunManager(reg)
+
+	run, err := sut.Start("wait", nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = sut.Wait(ctx, run.ID())
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+
+	time.AfterFunc(10*time.Millisecond, func() { _ = sut.Cancel(run.ID()) })
+	got, err := sut.Wait(context.Background(), run.ID())
+	assert.NoError(err)
+	assert.Equal(runs.StatusCancelled, got.Status())
+
+	_, err = sut.Wait(context.Background(), "nope")
+	assert.Equal(runs.ErrRunNotFound{ID: "nope"}, err)
+}
+
+func TestRunManager_Broadcast(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("echo", single("echo", &echo{})))
+	sut := runs.NewRunManager(reg)
+	first, err := sut.Start("echo", map[string]string{"topic": "otters"})
+	require.NoError(t, err)
+	waitDone(t, first)
+
+	ws := websocket.NewWebSocketServer("")
+	sut.Broadcast(ws)
+	srv := httptest.NewServer(ws.Handler())
+	defer srv.Close()
+	conn, _, err := gws.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	read := func() outputs.Message {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, b, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var m outputs.Message
+		require.NoError(t, json.Unmarshal(b, &m))
+		return m
+	}
+
+	// runs that already exist are replayed on connect
+	m := read()
+	assert.Equal(outputs.MessageTypeSnapshot, m.Type)
+	assert.Equal(first.ID(), m.Snapshot.PrimaryStateID)
+
+	// and runs started later are broadcast as they change
+	second, err := sut.Start("echo", map[string]string{"topic": "voles"})
+	require.NoError(t, err)
+	waitDone(t, second)
+	m = read()
+	assert.Equal(outputs.MessageTypePatch, m.Type)
+	assert.Equal(second.ID(), m.Patch.GraphID)
+	assert.Equal(outputs.PatchNodeAdded, m.Patch.Op)
+	assert.Equal(runs.InputsNode, m.Patch.NodeName)
+}
+
+func TestRunManager_Questions(t *testing.T) {
+	assert := assert.New(t)
+	inbox := &blockactions.Inbox{}
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("ask", single("ask", &blockactions.HumanInput{Inbox: inbox, Prompt: "name?"})))
+	sut := runs.NewRunManager(reg)
+	sut.Inbox = inbox
+	var _ websocket.Controller = sut
+
+	run, err := sut.Start("ask", nil)
+	require.NoError(t, err)
+	other, err := sut.Start("ask", nil)
+	require.NoError(t, err)
+	var qs []blockactions.Question
+	assert.Eventually(func() bool {
+		qs, err = sut.Questions(run.ID())
+		return err == nil && len(qs) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal("name?", qs[0].Prompt)
+	assert.Len(inbox.Pending(), 2)
+
+	assert.NoError(sut.Answer(qs[0].ID, "Jo"))
+	waitDone(t, run)
+	assert.Equal(runs.StatusSucceeded, run.Status())
+	n, err := run.Graph().FirstNodeByName("ask")
+	require.NoError(t, err)
+	assert.Equal("Jo", n.FirstStr("result"))
+
+	assert.Equal(blockactions.ErrQuestionNotFound{ID: "nope"}, sut.Answer("nope", "x"))
+	_, err = sut.Questions("nope")
+	assert.Equal(runs.ErrRunNotFound{ID: "nope"}, err)
+	require.NoError(t, sut.Cancel(other.ID()))
+	waitDone(t, other)
+}
+
+func approvalScaff(t *testing.T) *goraff.Scaff {
+	s := goraff.NewScaff()
+	approve := s.Blocks().Add("approve", &blockactions.Wait{Event: "approval"})
+	after := s.Blocks().Add("after", &blockactions.Input{FromNode: "approve"})
+	s.SetEntrypoint(approve)
+	require.NoError(t, s.Joins().Add(approve, after, nil))
+	return s
+}
+
+func TestRunManager_Resume(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("approval", approvalScaff(t)))
+	sut := runs.NewRunManager(reg)
+	sut.MaxConcurrent = 1
+
+	run, err := sut.Start("approval", nil)
+	require.NoError(t, err)
+	_, err = sut.Wait(context.Background(), run.ID())
+	require.NoError(t, err)
+	assert.Equal(runs.StatusSuspended, run.Status())
+	assert.Equal([]string{"approval"}, run.WaitingFor())
+	assert.Equal(goraff.ErrRunSuspended{Events: []string{"approval"}}, run.Err())
+	assert.False(run.Finished())
+
+	// a suspended run does not hold its place
+	other, err := sut.Start("approval", nil)
+	require.NoError(t, err)
+	_, err = sut.Wait(context.Background(), other.ID())
+	require.NoError(t, err)
+	assert.Equal(runs.StatusSuspended, other.Status())
+
+	assert.Equal(goraff.ErrEventNotAwaited{Event: "payment"}, sut.Resume(run.ID(), goraff.Event{Name: "payment"}))
+	assert.NoError(sut.Resume(run.ID(), goraff.Event{Name: "approval", Data: []byte("yes")}))
+	waitDone(t, run)
+	assert.Equal(runs.StatusSucceeded, run.Status())
+	assert.Empty(run.WaitingFor())
+	after, err := run.Graph().FirstNodeByName("after")
+	require.NoError(t, err)
+	assert.Equal("yes", after.FirstStr("result"))
+
+	assert.Equal(runs.ErrRunNotSuspended{ID: run.ID()}, sut.Resume(run.ID(), goraff.Event{Name: "approval"}))
+	assert.Equal(runs.ErrRunNotFound{ID: "nope"}, sut.Resume("nope", goraff.Event{Name: "approval"}))
+}
+
+func TestRunManager_ResumeAfterRestart(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("approval", approvalScaff(t)))
+	store := &runs.FileStore{Dir: t.TempDir()}
+	first := runs.NewRunManager(reg)
+	first.Store = store
+
+	ids := []string{}
+	for _, topic := range []string{"a", "b"} {
+		run, err := first.Start("approval", map[string]string{"topic": topic})
+		require.NoError(t, err)
+		_, err = first.Wait(context.Background(), run.ID())
+		require.NoError(t, err)
+		ids = append(ids, run.ID())
+	}
+	saved, err := store.Load(ids[0])
+	require.NoError(t, err)
+	assert.Equal("approval", saved.Scaff)
+	assert.Equal(map[string]string{"topic": "a"}, saved.Inputs)
+	assert.Equal(ids[0], saved.Graph.ID)
+
+	// a new process recovers every saved run
+	second := runs.NewRunManager(reg)
+	second.Store = store
+	assert.NoError(second.Recover())
+	assert.Len(second.List(), 2)
+	run, err := second.Get(ids[0])
+	require.NoError(t, err)
+	assert.Equal(runs.StatusSuspended, run.Status())
+	assert.Equal([]string{"approval"}, run.WaitingFor())
+	assert.Equal(map[string]string{"topic": "a"}, run.Inputs())
+	assert.NoError(second.Resume(ids[0], goraff.Event{Name: "approval", Data: []byte("yes")}))
+	waitDone(t, run)
+	assert.Equal(runs.StatusSucceeded, run.Status())
+	_, err = store.Load(ids[0])
+	assert.Equal(runs.ErrRunNotFound{ID: ids[0]}, err)
+
+	// or loads a run when it is resumed
+	third := runs.NewRunManager(reg)
+	third.Store = store
+	assert.NoError(third.Resume(ids[1], goraff.Event{Name: "approval", Data: []byte("no")}))
+	run, err = third.Wait(context.Background(), ids[1])
+	require.NoError(t, err)
+	waitDone(t, run)
+	after, err := run.Graph().FirstNodeByName("after")
+	require.NoError(t, err)
+	assert.Equal("no", after.FirstStr("result"))
+}
+
+func TestRunManager_CancelSuspended(t *testing.T) {
+	assert := assert.New(t)
+	reg := &runs.Registry{}
+	require.NoError(t, reg.Register("approval", approvalScaff(t)))
+	store := &runs.FileStore{Dir: t.TempDir()}
+	sut := runs.NewRunManager(reg)
+	sut.Store = store
+
+	run, err := sut.Start("approval", nil)
+	require.NoError(t, err)
+	_, err = sut.Wait(context.Background(), run.ID())
+	require.NoError(t, err)
+	assert.NoError(sut.Cancel(run.ID()))
+	waitDone(t, run)
+	assert.Equal(runs.StatusCancelled, run.Status())
+	assert.True(errors.Is(run.Err(), context.Canceled))
+	saved, err := store.List()
+	assert.NoError(err)
+	assert.Empty(saved)
+}
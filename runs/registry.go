@@ -0,0 +1,60 @@
+package runs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lordtatty/goraff"
+)
+
+type ErrScaffNotFound struct {
+	Name string
+}
+
+func (e ErrScaffNotFound) Error() string {
+	return "scaff not found: " + e.Name
+}
+
+// Registry holds the scaffs that can be run by name
+type Registry struct {
+	mu     sync.RWMutex
+	scaffs map[string]*goraff.Scaff
+}
+
+// Register validates the scaff and adds it under name, replacing any scaff already
+// registered with it. Runs share the scaff, so it must not be changed once registered.
+func (r *Registry) Register(name string, s *goraff.Scaff) error {
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("error validating scaff %s: %w", name, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.scaffs == nil {
+		r.scaffs = map[string]*goraff.Scaff{}
+	}
+	r.scaffs[name] = s
+	return nil
+}
+
+func (r *Registry) Get(name string) (*goraff.Scaff, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.scaffs[name]
+	if !ok {
+		return nil, ErrScaffNotFound{Name: name}
+	}
+	return s, nil
+}
+
+// Names returns the registered names in alphabetical order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.scaffs))
+	for name := range r.scaffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,36 @@
+package runs_test
+
+import (
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+	sut := &runs.Registry{}
+	assert.Empty(sut.Names())
+
+	s := single("b", &echo{})
+	assert.NoError(sut.Register("b", s))
+	assert.NoError(sut.Register("a", single("a", &echo{})))
+	assert.Equal([]string{"a", "b"}, sut.Names())
+
+	got, err := sut.Get("b")
+	assert.NoError(err)
+	assert.Same(s, got)
+
+	_, err = sut.Get("missing")
+	assert.Equal(runs.ErrScaffNotFound{Name: "missing"}, err)
+	assert.EqualError(err, "scaff not found: missing")
+}
+
+func TestRegistry_RegisterInvalid(t *testing.T) {
+	assert := assert.New(t)
+	sut := &runs.Registry{}
+	err := sut.Register("empty", goraff.NewScaff())
+	assert.EqualError(err, "error validating scaff empty: entrypoint not set")
+	assert.Empty(sut.Names())
+}
@@ -0,0 +1,130 @@
+package runs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lordtatty/goraff"
+)
+
+// SavedRun is a suspended run as kept in a Store, enough to resume it in another process
+type SavedRun struct {
+	ID        string            `json:"id"`
+	Scaff     string            `json:"scaff"`
+	Inputs    map[string]string `json:"inputs"`
+	CreatedAt time.Time         `json:"created_at"`
+	StartedAt time.Time         `json:"started_at"`
+	SavedAt   time.Time         `json:"saved_at"`
+	Graph     goraff.GraphState `json:"graph"`
+}
+
+// Store keeps suspended runs so they can be resumed after a restart
+type Store interface {
+	Save(r SavedRun) error
+	// Load returns ErrRunNotFound if there is no run with the ID
+	Load(id string) (SavedRun, error)
+	// Delete does nothing if there is no run with the ID
+	Delete(id string) error
+	List() ([]SavedRun, error)
+}
+
+// FileStore keeps each run as a JSON file named after its ID in Dir.
+// Node values are written as they are, so keep Dir private if they hold secrets.
+type FileStore struct {
+	Dir string
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", ErrRunNotFound{ID: id}
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
+
+// Save writes the run to a temporary file first, so a crash never leaves half a run behind
+func (s *FileStore) Save(r SavedRun) error {
+	path, err := s.path(r.ID)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error marshalling run: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("error creating store directory: %w", err)
+	}
+	f, err := os.CreateTemp(s.Dir, ".run-*")
+	if err != nil {
+		return fmt.Errorf("error creating run file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing run file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing run file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("error writing run file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) Load(id string) (SavedRun, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return SavedRun{}, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return SavedRun{}, ErrRunNotFound{ID: id}
+	}
+	if err != nil {
+		return SavedRun{}, fmt.Errorf("error reading run file: %w", err)
+	}
+	var r SavedRun
+	if err := json.Unmarshal(b, &r); err != nil {
+		return SavedRun{}, fmt.Errorf("error parsing run file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error deleting run file: %w", err)
+	}
+	return nil
+}
+
+// List returns every saved run, in the order they were requested
+func (s *FileStore) List() ([]SavedRun, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing run files: %w", err)
+	}
+	result := []SavedRun{}
+	for _, p := range paths {
+		r, err := s.Load(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
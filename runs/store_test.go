@@ -0,0 +1,48 @@
+package runs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/runs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	assert := assert.New(t)
+	sut := &runs.FileStore{Dir: filepath.Join(t.TempDir(), "runs")}
+	list, err := sut.List()
+	assert.NoError(err)
+	assert.Empty(list)
+
+	now := time.Now().UTC()
+	older := runs.SavedRun{ID: "b", Scaff: "s", CreatedAt: now.Add(-time.Minute), Graph: goraff.GraphState{ID: "b"}}
+	newer := runs.SavedRun{ID: "a", Scaff: "s", CreatedAt: now, Graph: goraff.GraphState{ID: "a", Nodes: []goraff.NodeState{
+		{ID: "n1", Name: "wait", WaitingFor: "approval", Values: map[string][][]byte{"k": {[]byte("v")}}},
+	}}}
+	require.NoError(t, sut.Save(newer))
+	require.NoError(t, sut.Save(older))
+
+	got, err := sut.Load("a")
+	assert.NoError(err)
+	assert.Equal("approval", got.Graph.Nodes[0].WaitingFor)
+	assert.Equal([][]byte{[]byte("v")}, got.Graph.Nodes[0].Values["k"])
+	list, err = sut.List()
+	assert.NoError(err)
+	assert.Equal([]string{"b", "a"}, []string{list[0].ID, list[1].ID})
+
+	info, err := os.Stat(filepath.Join(sut.Dir, "a.json"))
+	require.NoError(t, err)
+	assert.Equal(os.FileMode(0o600), info.Mode().Perm())
+
+	assert.NoError(sut.Delete("a"))
+	assert.NoError(sut.Delete("a"))
+	_, err = sut.Load("a")
+	assert.Equal(runs.ErrRunNotFound{ID: "a"}, err)
+	_, err = sut.Load("../a")
+	assert.Equal(runs.ErrRunNotFound{ID: "../a"}, err)
+}
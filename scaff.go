@@ -1,7 +1,9 @@
 package goraff
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"sync"
 )
 
@@ -11,42 +13,99 @@ type Scaff struct {
 	entrypoint *Block
 	joins      *Joins
 	blocks     *Blocks
+	// once makes blocks and joins, so runs of a shared scaff never race to create them
+	once sync.Once
 }
 
 func NewScaff() *Scaff {
 	return &Scaff{}
 }
 
-func (g *Scaff) Blocks() *Blocks {
-	if g.blocks == nil {
+func (g *Scaff) init() {
+	g.once.Do(func() {
 		g.blocks = &Blocks{}
-	}
+		g.joins = &Joins{Blocks: g.blocks}
+	})
+}
+
+func (g *Scaff) Blocks() *Blocks {
+	g.init()
 	return g.blocks
 }
 
 func (g *Scaff) Joins() *Joins {
-	if g.joins == nil {
-		g.joins = &Joins{
-			Blocks: g.Blocks(),
-		}
-	}
+	g.init()
 	return g.joins
 }
 
 func (g *Scaff) SetEntrypoint(name string) {
-	n := g.blocks.Get(name)
+	n := g.Blocks().Get(name)
 	g.entrypoint = n
 }
 
+// Entrypoint returns the block the scaff starts from, or nil if it has not been set
+func (g *Scaff) Entrypoint() *Block {
+	return g.entrypoint
+}
+
+// Go runs the scaff on the graph. Sub-graphs run with the context of their parent's run.
 func (g *Scaff) Go(graph *Graph) error {
 	if graph == nil {
 		return fmt.Errorf("graph not provided")
 	}
+	return g.GoCtx(graph.context(), graph)
+}
+
+// GoCtx runs the scaff on the graph until ctx is done.
+// Once it is, no more blocks are started, and the context's error is returned
+// after running blocks finish.
+func (g *Scaff) GoCtx(ctx context.Context, graph *Graph) error {
+	if graph == nil {
+		return fmt.Errorf("graph not provided")
+	}
+	graph.setContext(ctx)
 	err := g.validate()
 	if err != nil {
 		return fmt.Errorf("error validating graph: %w", err)
 	}
-	return g.flowMgr(graph)
+	fmt.Println("starting block", g.entrypoint.Name)
+	return g.run(ctx, graph, []nextJoin{{
+		Join:         &Join{From: nil, To: g.entrypoint},
+		previousNode: nil,
+	}})
+}
+
+// run follows the flow from the start joins and fires the run hooks around it
+func (g *Scaff) run(ctx context.Context, graph *Graph, start []nextJoin) error {
+	r := NewReadableGraph(graph)
+	graph.eachHook(func(h *Hooks) {
+		if h.RunStarted != nil {
+			h.RunStarted(r)
+		}
+	})
+	err := g.flowMgr(ctx, graph, start)
+	graph.eachHook(func(h *Hooks) {
+		if h.RunFinished != nil {
+			h.RunFinished(r, err)
+		}
+	})
+	return err
+}
+
+// InputsNode is the name of the node holding a run's inputs, one key per input.
+// It is created, and marked done, before the scaff's entrypoint runs.
+const InputsNode = "inputs"
+
+// Validate checks the scaff can run: its entrypoint is set, block names are unique,
+// every join was added without error, and each block action that is a BlockValidator,
+// or runs a scaff of its own, is valid. It also checks every node a NodeReader reads
+// is a block, or the run's InputsNode, including in the scaffs blocks run.
+// Go and GoCtx skip that last check, as a graph can be given nodes before it runs.
+func (g *Scaff) Validate() error {
+	if err := g.validate(); err != nil {
+		return err
+	}
+	return g.validateReads([]string{InputsNode})
 }
 
 func (g *Scaff) validate() error {
@@ -63,29 +122,77 @@ func (g *Scaff) validate() error {
 	if err != nil {
 		return fmt.Errorf("error validating joins: %w", err)
 	}
+	// check block actions
+	for _, b := range g.Blocks().All() {
+		if v, ok := b.Action.(BlockValidator); ok {
+			if err := v.Validate(); err != nil {
+				return fmt.Errorf("error validating block %s: %w", b.Name, err)
+			}
+		}
+		if subs, ok := b.Action.(SubScaffer); ok {
+			for _, sub := range subs.SubScaffs() {
+				if sub == nil {
+					return fmt.Errorf("block %s has no scaff", b.Name)
+				}
+				if err := sub.validate(); err != nil {
+					return fmt.Errorf("error validating block %s: %w", b.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateReads checks every node the blocks read by name is a block or one of known,
+// and does the same for the scaffs the blocks run
+func (g *Scaff) validateReads(known []string) error {
+	names := slices.Clone(known)
+	for _, b := range g.Blocks().All() {
+		names = append(names, b.Name)
+	}
+	for _, b := range g.Blocks().All() {
+		if r, ok := b.Action.(NodeReader); ok {
+			for _, name := range r.NodesRead() {
+				if !slices.Contains(names, name) {
+					return fmt.Errorf("error validating block %s: reads node %q, which is not a block", b.Name, name)
+				}
+			}
+		}
+		subs, ok := b.Action.(SubScaffer)
+		if !ok {
+			continue
+		}
+		var seeds []string
+		if s, ok := b.Action.(SubGraphSeeder); ok {
+			seeds = s.SubGraphNodes()
+		}
+		for _, sub := range subs.SubScaffs() {
+			if err := sub.validateReads(seeds); err != nil {
+				return fmt.Errorf("error validating block %s: %w", b.Name, err)
+			}
+		}
+	}
 	return nil
 }
 
 type nextJoin struct {
 	Join         *Join
 	previousNode *Node
+	// resume, when set, is a suspended node of Join.To to carry on with event,
+	// instead of running the block on a new node
+	resume *Node
+	event  Event
 }
 
-func (g *Scaff) flowMgr(graph *Graph) error {
-	if g.entrypoint == nil {
-		return fmt.Errorf("entrypoint not set")
-	}
-
-	completedCh := make(chan nextJoin, 10)
+func (g *Scaff) flowMgr(ctx context.Context, graph *Graph, start []nextJoin) error {
+	completedCh := make(chan nextJoin, 10+len(start))
 	var wg sync.WaitGroup
 
-	completedCh <- nextJoin{
-		Join:         &Join{From: nil, To: g.entrypoint},
-		previousNode: nil,
+	for _, n := range start {
+		completedCh <- n
+		wg.Add(1) // Increment for each starting node
 	}
-	wg.Add(1) // Increment for the initial node
 
-	fmt.Println("starting block", g.entrypoint.Name)
 	var foundErr error
 	mut := sync.Mutex{}
 	go func() {
@@ -101,6 +208,13 @@ func (g *Scaff) flowMgr(graph *Graph) error {
 			fmt.Println("considering block", n.Join.To.Name)
 			r := NewReadableGraph(graph)
 			t, err := n.Join.TriggersMet(r)
+			if n.Join.From != nil {
+				graph.eachHook(func(h *Hooks) {
+					if h.JoinEvaluated != nil {
+						h.JoinEvaluated(r, n.Join, t, err)
+					}
+				})
+			}
 			if err != nil {
 				fmt.Printf("error checking join condition: %s\n", err.Error())
 				wg.Done()
@@ -119,14 +233,30 @@ func (g *Scaff) flowMgr(graph *Graph) error {
 				block := n.Join.To
 				defer fmt.Printf("finished block %s\n", n.Join.To.Name)
 				fmt.Println("starting block", block.Name)
-				if foundErr != nil {
+				mut.Lock()
+				if foundErr == nil && ctx.Err() != nil {
+					foundErr = fmt.Errorf("run stopped: %w", ctx.Err())
+				}
+				stop := foundErr != nil
+				mut.Unlock()
+				if stop {
 					return
 				}
 				var tr *ReadableNode = nil
 				if n.previousNode != nil {
 					tr = n.previousNode.Get()
 				}
-				completedNode, err := g.runBlock(graph, block, tr)
+				var completedNode *Node
+				var err error
+				if n.resume != nil {
+					completedNode, err = g.resumeBlock(graph, block, n.resume, n.event)
+				} else {
+					completedNode, err = g.runBlock(graph, block, tr)
+				}
+				if _, ok := suspension(err); ok {
+					// the branch waits here until the run is resumed, and BlockFinished has reported why
+					return
+				}
 				if err != nil {
 					fmt.Printf("error running block %s, letting all active blocks drain: %s \n", block.Name, err.Error())
 					mut.Lock()
@@ -156,13 +286,50 @@ func (g *Scaff) flowMgr(graph *Graph) error {
 
 	wg.Wait()          // Wait for all goroutines to finish
 	close(completedCh) // Safe to close here as no more writes will happen
+	// blocks may have cut their work short when the context ended, so the run did not complete
+	if foundErr == nil && ctx.Err() != nil {
+		foundErr = fmt.Errorf("run stopped: %w", ctx.Err())
+	}
+	if foundErr == nil {
+		foundErr = graph.suspendedErr()
+	}
 	return foundErr
 }
 
 func (s *Scaff) runBlock(g *Graph, b *Block, triggeringNS *ReadableNode) (*Node, error) {
-	n := g.NewNode(b.Name, nil)
+	var triggeredBy []*ReadableNode
+	if triggeringNS != nil {
+		triggeredBy = []*ReadableNode{triggeringNS}
+	}
+	n := g.NewNode(b.Name, triggeredBy)
+	return s.do(g, b, n, func(r *ReadableGraph) error {
+		return b.Action.Do(n, r, triggeringNS)
+	})
+}
+
+// do runs the block's work on its node, firing the block hooks around it.
+// A block that suspends is left waiting with the suspension as its error.
+func (s *Scaff) do(g *Graph, b *Block, n *Node, work func(r *ReadableGraph) error) (*Node, error) {
 	r := NewReadableGraph(g)
-	err := b.Action.Do(n, r, triggeringNS)
+	g.eachHook(func(h *Hooks) {
+		if h.BlockStarted != nil {
+			h.BlockStarted(r, n.Get())
+		}
+	})
+	err := work(r)
+	if event, ok := suspension(err); ok && g.parentNode() != nil {
+		err = fmt.Errorf("block %s cannot wait for %s: only blocks of a run's top level graph can suspend", b.Name, event)
+	}
+	if event, ok := suspension(err); ok {
+		n.suspend(event)
+	} else if err != nil {
+		n.MarkFailed(err)
+	}
+	g.eachHook(func(h *Hooks) {
+		if h.BlockFinished != nil {
+			h.BlockFinished(r, n.Get(), err)
+		}
+	})
 	if err != nil {
 		return nil, err
 	}
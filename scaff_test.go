@@ -1,6 +1,8 @@
 package goraff_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -15,8 +17,10 @@ import (
 func TestNew(t *testing.T) {
 	assert := assert.New(t)
 	g := goraff.NewScaff()
-	// must be an empty goraff.Graph{}
-	assert.Equal(goraff.Scaff{}, *g)
+	// must be an empty scaff
+	assert.Nil(g.Entrypoint())
+	assert.Empty(g.Blocks().All())
+	assert.NoError(g.Joins().Validate())
 }
 
 type actionMock struct {
@@ -325,6 +329,74 @@ func TestScaff_Go_ValidateUniqueBlockNames(t *testing.T) {
 	assert.Equal("error validating graph: error validating blocks: block name not unique: action1", err.Error())
 }
 
+// validatingAction is a BlockValidator that returns err
+type validatingAction struct {
+	actionMock
+	err error
+}
+
+func (a *validatingAction) Validate() error {
+	return a.err
+}
+
+// nestedAction runs nothing, but reports a scaff of its own
+type nestedAction struct {
+	actionMock
+	sub *goraff.Scaff
+}
+
+func (a *nestedAction) SubScaffs() []*goraff.Scaff {
+	return []*goraff.Scaff{a.sub}
+}
+
+func TestScaff_Validate_BlockActions(t *testing.T) {
+	assert := assert.New(t)
+	g := goraff.NewScaff()
+	v := &validatingAction{actionMock: actionMock{name: "valid"}}
+	g.SetEntrypoint(g.Blocks().Add("valid", v))
+	assert.NoError(g.Validate())
+
+	v.err = fmt.Errorf("bad template")
+	assert.EqualError(g.Validate(), "error validating block valid: bad template")
+	err := g.Go(&goraff.Graph{})
+	assert.EqualError(err, "error validating graph: error validating block valid: bad template")
+
+	v.err = nil
+	sub := goraff.NewScaff()
+	g.Blocks().Add("nested", &nestedAction{sub: sub})
+	assert.EqualError(g.Validate(), "error validating block nested: entrypoint not set")
+	sub.SetEntrypoint(sub.Blocks().Add("inner", &actionMock{name: "inner"}))
+	assert.NoError(g.Validate())
+}
+
+// readingAction reads the named nodes
+type readingAction struct {
+	actionMock
+	reads []string
+}
+
+func (a *readingAction) NodesRead() []string {
+	return a.reads
+}
+
+func TestScaff_Validate_NodesRead(t *testing.T) {
+	assert := assert.New(t)
+	g := goraff.NewScaff()
+	r := &readingAction{reads: []string{goraff.InputsNode, "reader", "missing"}}
+	g.SetEntrypoint(g.Blocks().Add("reader", r))
+	assert.EqualError(g.Validate(), `error validating block reader: reads node "missing", which is not a block`)
+	// runs can be given nodes before they start, so Go does not check reads
+	assert.NoError(g.Go(&goraff.Graph{}))
+
+	r.reads = r.reads[:2]
+	assert.NoError(g.Validate())
+
+	sub := goraff.NewScaff()
+	sub.SetEntrypoint(sub.Blocks().Add("inner", &readingAction{reads: []string{"reader"}}))
+	g.Blocks().Add("nested", &nestedAction{sub: sub})
+	assert.EqualError(g.Validate(), `error validating block nested: error validating block inner: reads node "reader", which is not a block`)
+}
+
 // This test is to ensure that the scaff can be reused
 // with multiple graphs, eg. Does not hold on to any
 // state from Go() calls
@@ -383,3 +455,51 @@ func TestScaff_Go_IsReusable(t *testing.T) {
 	}
 	assert.Len(responses, 100)
 }
+
+func TestScaff_GoCtxCancelled(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	a := s.Blocks().Add("slow", &actionMock{name: "slow", delay: 50 * time.Millisecond})
+	b := s.Blocks().Add("never", &actionMock{name: "never", expectNoRun: true, t: t})
+	s.SetEntrypoint(a)
+	s.Joins().Add(a, b, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	graph := &goraff.Graph{}
+	err := s.GoCtx(ctx, graph)
+	assert.True(errors.Is(err, context.Canceled), "got %v", err)
+	// the running block finishes, but nothing after it starts
+	assert.Equal("slow", graph.FirstNodeByName("slow").Get().FirstStr("slow_key"))
+	assert.Nil(graph.FirstNodeByName("never"))
+}
+
+type contextAction struct {
+	got context.Context
+}
+
+func (a *contextAction) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	a.got = r.Context()
+	return nil
+}
+
+func TestScaff_SubGraphsShareContext(t *testing.T) {
+	assert := assert.New(t)
+	sub := goraff.NewScaff()
+	inner := &contextAction{}
+	sub.SetEntrypoint(sub.Blocks().Add("inner", inner))
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "run")
+	graph := &goraff.Graph{}
+	n := graph.NewNode("parent", nil)
+	assert.Equal(context.Background(), goraff.NewReadableGraph(graph).Context())
+
+	s := goraff.NewScaff()
+	s.SetEntrypoint(s.Blocks().Add("outer", &contextAction{}))
+	assert.NoError(s.GoCtx(ctx, graph))
+	subGraph := &goraff.Graph{}
+	n.AddSubGraph(subGraph)
+	assert.NoError(sub.Go(subGraph))
+	assert.Equal("run", inner.got.Value(key{}))
+}
@@ -0,0 +1,159 @@
+package scaffdef
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/llm"
+)
+
+// EnvGroqAPIKey is the environment variable EnvClients reads the Groq API key from
+const EnvGroqAPIKey = "GROQ_API_KEY"
+
+// ClientFunc makes the client for an llm block
+type ClientFunc func(provider, model string) (blockactions.LLMClient, error)
+
+// EnvClients makes clients with credentials from the environment.
+// Groq needs GROQ_API_KEY, and Ollama is found as its own client finds it, through OLLAMA_HOST.
+func EnvClients(provider, model string) (blockactions.LLMClient, error) {
+	switch provider {
+	case ProviderGroq:
+		key := os.Getenv(EnvGroqAPIKey)
+		if key == "" {
+			return nil, fmt.Errorf("%s is not set", EnvGroqAPIKey)
+		}
+		return &llm.Groq{APIKey: key, Model: model}, nil
+	case ProviderOllama:
+		return &llm.Ollama{Model: model}, nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", provider)
+}
+
+// Builder turns definitions into scaffs
+type Builder struct {
+	// Clients makes the client for each llm block. Defaults to EnvClients.
+	Clients ClientFunc
+	// Inbox is where human_input blocks ask their questions. Defaults to a new inbox,
+	// shared by every scaff the builder builds.
+	Inbox *blockactions.Inbox
+}
+
+// Build validates the definition and builds its scaff
+func (b *Builder) Build(d *Def) (*goraff.Scaff, error) {
+	if err := d.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scaff definition: %w", err)
+	}
+	prompts, err := d.loadPrompts()
+	if err != nil {
+		return nil, err
+	}
+	return b.scaff(d, prompts)
+}
+
+func (b *Builder) scaff(d *Def, prompts *blockactions.Prompts) (*goraff.Scaff, error) {
+	s := goraff.NewScaff()
+	for _, bd := range d.Blocks {
+		a, err := b.action(bd, prompts)
+		if err != nil {
+			return nil, fmt.Errorf("error building block %s: %w", bd.Name, err)
+		}
+		s.Blocks().Add(bd.Name, a)
+	}
+	for _, j := range d.Joins {
+		if err := s.Joins().Add(j.From, j.To, condition(j.If)); err != nil {
+			return nil, fmt.Errorf("error adding join from %s to %s: %w", j.From, j.To, err)
+		}
+	}
+	s.SetEntrypoint(d.Entrypoint)
+	return s, nil
+}
+
+func (b *Builder) action(bd BlockDef, prompts *blockactions.Prompts) (goraff.BlockAction, error) {
+	switch bd.Type {
+	case BlockInput:
+		return &blockactions.Input{Value: bd.Value, FromNode: bd.FromNode, FromKey: bd.FromKey}, nil
+	case BlockLLM:
+		clients := b.Clients
+		if clients == nil {
+			clients = EnvClients
+		}
+		c, err := clients(bd.Provider, bd.Model)
+		if err != nil {
+			return nil, fmt.Errorf("error making %s client: %w", bd.Provider, err)
+		}
+		return &blockactions.LLM{SystemMsg: bd.System, UserMsg: bd.User, Client: c, IncludeOutputs: bd.Include, Prompts: prompts}, nil
+	case BlockPrint:
+		return &blockactions.Print{}, nil
+	case BlockFanOut:
+		s, err := b.scaff(bd.Scaff, prompts)
+		if err != nil {
+			return nil, err
+		}
+		return &blockactions.FanOut{Scaff: s, InNode: bd.InNode, InKey: bd.InKey, OutNode: bd.OutNode, OutKey: bd.OutKey}, nil
+	case BlockScaff:
+		s, err := b.scaff(bd.Scaff, prompts)
+		if err != nil {
+			return nil, err
+		}
+		return &blockactions.ScaffNode{Scaff: s}, nil
+	case BlockHuman:
+		if b.Inbox == nil {
+			b.Inbox = &blockactions.Inbox{}
+		}
+		h := &blockactions.HumanInput{
+			Inbox:   b.Inbox,
+			Prompt:  bd.Prompt,
+			Schema:  string(bd.Schema),
+			Options: bd.Options,
+			Default: bd.Default,
+		}
+		if bd.Timeout != "" {
+			d, err := time.ParseDuration(bd.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing timeout: %w", err)
+			}
+			h.Timeout = d
+		}
+		return h, nil
+	case BlockWait:
+		return &blockactions.Wait{Event: bd.Event}, nil
+	case BlockHTTP:
+		h := &blockactions.HTTP{
+			Method:       bd.Method,
+			URL:          bd.URL,
+			Headers:      bd.Headers,
+			Body:         bd.Body,
+			Retries:      bd.Retries,
+			Extract:      bd.Extract,
+			IgnoreStatus: bd.IgnoreStatus,
+		}
+		if bd.Timeout != "" {
+			d, err := time.ParseDuration(bd.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing timeout: %w", err)
+			}
+			h.Timeout = d
+		}
+		return h, nil
+	case BlockTemplate:
+		return &blockactions.Template{Text: bd.Text, Key: bd.Key}, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", bd.Type)
+}
+
+func condition(c *ConditionDef) goraff.FollowIf {
+	if c == nil {
+		return nil
+	}
+	if len(c.Completed) > 0 {
+		return goraff.FollowIfNodesCompleted(c.Completed...)
+	}
+	key := c.Key
+	if key == "" {
+		key = "result"
+	}
+	return goraff.FollowIfKeyMatches(c.Node, key, c.Equals)
+}
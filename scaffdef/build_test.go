@@ -0,0 +1,207 @@
+package scaffdef_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/mocks"
+	"github.com/lordtatty/goraff/scaffdef"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	assert := assert.New(t)
+	d, err := scaffdef.Parse([]byte(`{
+		"entrypoint": "start",
+		"blocks": [
+			{"name": "start", "type": "input", "from_node": "inputs", "from_key": "topic"},
+			{"name": "ask", "type": "llm", "provider": "groq", "model": "big", "system": "be brief", "user": "say yes"},
+			{"name": "yes", "type": "input", "value": "followed"},
+			{"name": "no", "type": "input", "value": "skipped"}
+		],
+		"joins": [
+			{"from": "start", "to": "ask"},
+			{"from": "ask", "to": "yes", "if": {"node": "ask", "equals": "yes"}},
+			{"from": "ask", "to": "no", "if": {"node": "ask", "equals": "no"}}
+		]
+	}`))
+	assert.NoError(err)
+
+	client := mocks.NewLLMClient(t)
+	client.EXPECT().Chat("be brief", mock.Anything, mock.Anything).
+		RunAndReturn(func(systemMsg, userMsg string, stream chan string) (string, error) {
+			stream <- "yes"
+			return "yes", nil
+		})
+	sut := &scaffdef.Builder{Clients: func(provider, model string) (blockactions.LLMClient, error) {
+		assert.Equal("groq", provider)
+		assert.Equal("big", model)
+		return client, nil
+	}}
+	s, err := sut.Build(d)
+	assert.NoError(err)
+
+	g := &goraff.Graph{}
+	g.NewNode("inputs", nil).SetStr("topic", "otters")
+	assert.NoError(s.Go(g))
+	r := goraff.NewReadableGraph(g)
+	assert.Equal([]string{"inputs", "start", "ask", "yes"}, r.NodeNames())
+	start, err := r.FirstNodeByName("start")
+	assert.NoError(err)
+	assert.Equal("otters", start.FirstStr("result"))
+}
+
+func TestBuilder_BuildSubScaffs(t *testing.T) {
+	assert := assert.New(t)
+	d, err := scaffdef.Parse([]byte(`{
+		"entrypoint": "items",
+		"blocks": [
+			{"name": "items", "type": "input", "value": "a"},
+			{"name": "each", "type": "fanout", "in_node": "items", "out_node": "copy", "scaff": {
+				"entrypoint": "copy",
+				"blocks": [{"name": "copy", "type": "input", "from_node": "items"}]
+			}}
+		],
+		"joins": [{"from": "items", "to": "each"}]
+	}`))
+	assert.NoError(err)
+	s, err := (&scaffdef.Builder{}).Build(d)
+	assert.NoError(err)
+
+	g := &goraff.Graph{}
+	assert.NoError(s.Go(g))
+	each, err := goraff.NewReadableGraph(g).FirstNodeByName("each")
+	assert.NoError(err)
+	assert.Equal([]string{"a"}, each.AllStr("result"))
+}
+
+func TestBuilder_BuildHumanInput(t *testing.T) {
+	assert := assert.New(t)
+	d, err := scaffdef.Parse([]byte(`{
+		"entrypoint": "approve",
+		"blocks": [{"name": "approve", "type": "human_input", "prompt": "Ship it?",
+			"schema": {"type": "string"}, "options": ["yes", "no"], "timeout": "10ms", "default": "no"}]
+	}`))
+	assert.NoError(err)
+	sut := &scaffdef.Builder{}
+	s, err := sut.Build(d)
+	assert.NoError(err)
+	assert.NotNil(sut.Inbox)
+
+	h := s.Blocks().Get("approve").Action.(*blockactions.HumanInput)
+	assert.Same(sut.Inbox, h.Inbox)
+	assert.Equal(`{"type": "string"}`, h.Schema)
+	assert.Equal([]string{"yes", "no"}, h.Options)
+	assert.Equal(10*time.Millisecond, h.Timeout)
+
+	// nobody answers, so the default is used
+	g := &goraff.Graph{}
+	assert.NoError(s.Go(g))
+	n, err := goraff.NewReadableGraph(g).FirstNodeByName("approve")
+	assert.NoError(err)
+	assert.Equal("no", n.FirstStr("result"))
+}
+
+func TestBuilder_BuildWait(t *testing.T) {
+	assert := assert.New(t)
+	d, err := scaffdef.Parse([]byte(`{"entrypoint": "hook", "blocks": [{"name": "hook", "type": "wait", "event": "paid"}]}`))
+	assert.NoError(err)
+	s, err := (&scaffdef.Builder{}).Build(d)
+	assert.NoError(err)
+
+	g := &goraff.Graph{}
+	assert.Equal(goraff.ErrRunSuspended{Events: []string{"paid"}}, s.Go(g))
+	assert.NoError(s.Resume(g, goraff.Event{Name: "paid", Data: []byte("receipt")}))
+	assert.Equal("receipt", g.FirstNodeByName("hook").Get().FirstStr("result"))
+}
+
+func TestBuilder_BuildHTTP(t *testing.T) {
+	assert := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/orders/7", r.URL.Path)
+		_, _ = w.Write([]byte(`{"order": {"state": "paid"}}`))
+	}))
+	defer srv.Close()
+	d, err := scaffdef.Parse([]byte(`{"entrypoint": "id", "blocks": [
+		{"name": "id", "type": "input", "value": "7"},
+		{"name": "fetch", "type": "http", "url": "` + srv.URL + `/orders/{{trigger}}", "timeout": "5s", "extract": {"state": "order.state"}}
+	], "joins": [{"from": "id", "to": "fetch"}]}`))
+	assert.NoError(err)
+	s, err := (&scaffdef.Builder{}).Build(d)
+	assert.NoError(err)
+
+	g := &goraff.Graph{}
+	assert.NoError(s.Go(g))
+	assert.Equal("paid", g.FirstNodeByName("fetch").Get().FirstStr("state"))
+}
+
+func TestBuilder_BuildTemplate(t *testing.T) {
+	assert := assert.New(t)
+	d, err := scaffdef.Parse([]byte(`{"entrypoint": "name", "blocks": [
+		{"name": "name", "type": "input", "value": "sam"},
+		{"name": "greet", "type": "template", "text": "hello {{trigger}}", "key": "greeting"}
+	], "joins": [{"from": "name", "to": "greet"}]}`))
+	assert.NoError(err)
+	s, err := (&scaffdef.Builder{}).Build(d)
+	assert.NoError(err)
+
+	g := &goraff.Graph{}
+	assert.NoError(s.Go(g))
+	assert.Equal("hello sam", g.FirstNodeByName("greet").Get().FirstStr("greeting"))
+}
+
+func TestBuilder_BuildPrompts(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(dir, "brief.tmpl"), []byte("Answer in one word."), 0o644))
+	d, err := scaffdef.Parse([]byte(`{"entrypoint": "topic", "prompts": "` + dir + `", "blocks": [
+		{"name": "topic", "type": "input", "value": "otters"},
+		{"name": "lines", "type": "scaff", "scaff": {"entrypoint": "ask", "blocks": [
+			{"name": "ask", "type": "llm", "provider": "groq", "system": "{{template \"brief\"}}", "user": "Say something"}
+		]}},
+		{"name": "ask", "type": "llm", "provider": "groq", "system": "{{template \"brief\"}}", "user": "Describe {{trigger}}"}
+	], "joins": [{"from": "topic", "to": "ask"}, {"from": "topic", "to": "lines"}]}`))
+	assert.NoError(err)
+
+	client := mocks.NewLLMClient(t)
+	client.EXPECT().Chat("Answer in one word.", "Describe otters", mock.Anything).Return("", nil)
+	client.EXPECT().Chat("Answer in one word.", "Say something", mock.Anything).Return("", nil)
+	sut := &scaffdef.Builder{Clients: func(provider, model string) (blockactions.LLMClient, error) {
+		return client, nil
+	}}
+	s, err := sut.Build(d)
+	assert.NoError(err)
+	assert.NoError(s.Go(&goraff.Graph{}))
+}
+
+func TestBuilder_BuildInvalid(t *testing.T) {
+	assert := assert.New(t)
+	_, err := (&scaffdef.Builder{}).Build(&scaffdef.Def{})
+	assert.ErrorContains(err, "invalid scaff definition")
+}
+
+func TestEnvClients(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv(scaffdef.EnvGroqAPIKey, "")
+	_, err := scaffdef.EnvClients(scaffdef.ProviderGroq, "")
+	assert.EqualError(err, "GROQ_API_KEY is not set")
+
+	t.Setenv(scaffdef.EnvGroqAPIKey, "key")
+	c, err := scaffdef.EnvClients(scaffdef.ProviderGroq, "model")
+	assert.NoError(err)
+	assert.Equal("model", c.(blockactions.LLMModel).ModelName())
+
+	c, err = scaffdef.EnvClients(scaffdef.ProviderOllama, "")
+	assert.NoError(err)
+	assert.Equal("ollama", c.(blockactions.LLMModel).Provider())
+
+	_, err = scaffdef.EnvClients("acme", "")
+	assert.EqualError(err, `unknown provider "acme"`)
+}
@@ -0,0 +1,515 @@
+package scaffdef
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/runs"
+)
+
+// Block types
+const (
+	BlockInput    = "input"
+	BlockLLM      = "llm"
+	BlockPrint    = "print"
+	BlockFanOut   = "fanout"
+	BlockScaff    = "scaff"
+	BlockHuman    = "human_input"
+	BlockWait     = "wait"
+	BlockHTTP     = "http"
+	BlockTemplate = "template"
+)
+
+// LLM providers
+const (
+	ProviderGroq   = "groq"
+	ProviderOllama = "ollama"
+)
+
+// Def describes a scaff in JSON, so scaffs can be written without Go:
+//
+//	{
+//	  "name": "story",
+//	  "entrypoint": "topic",
+//	  "blocks": [
+//	    {"name": "topic", "type": "input", "from_node": "inputs", "from_key": "topic"},
+//	    {"name": "writer", "type": "llm", "provider": "groq", "user": "Write a story about {{trigger}}"}
+//	  ],
+//	  "joins": [
+//	    {"from": "topic", "to": "writer"}
+//	  ]
+//	}
+//
+// LLM messages, template text and http requests are templates, see blockactions.Template.
+// Validate checks that the nodes and keys they read with first, latest and all exist.
+type Def struct {
+	Name       string     `json:"name,omitempty"`
+	Entrypoint string     `json:"entrypoint"`
+	Blocks     []BlockDef `json:"blocks"`
+	Joins      []JoinDef  `json:"joins,omitempty"`
+	// Prompts is a directory of prompt templates that llm blocks include with {{template "name"}},
+	// see blockactions.LoadPrompts. Load makes it relative to the definition's file.
+	// Only the top level scaff's prompts are used, by nested scaffs too.
+	Prompts string `json:"prompts,omitempty"`
+}
+
+// BlockDef describes one block. Which fields apply depends on Type.
+type BlockDef struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// input: Value, or the value of FromKey on the FromNode node, such as the run's inputs
+	Value    string `json:"value,omitempty"`
+	FromNode string `json:"from_node,omitempty"`
+	FromKey  string `json:"from_key,omitempty"`
+
+	// llm: System and User are templates, which can include the definition's Prompts
+	Provider string   `json:"provider,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	System   string   `json:"system,omitempty"`
+	User     string   `json:"user,omitempty"`
+	Include  []string `json:"include,omitempty"`
+
+	// fanout and scaff: the scaff to run, with fanout's inputs and outputs
+	Scaff   *Def   `json:"scaff,omitempty"`
+	InNode  string `json:"in_node,omitempty"`
+	InKey   string `json:"in_key,omitempty"`
+	OutNode string `json:"out_node,omitempty"`
+	OutKey  string `json:"out_key,omitempty"`
+
+	// human_input: Timeout is a duration such as "10m", and Schema any JSON describing the answer.
+	// http uses Timeout too, for each attempt.
+	Prompt  string          `json:"prompt,omitempty"`
+	Schema  json.RawMessage `json:"schema,omitempty"`
+	Options []string        `json:"options,omitempty"`
+	Timeout string          `json:"timeout,omitempty"`
+	Default string          `json:"default,omitempty"`
+
+	// wait: the event the run is resumed with, whose data becomes the block's result
+	Event string `json:"event,omitempty"`
+
+	// http: Method, URL, header values and Body are templates, see blockactions.HTTP.
+	// Extract maps node keys to paths in a JSON response.
+	Method       string            `json:"method,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         string            `json:"body,omitempty"`
+	Retries      int               `json:"retries,omitempty"`
+	Extract      map[string]string `json:"extract,omitempty"`
+	IgnoreStatus bool              `json:"ignore_status,omitempty"`
+
+	// template: Text is rendered over the graph, see blockactions.Template, and written to Key
+	Text string `json:"text,omitempty"`
+	Key  string `json:"key,omitempty"`
+}
+
+// JoinDef connects two blocks, optionally only when If is met
+type JoinDef struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	If   *ConditionDef `json:"if,omitempty"`
+}
+
+// ConditionDef is either a key match, {"node": "check", "key": "result", "equals": "yes"},
+// or a wait for nodes to complete, {"completed": ["a", "b"]}. Key defaults to result.
+type ConditionDef struct {
+	Node      string   `json:"node,omitempty"`
+	Key       string   `json:"key,omitempty"`
+	Equals    string   `json:"equals,omitempty"`
+	Completed []string `json:"completed,omitempty"`
+}
+
+// Parse reads a definition. Unknown fields are an error, to catch typos.
+func Parse(data []byte) (*Def, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	d := &Def{}
+	if err := dec.Decode(d); err != nil {
+		return nil, fmt.Errorf("error parsing scaff definition: %w", err)
+	}
+	return d, nil
+}
+
+// Load reads the definition in the file at path
+func Load(path string) (*Def, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scaff definition: %w", err)
+	}
+	d, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if d.Prompts != "" && !filepath.IsAbs(d.Prompts) {
+		d.Prompts = filepath.Join(filepath.Dir(path), d.Prompts)
+	}
+	return d, nil
+}
+
+// Validate checks the definition's topology and the configuration of every block,
+// including those in nested scaffs, and returns every problem it finds joined together
+func (d *Def) Validate() error {
+	prompts, err := d.loadPrompts()
+	if err != nil {
+		return err
+	}
+	return errors.Join(d.problems("", []string{runs.InputsNode}, prompts)...)
+}
+
+// loadPrompts loads the definition's prompts, if it has any
+func (d *Def) loadPrompts() (*blockactions.Prompts, error) {
+	if d.Prompts == "" {
+		return nil, nil
+	}
+	p, err := blockactions.LoadPrompts(d.Prompts)
+	if err != nil {
+		return nil, fmt.Errorf("error loading prompts: %w", err)
+	}
+	return p, nil
+}
+
+// problems lists what is wrong with the definition. Blocks may refer to the nodes
+// named in known as well as to each other.
+func (d *Def) problems(path string, known []string, prompts *blockactions.Prompts) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s%s", path, fmt.Sprintf(format, args...)))
+	}
+	if len(d.Blocks) == 0 {
+		fail("no blocks")
+	}
+	if path != "" && d.Prompts != "" {
+		fail("prompts can only be set on the top level scaff")
+	}
+	sc := d.scope(known, prompts)
+	seen := map[string]bool{}
+	for i, b := range d.Blocks {
+		if b.Name == "" {
+			fail("block %d has no name", i)
+			continue
+		}
+		if seen[b.Name] {
+			fail("block name not unique: %s", b.Name)
+		}
+		seen[b.Name] = true
+	}
+	if d.Entrypoint == "" {
+		fail("entrypoint not set")
+	} else if !seen[d.Entrypoint] {
+		fail("entrypoint %s is not a block", d.Entrypoint)
+	}
+
+	for _, b := range d.Blocks {
+		errs = append(errs, b.problems(path, sc)...)
+	}
+
+	next := map[string][]string{}
+	for i, j := range d.Joins {
+		if !seen[j.From] {
+			fail("join %d: from block %q not found", i, j.From)
+		}
+		if !seen[j.To] {
+			fail("join %d: to block %q not found", i, j.To)
+		}
+		next[j.From] = append(next[j.From], j.To)
+		if j.If == nil {
+			continue
+		}
+		if err := j.If.problem(sc.names); err != nil {
+			fail("join %d: %s", i, err.Error())
+		}
+	}
+
+	// every block must be reachable, or it can never run
+	reached := map[string]bool{}
+	queue := []string{d.Entrypoint}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if reached[name] {
+			continue
+		}
+		reached[name] = true
+		queue = append(queue, next[name]...)
+	}
+	if seen[d.Entrypoint] {
+		for _, b := range d.Blocks {
+			if b.Name != "" && !reached[b.Name] {
+				fail("block %s is not reachable from the entrypoint", b.Name)
+			}
+		}
+	}
+	return errs
+}
+
+// scope is what the blocks of one scaff can refer to
+type scope struct {
+	names []string
+	// keys holds the keys each block writes, or nil when they cannot be known before it runs
+	keys map[string][]string
+	// blocks are the scaff's blocks by name, so sub paths can be followed into their scaffs
+	blocks map[string]*BlockDef
+	// from holds the blocks that join into each block, and so can trigger it
+	from       map[string][]string
+	entrypoint string
+	prompts    *blockactions.Prompts
+}
+
+// scope returns what the blocks of d can refer to, with known naming nodes
+// that are in the graph without being blocks
+func (d *Def) scope(known []string, prompts *blockactions.Prompts) *scope {
+	sc := &scope{
+		names:      slices.Clone(known),
+		keys:       map[string][]string{},
+		blocks:     map[string]*BlockDef{},
+		from:       map[string][]string{},
+		entrypoint: d.Entrypoint,
+		prompts:    prompts,
+	}
+	for i := range d.Blocks {
+		b := &d.Blocks[i]
+		if b.Name == "" {
+			continue
+		}
+		sc.names = append(sc.names, b.Name)
+		sc.keys[b.Name] = b.writes()
+		sc.blocks[b.Name] = b
+	}
+	for _, j := range d.Joins {
+		if _, ok := sc.blocks[j.From]; ok && !slices.Contains(sc.from[j.To], j.From) {
+			sc.from[j.To] = append(sc.from[j.To], j.From)
+		}
+	}
+	return sc
+}
+
+// subProblem returns what is wrong with the nodes a sub path reads key from, following
+// the path into the scaffs of fanout and scaff blocks. Wildcard names are not checked.
+func (sc *scope) subProblem(path, key string) error {
+	segments := strings.Split(path, "/")
+	if len(segments)%2 == 0 {
+		return fmt.Errorf("the path must alternate node names and sub-graph selectors")
+	}
+	for i := 0; i < len(segments); i += 2 {
+		name := segments[i]
+		if name == goraff.PathWildcard {
+			return nil
+		}
+		if !slices.Contains(sc.names, name) {
+			return fmt.Errorf("%s is not a block", name)
+		}
+		b := sc.blocks[name]
+		if i == len(segments)-1 {
+			if keys := sc.keys[name]; keys != nil && !slices.Contains(keys, key) {
+				return fmt.Errorf("block %s does not write key %q", name, key)
+			}
+			return nil
+		}
+		if b == nil {
+			return nil
+		}
+		if (b.Type != BlockFanOut && b.Type != BlockScaff) || b.Scaff == nil {
+			return fmt.Errorf("block %s has no sub-graphs", name)
+		}
+		sc = b.Scaff.scope(b.known(), sc.prompts)
+	}
+	return nil
+}
+
+func (b *BlockDef) problems(path string, sc *scope) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%sblock %s: %s", path, b.Name, fmt.Sprintf(format, args...)))
+	}
+	refer := func(field, name string) {
+		if name != "" && !slices.Contains(sc.names, name) {
+			fail("%s %q is not a block", field, name)
+		}
+	}
+	// tmpl checks the nodes and keys a template reads. Only llm messages can include prompts.
+	tmpl := func(field, text string, prompts *blockactions.Prompts) {
+		refs, err := blockactions.TemplateRefs(text, prompts)
+		if err != nil {
+			fail("%s: %s", field, err.Error())
+			return
+		}
+		for _, ref := range refs {
+			switch ref.Func {
+			case "trigger":
+				// the entrypoint can be triggered by the run's inputs, or by nothing
+				if b.Name == sc.entrypoint {
+					continue
+				}
+				for _, from := range sc.from[b.Name] {
+					if keys := sc.keys[from]; keys != nil && !slices.Contains(keys, ref.Key) {
+						fail("%s reads key %q from its trigger, which block %s does not write", field, ref.Key, from)
+					}
+				}
+				continue
+			case "sub":
+				if err := sc.subProblem(ref.Node, ref.Key); err != nil {
+					fail("%s reads %q: %s", field, ref.Node, err.Error())
+				}
+				continue
+			}
+			if !slices.Contains(sc.names, ref.Node) {
+				fail("%s reads %q, which is not a block", field, ref.Node)
+			} else if keys := sc.keys[ref.Node]; keys != nil && !slices.Contains(keys, ref.Key) {
+				fail("%s reads key %q, which block %s does not write", field, ref.Key, ref.Node)
+			}
+		}
+	}
+	switch b.Type {
+	case BlockInput:
+		refer("from_node", b.FromNode)
+	case BlockLLM:
+		if b.System == "" && b.User == "" {
+			fail("llm needs a system or user message")
+		}
+		switch b.Provider {
+		case ProviderGroq, ProviderOllama:
+		case "":
+			fail("llm needs a provider")
+		default:
+			fail("unknown provider %q", b.Provider)
+		}
+		for _, inc := range b.Include {
+			refer("include", inc)
+		}
+		tmpl("system", b.System, sc.prompts)
+		tmpl("user", b.User, sc.prompts)
+	case BlockPrint:
+	case BlockFanOut, BlockScaff:
+		if b.Scaff == nil {
+			fail("%s needs a scaff", b.Type)
+			break
+		}
+		refer("in_node", b.InNode)
+		errs = append(errs, b.Scaff.problems(path+b.Name+" > ", b.known(), sc.prompts)...)
+	case BlockHuman:
+		if b.Prompt == "" {
+			fail("human_input needs a prompt")
+		}
+		if b.Timeout != "" && !positive(b.Timeout) {
+			fail("timeout %q is not a positive duration", b.Timeout)
+		}
+		if b.Default != "" && b.Timeout == "" {
+			fail("default is only used after a timeout")
+		}
+		if b.Default != "" && len(b.Options) > 0 && !slices.Contains(b.Options, b.Default) {
+			fail("default %q is not one of the options", b.Default)
+		}
+	case BlockHTTP:
+		if b.URL == "" {
+			fail("http needs a url")
+		}
+		if b.Timeout != "" && !positive(b.Timeout) {
+			fail("timeout %q is not a positive duration", b.Timeout)
+		}
+		if b.Retries < 0 {
+			fail("retries must not be negative")
+		}
+		tmpl("method", b.Method, nil)
+		tmpl("url", b.URL, nil)
+		tmpl("body", b.Body, nil)
+		for _, name := range sortedKeys(b.Headers) {
+			tmpl("header "+name, b.Headers[name], nil)
+		}
+	case BlockTemplate:
+		if b.Text == "" {
+			fail("template needs text")
+		}
+		tmpl("text", b.Text, nil)
+	case BlockWait:
+		if b.Event == "" {
+			fail("wait needs an event")
+		}
+		if path != "" {
+			// runs can only be resumed from blocks of their top level graph
+			fail("wait can only be used in the top level scaff")
+		}
+	case "":
+		fail("no type")
+	default:
+		fail("unknown type %q", b.Type)
+	}
+	return errs
+}
+
+// known names the nodes the graphs of a fanout or scaff block's scaff start with
+func (b *BlockDef) known() []string {
+	if b.Type == BlockFanOut && b.InNode != "" {
+		// each of the sub-scaff's graphs starts with the item in a node named InNode
+		return []string{b.InNode}
+	}
+	return nil
+}
+
+// writes returns the keys the block writes, or nil when they cannot be known before it runs
+func (b *BlockDef) writes() []string {
+	switch b.Type {
+	case BlockInput, BlockWait:
+		return []string{"result"}
+	case BlockLLM:
+		return []string{blockactions.LLMKeyResult, blockactions.LLMKeySystemMsg, blockactions.LLMKeyUserMsg}
+	case BlockPrint:
+		return []string{}
+	case BlockHuman:
+		return []string{"result", blockactions.HumanKeyPrompt, blockactions.HumanKeySchema, blockactions.HumanKeyOptions, blockactions.HumanKeyState}
+	case BlockHTTP:
+		return append([]string{
+			blockactions.HTTPKeyBody, blockactions.HTTPKeyMethod, blockactions.HTTPKeyURL,
+			blockactions.HTTPKeyStatus, blockactions.HTTPKeyHeaders,
+		}, sortedKeys(b.Extract)...)
+	case BlockTemplate:
+		if b.Key != "" {
+			return []string{b.Key}
+		}
+		return []string{"result"}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// positive reports whether s is a duration greater than zero
+func positive(s string) bool {
+	d, err := time.ParseDuration(s)
+	return err == nil && d > 0
+}
+
+func (c *ConditionDef) problem(names []string) error {
+	switch {
+	case c.Node != "" && len(c.Completed) > 0:
+		return fmt.Errorf("condition has both node and completed")
+	case c.Node != "":
+		if !slices.Contains(names, c.Node) {
+			return fmt.Errorf("condition node %q is not a block", c.Node)
+		}
+	case len(c.Completed) > 0:
+		for _, name := range c.Completed {
+			if !slices.Contains(names, name) {
+				return fmt.Errorf("condition waits for %q, which is not a block", name)
+			}
+		}
+	default:
+		return fmt.Errorf("condition needs a node or completed")
+	}
+	return nil
+}
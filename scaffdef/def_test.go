@@ -0,0 +1,244 @@
+package scaffdef_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lordtatty/goraff/scaffdef"
+	"github.com/stretchr/testify/assert"
+)
+
+const storyDef = `{
+	"name": "story",
+	"entrypoint": "topic",
+	"blocks": [
+		{"name": "topic", "type": "input", "from_node": "inputs", "from_key": "topic", "value": "otters"},
+		{"name": "writer", "type": "llm", "provider": "groq", "user": "Write a story", "include": ["topic"]},
+		{"name": "lines", "type": "fanout", "in_node": "writer", "scaff": {
+			"entrypoint": "shout",
+			"blocks": [{"name": "shout", "type": "input", "from_node": "writer"}]
+		}},
+		{"name": "print", "type": "print"}
+	],
+	"joins": [
+		{"from": "topic", "to": "writer"},
+		{"from": "writer", "to": "lines"},
+		{"from": "lines", "to": "print", "if": {"completed": ["lines"]}}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	d, err := scaffdef.Parse([]byte(storyDef))
+	assert.NoError(err)
+	assert.Equal("story", d.Name)
+	assert.Equal("topic", d.Entrypoint)
+	assert.Len(d.Blocks, 4)
+	assert.Equal("shout", d.Blocks[2].Scaff.Entrypoint)
+	assert.Equal([]string{"lines"}, d.Joins[2].If.Completed)
+	assert.NoError(d.Validate())
+}
+
+func TestParse_UnknownField(t *testing.T) {
+	assert := assert.New(t)
+	_, err := scaffdef.Parse([]byte(`{"entrypoint": "a", "blocks": [{"name": "a", "type": "print", "colour": "red"}]}`))
+	assert.ErrorContains(err, `unknown field "colour"`)
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "story.json")
+	assert.NoError(os.WriteFile(path, []byte(storyDef), 0o644))
+	d, err := scaffdef.Load(path)
+	assert.NoError(err)
+	assert.Equal("story", d.Name)
+
+	_, err = scaffdef.Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(err, "error reading scaff definition")
+}
+
+func TestLoad_Prompts(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	assert.NoError(os.Mkdir(filepath.Join(dir, "prompts"), 0o755))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "prompts", "style.tmpl"), []byte(`Write about {{first "topic" "subject"}}.`), 0o644))
+	path := filepath.Join(dir, "story.json")
+	assert.NoError(os.WriteFile(path, []byte(`{"entrypoint": "topic", "prompts": "prompts", "blocks": [
+		{"name": "topic", "type": "input", "value": "otters"},
+		{"name": "writer", "type": "llm", "provider": "groq", "user": "{{template \"style\"}}"}
+	], "joins": [{"from": "topic", "to": "writer"}]}`), 0o644))
+
+	d, err := scaffdef.Load(path)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dir, "prompts"), d.Prompts)
+	// the prompt is checked as part of the message that includes it
+	assert.EqualError(d.Validate(), `block writer: user reads key "subject", which block topic does not write`)
+
+	assert.NoError(os.WriteFile(filepath.Join(dir, "prompts", "style.tmpl"), []byte(`{{`), 0o644))
+	assert.ErrorContains(d.Validate(), "error loading prompts")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want []string
+	}{
+		{
+			name: "no blocks",
+			def:  `{"entrypoint": "a"}`,
+			want: []string{"no blocks", "entrypoint a is not a block"},
+		},
+		{
+			name: "names",
+			def:  `{"blocks": [{"type": "print"}, {"name": "a", "type": "print"}, {"name": "a", "type": "print"}]}`,
+			want: []string{"block 0 has no name", "block name not unique: a", "entrypoint not set"},
+		},
+		{
+			name: "config",
+			def: `{"entrypoint": "a", "blocks": [
+				{"name": "a", "type": "llm", "include": ["nope"]},
+				{"name": "b", "type": "llm", "provider": "acme", "user": "hi"},
+				{"name": "c", "type": "fanout"},
+				{"name": "d", "type": "widget"},
+				{"name": "e"}
+			], "joins": [{"from": "a", "to": "b"}, {"from": "a", "to": "c"}, {"from": "a", "to": "d"}, {"from": "a", "to": "e"}]}`,
+			want: []string{
+				"block a: llm needs a system or user message",
+				"block a: llm needs a provider",
+				`block a: include "nope" is not a block`,
+				`block b: unknown provider "acme"`,
+				"block c: fanout needs a scaff",
+				`block d: unknown type "widget"`,
+				"block e: no type",
+			},
+		},
+		{
+			name: "joins",
+			def: `{"entrypoint": "a", "blocks": [{"name": "a", "type": "print"}, {"name": "b", "type": "print"}, {"name": "c", "type": "print"}], "joins": [
+				{"from": "a", "to": "x"},
+				{"from": "y", "to": "c"},
+				{"from": "a", "to": "b", "if": {"node": "z"}},
+				{"from": "a", "to": "b", "if": {}}
+			]}`,
+			want: []string{
+				`join 0: to block "x" not found`,
+				`join 1: from block "y" not found`,
+				`join 2: condition node "z" is not a block`,
+				"join 3: condition needs a node or completed",
+				"block c is not reachable from the entrypoint",
+			},
+		},
+		{
+			name: "human input",
+			def: `{"entrypoint": "a", "blocks": [
+				{"name": "a", "type": "human_input", "timeout": "soon", "default": "x"},
+				{"name": "b", "type": "human_input", "prompt": "ok?", "options": ["yes", "no"], "timeout": "1m", "default": "maybe"},
+				{"name": "c", "type": "human_input", "prompt": "ok?", "default": "yes"}
+			], "joins": [{"from": "a", "to": "b"}, {"from": "a", "to": "c"}]}`,
+			want: []string{
+				"block a: human_input needs a prompt",
+				`block a: timeout "soon" is not a positive duration`,
+				`block b: default "maybe" is not one of the options`,
+				"block c: default is only used after a timeout",
+			},
+		},
+		{
+			name: "http",
+			def: `{"entrypoint": "a", "blocks": [
+				{"name": "a", "type": "http", "timeout": "0s", "retries": -1},
+				{"name": "b", "type": "http", "url": "http://example.com", "timeout": "5s"}
+			], "joins": [{"from": "a", "to": "b"}]}`,
+			want: []string{
+				"block a: http needs a url",
+				`block a: timeout "0s" is not a positive duration`,
+				"block a: retries must not be negative",
+			},
+		},
+		{
+			name: "template",
+			def:  `{"entrypoint": "a", "blocks": [{"name": "a", "type": "template"}]}`,
+			want: []string{"block a: template needs text"},
+		},
+		{
+			name: "templates",
+			def: `{"entrypoint": "a", "blocks": [
+				{"name": "a", "type": "llm", "provider": "groq", "system": "{{first \"nope\"}}", "user": "{{template \"style\"}}"},
+				{"name": "b", "type": "http", "url": "{{first \"a\" \"llm_user_msg\"}}", "headers": {"X": "{{first \"a\" \"tokens\"}}"}, "extract": {"id": "id"}},
+				{"name": "c", "type": "template", "text": "{{latest \"b\" \"id\"}} {{all \"b\" \"name\"}} {{first \"inputs\" \"anything\"}} {{trigger \"id\"}}", "key": "out"},
+				{"name": "d", "type": "template", "text": "{{first \"c\"}}"},
+				{"name": "e", "type": "template", "text": "{{first"}
+			], "joins": [{"from": "a", "to": "b"}, {"from": "b", "to": "c"}, {"from": "c", "to": "d"}, {"from": "c", "to": "e"}]}`,
+			want: []string{
+				`block a: system reads "nope", which is not a block`,
+				`block a: user: no prompt named "style"`,
+				`block b: header X reads key "tokens", which block a does not write`,
+				`block c: text reads key "name", which block b does not write`,
+				`block d: text reads key "result", which block c does not write`,
+				"block e: text: template: refs:1: unclosed action",
+			},
+		},
+		{
+			name: "template trigger",
+			def: `{"entrypoint": "a", "blocks": [
+				{"name": "a", "type": "template", "text": "{{trigger \"anything\"}}"},
+				{"name": "b", "type": "template", "text": "x", "key": "title"},
+				{"name": "c", "type": "template", "text": "{{trigger \"title\"}}"},
+				{"name": "d", "type": "print"}
+			], "joins": [{"from": "a", "to": "b"}, {"from": "b", "to": "c"}, {"from": "a", "to": "c"}, {"from": "c", "to": "d"}, {"from": "d", "to": "c"}]}`,
+			// every block joining into c can trigger it, so each must write the key
+			want: []string{`block c: text reads key "title" from its trigger, which block a does not write`},
+		},
+		{
+			name: "template sub",
+			def: `{"entrypoint": "a", "blocks": [
+				{"name": "a", "type": "fanout", "in_node": "item", "scaff": {"entrypoint": "upper", "blocks": [
+					{"name": "upper", "type": "template", "text": "{{first \"item\"}}", "key": "upper"}
+				]}},
+				{"name": "b", "type": "template", "text": "{{sub \"a/*/upper\" \"upper\"}} {{sub \"a/0/item\"}} {{sub \"a/*/*\" \"any\"}}"},
+				{"name": "c", "type": "template", "text": "{{sub \"a/*/upper\"}}"},
+				{"name": "d", "type": "template", "text": "{{sub \"a/*/nope\"}} {{sub \"b/*/x\"}} {{sub \"x/*/y\"}} {{sub \"a/*\"}}"}
+			], "joins": [{"from": "a", "to": "b"}, {"from": "b", "to": "c"}, {"from": "c", "to": "d"}]}`,
+			want: []string{
+				`block c: text reads "a/*/upper": block upper does not write key "result"`,
+				`block d: text reads "a/*/nope": nope is not a block`,
+				`block d: text reads "b/*/x": block b has no sub-graphs`,
+				`block d: text reads "x/*/y": x is not a block`,
+				`block d: text reads "a/*": the path must alternate node names and sub-graph selectors`,
+			},
+		},
+		{
+			name: "wait",
+			def: `{"entrypoint": "a", "blocks": [
+				{"name": "a", "type": "wait"},
+				{"name": "b", "type": "scaff", "scaff": {"entrypoint": "c", "blocks": [{"name": "c", "type": "wait", "event": "go"}]}}
+			], "joins": [{"from": "a", "to": "b"}]}`,
+			want: []string{
+				"block a: wait needs an event",
+				"b > block c: wait can only be used in the top level scaff",
+			},
+		},
+		{
+			name: "nested",
+			def: `{"entrypoint": "a", "blocks": [{"name": "a", "type": "scaff", "scaff": {
+				"entrypoint": "inner",
+				"blocks": [{"name": "inner", "type": "input", "from_node": "inputs"}]
+			}}]}`,
+			// a scaff block's graph starts empty, without the run's inputs
+			want: []string{`a > block inner: from_node "inputs" is not a block`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			d, err := scaffdef.Parse([]byte(tt.def))
+			assert.NoError(err)
+			err = d.Validate()
+			assert.Error(err)
+			for _, w := range tt.want {
+				assert.ErrorContains(err, w)
+			}
+		})
+	}
+}
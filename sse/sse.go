@@ -0,0 +1,149 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lordtatty/goraff/outputs"
+)
+
+const (
+	defaultKeepAlive = 15 * time.Second
+	defaultBuffer    = 256
+)
+
+// Handler streams graph update messages as Server-Sent Events, for clients that cannot use websockets.
+//
+// Each event's id is the message's seq and its event name is the message type, snapshot or patch,
+// with the message JSON as data. Clients resume with the Last-Event-ID header, which browsers send
+// when they reconnect, or with ?since=<seq>. ?graph_id=<id> picks the graph to follow, which may
+// be a sub-graph: snapshots are sent whole, and patches only when they change that graph or one nested in it.
+type Handler struct {
+	// Stream is followed when no graph_id is given, or when it is the stream's graph or one of its sub-graphs
+	Stream *outputs.Stream
+	// Lookup, when set, finds the stream for a graph_id that is not Stream's
+	Lookup func(graphID string) (*outputs.Stream, error)
+	// KeepAlive is how often a comment is sent to keep idle connections open. Defaults to 15 seconds.
+	KeepAlive time.Duration
+	// Buffer is the number of messages held for a client before it is disconnected. Defaults to 256.
+	Buffer int
+}
+
+func (h *Handler) stream(graphID string) (*outputs.Stream, error) {
+	if h.Stream != nil && (graphID == "" || h.Stream.Contains(graphID)) {
+		return h.Stream, nil
+	}
+	if graphID == "" {
+		return nil, fmt.Errorf("graph_id is required")
+	}
+	if h.Lookup == nil {
+		return nil, fmt.Errorf("graph %s not found", graphID)
+	}
+	return h.Lookup(graphID)
+}
+
+func since(r *http.Request) (uint64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("since")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("last event id must be a sequence number")
+	}
+	return n, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	seq, err := since(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	graphID := r.URL.Query().Get("graph_id")
+	st, err := h.stream(graphID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	buffer := h.Buffer
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	keepAlive := h.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = defaultKeepAlive
+	}
+
+	backlog, ch, cancel := st.Subscribe(seq, buffer)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, m := range backlog {
+		if !follows(m, graphID) {
+			continue
+		}
+		if err := writeEvent(w, m); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				// fell behind; the client reconnects with its last event id
+				return
+			}
+			if !follows(m, graphID) {
+				continue
+			}
+			if err := writeEvent(w, m); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// follows reports whether a client following graphID is sent the message
+func follows(m outputs.Message, graphID string) bool {
+	return graphID == "" || m.Snapshot != nil || slices.Contains(m.GraphIDs(), graphID)
+}
+
+func writeEvent(w http.ResponseWriter, m outputs.Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("error marshalling message: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", m.Seq, m.Type, b); err != nil {
+		return fmt.Errorf("error writing event: %w", err)
+	}
+	return nil
+}
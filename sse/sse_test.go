@@ -0,0 +1,194 @@
+package sse_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/notifiers"
+	"github.com/lordtatty/goraff/outputs"
+	"github.com/lordtatty/goraff/sse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	id   string
+	name string
+	msg  outputs.Message
+}
+
+type eventReader struct {
+	t       *testing.T
+	scanner *bufio.Scanner
+	cancel  func()
+}
+
+func connect(t *testing.T, url string, header map[string]string) *eventReader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	t.Cleanup(func() {
+		cancel()
+		resp.Body.Close()
+	})
+	return &eventReader{t: t, scanner: bufio.NewScanner(resp.Body), cancel: cancel}
+}
+
+func (e *eventReader) next() event {
+	e.t.Helper()
+	ev := event{}
+	for e.scanner.Scan() {
+		line := e.scanner.Text()
+		switch {
+		case line == "":
+			if ev.name != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(e.t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.msg))
+		}
+	}
+	e.t.Fatalf("stream ended: %v", e.scanner.Err())
+	return ev
+}
+
+func setup() (*goraff.Graph, *outputs.Stream) {
+	notifier := &notifiers.GraphNotifier{}
+	g := &goraff.Graph{Notifier: notifier}
+	st := outputs.NewStream(outputs.NewDiffer(goraff.NewReadableGraph(g)), 0)
+	notifier.Listen(st.Notify)
+	return g, st
+}
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+	g, st := setup()
+	n := g.NewNode("node1", nil)
+	n.SetStr("key", "before")
+	srv := httptest.NewServer(&sse.Handler{Stream: st})
+	t.Cleanup(srv.Close)
+
+	r := connect(t, srv.URL, nil)
+	snap := r.next()
+	assert.Equal("snapshot", snap.name)
+	assert.Equal(strconv.FormatUint(snap.msg.Seq, 10), snap.id)
+	assert.Equal("before", snap.msg.Snapshot.Nodes[0].Vals[0].Values[0])
+
+	n.SetStr("key", "live")
+	live := r.next()
+	assert.Equal("patch", live.name)
+	assert.Equal("live", live.msg.Patch.Value)
+	r.cancel()
+
+	// browsers resume with Last-Event-ID
+	n.SetStr("key", "missed")
+	r = connect(t, srv.URL, map[string]string{"Last-Event-ID": live.id})
+	missed := r.next()
+	assert.Equal("patch", missed.name)
+	assert.Equal("missed", missed.msg.Patch.Value)
+	r.cancel()
+
+	// or with ?since= when headers cannot be set
+	r = connect(t, srv.URL+"?since="+live.id, nil)
+	assert.Equal("missed", r.next().msg.Patch.Value)
+}
+
+func TestHandler_SubGraph(t *testing.T) {
+	assert := assert.New(t)
+	g, st := setup()
+	parent := g.NewNode("fanout", nil)
+	sub := &goraff.Graph{}
+	parent.AddSubGraph(sub)
+	other := &goraff.Graph{}
+	parent.AddSubGraph(other)
+	subID := goraff.NewReadableGraph(sub).ID()
+	srv := httptest.NewServer(&sse.Handler{Stream: st})
+	t.Cleanup(srv.Close)
+
+	r := connect(t, srv.URL+"?graph_id="+subID, nil)
+	assert.Equal("snapshot", r.next().name)
+	// changes to other graphs are left out
+	other.NewNode("skipped", nil)
+	g.NewNode("skipped", nil)
+	sub.NewNode("item", nil)
+	ev := r.next()
+	assert.Equal("patch", ev.name)
+	assert.Equal(subID, ev.msg.Patch.GraphID)
+	assert.Equal("item", ev.msg.Patch.NodeName)
+}
+
+func TestHandler_GraphID(t *testing.T) {
+	assert := assert.New(t)
+	g1, st1 := setup()
+	g2, st2 := setup()
+	g2.NewNode("other", nil)
+	lookup := func(id string) (*outputs.Stream, error) {
+		if id == st2.GraphID() {
+			return st2, nil
+		}
+		return nil, fmt.Errorf("graph %s not found", id)
+	}
+	srv := httptest.NewServer(&sse.Handler{Stream: st1, Lookup: lookup})
+	t.Cleanup(srv.Close)
+
+	g1.NewNode("mine", nil)
+	r := connect(t, srv.URL+"?graph_id="+st1.GraphID(), nil)
+	assert.Equal("mine", r.next().msg.Snapshot.Nodes[0].Name)
+	r = connect(t, srv.URL+"?graph_id="+st2.GraphID(), nil)
+	assert.Equal("other", r.next().msg.Snapshot.Nodes[0].Name)
+
+	resp, err := http.Get(srv.URL + "?graph_id=missing")
+	assert.NoError(err)
+	assert.Equal(404, resp.StatusCode)
+	resp.Body.Close()
+	resp, err = http.Get(srv.URL + "?since=abc")
+	assert.NoError(err)
+	assert.Equal(400, resp.StatusCode)
+	resp.Body.Close()
+
+	noDefault := httptest.NewServer(&sse.Handler{Lookup: lookup})
+	t.Cleanup(noDefault.Close)
+	resp, err = http.Get(noDefault.URL)
+	assert.NoError(err)
+	assert.Equal(404, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestHandler_KeepAlive(t *testing.T) {
+	assert := assert.New(t)
+	_, st := setup()
+	srv := httptest.NewServer(&sse.Handler{Stream: st, KeepAlive: 10 * time.Millisecond})
+	t.Cleanup(srv.Close)
+
+	r := connect(t, srv.URL, nil)
+	r.next()
+	found := false
+	for r.scanner.Scan() {
+		if r.scanner.Text() == ": keep-alive" {
+			found = true
+			break
+		}
+	}
+	assert.True(found)
+}
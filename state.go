@@ -0,0 +1,144 @@
+package goraff
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GraphState is a snapshot of a graph's nodes, their values and their sub-graphs,
+// for saving a suspended run and restoring it later, possibly in another process
+type GraphState struct {
+	ID    string      `json:"id"`
+	Nodes []NodeState `json:"nodes"`
+}
+
+// NodeState is a snapshot of a node. Journals are not kept.
+type NodeState struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Values     map[string][][]byte `json:"values,omitempty"`
+	Done       bool                `json:"done,omitempty"`
+	Err        string              `json:"error,omitempty"`
+	WaitingFor string              `json:"waiting_for,omitempty"`
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt time.Time           `json:"finished_at"`
+	// TriggeredBy holds the IDs of the nodes that triggered this one
+	TriggeredBy []string     `json:"triggered_by,omitempty"`
+	SubGraphs   []GraphState `json:"sub_graphs,omitempty"`
+}
+
+// State returns a snapshot of the graph and its sub-graphs
+func (s *ReadableGraph) State() GraphState {
+	st := GraphState{ID: s.ID(), Nodes: []NodeState{}}
+	for _, n := range s.graph.allNodes() {
+		st.Nodes = append(st.Nodes, n.snapshot())
+	}
+	return st
+}
+
+func (n *Node) snapshot() NodeState {
+	r := n.Get()
+	n.mut.Lock()
+	st := NodeState{
+		ID:         n.id,
+		Name:       n.name,
+		Done:       n.done,
+		WaitingFor: n.waitingFor,
+		StartedAt:  n.startedAt,
+		FinishedAt: n.finishedAt,
+	}
+	if n.err != nil {
+		st.Err = n.err.Error()
+	}
+	if len(n.state) > 0 {
+		st.Values = map[string][][]byte{}
+		for k, vals := range n.state {
+			st.Values[k] = append([][]byte{}, vals...)
+		}
+	}
+	for _, t := range n.triggeredBy {
+		st.TriggeredBy = append(st.TriggeredBy, t.ID())
+	}
+	n.mut.Unlock()
+	for _, sub := range r.SubGraph() {
+		st.SubGraphs = append(st.SubGraphs, sub.State())
+	}
+	return st
+}
+
+// Restore fills an empty graph with the snapshot, keeping its graph and node IDs.
+// No changes are notified and no journal entries recorded, so set the graph's Notifier,
+// Hooks and Journal before restoring for sub-graphs to share them, as AddSubGraph does.
+// Errors are restored with their message only.
+func (s *Graph) Restore(st GraphState) error {
+	s.mut.Lock()
+	if len(s.nodes) > 0 {
+		s.mut.Unlock()
+		return fmt.Errorf("graph already has nodes")
+	}
+	s.id = st.ID
+	s.mut.Unlock()
+	for _, ns := range st.Nodes {
+		if err := s.restoreNode(ns); err != nil {
+			return fmt.Errorf("error restoring node %s: %w", ns.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Graph) restoreNode(st NodeState) error {
+	if st.ID == "" {
+		return fmt.Errorf("node has no id")
+	}
+	if s.NodeByID(st.ID) != nil {
+		return fmt.Errorf("node id is not unique")
+	}
+	n := &Node{
+		id:         st.ID,
+		name:       st.Name,
+		done:       st.Done,
+		waitingFor: st.WaitingFor,
+		startedAt:  st.StartedAt,
+		finishedAt: st.FinishedAt,
+		graph:      s,
+	}
+	if st.Err != "" {
+		n.err = errors.New(st.Err)
+	}
+	if len(st.Values) > 0 {
+		n.state = map[string][][]byte{}
+		for k, vals := range st.Values {
+			n.state[k] = append([][]byte{}, vals...)
+		}
+	}
+	for _, id := range st.TriggeredBy {
+		t := s.NodeByID(id)
+		if t == nil {
+			return fmt.Errorf("triggered by unknown node %s", id)
+		}
+		n.triggeredBy = append(n.triggeredBy, t.Get())
+	}
+	if s.Journal != nil {
+		n.journal = &journal{config: *s.Journal}
+	}
+	for _, subState := range st.SubGraphs {
+		sub := &Graph{}
+		n.mut.Lock()
+		n.attach(sub)
+		n.mut.Unlock()
+		if err := sub.Restore(subState); err != nil {
+			return fmt.Errorf("error restoring sub-graph %s: %w", subState.ID, err)
+		}
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.byName == nil {
+		s.byName = make(map[string][]*Node)
+		s.byID = make(map[string]*Node)
+	}
+	s.nodes = append(s.nodes, n)
+	s.byName[n.name] = append(s.byName[n.name], n)
+	s.byID[n.id] = n
+	return nil
+}
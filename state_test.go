@@ -0,0 +1,62 @@
+package goraff_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_StateAndRestore(t *testing.T) {
+	assert := assert.New(t)
+	graph := &goraff.Graph{}
+	first := graph.NewNode("first", nil)
+	first.AddStr("items", "a")
+	first.AddStr("items", "b")
+	first.MarkDone()
+	second := graph.NewNode("second", []*goraff.ReadableNode{first.Get()})
+	second.MarkFailed(fmt.Errorf("boom"))
+	sub := &goraff.Graph{}
+	second.AddSubGraph(sub)
+	sub.NewNode("inner", nil).SetStr("result", "deep")
+
+	st := goraff.NewReadableGraph(graph).State()
+	assert.Equal(goraff.NewReadableGraph(graph).ID(), st.ID)
+	assert.Len(st.Nodes, 2)
+	assert.Equal([][]byte{[]byte("a"), []byte("b")}, st.Nodes[0].Values["items"])
+	assert.Equal([]string{first.Get().ID()}, st.Nodes[1].TriggeredBy)
+	assert.Equal("boom", st.Nodes[1].Err)
+
+	// restoring is silent
+	notifier := &MockNotifier{}
+	restored := &goraff.Graph{Notifier: notifier}
+	require.NoError(t, restored.Restore(st))
+	assert.False(notifier.Notified)
+	assert.Equal(st, goraff.NewReadableGraph(restored).State())
+
+	r := goraff.NewReadableGraph(restored)
+	n, err := r.FirstNodeByName("second")
+	require.NoError(t, err)
+	assert.Equal(goraff.NodeStatusFailed, n.Status())
+	assert.EqualError(n.Err(), "boom")
+	assert.Equal([]string{"a", "b"}, restored.FirstNodeByName("first").Get().AllStr("items"))
+
+	inner, err := r.FindGraph(st.Nodes[1].SubGraphs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(r.ID(), inner.ParentIDs()[0])
+	innerNode, err := inner.FirstNodeByName("inner")
+	require.NoError(t, err)
+	assert.Equal("deep", innerNode.FirstStr("result"))
+
+	assert.EqualError(restored.Restore(st), "graph already has nodes")
+}
+
+func TestGraph_RestoreInvalid(t *testing.T) {
+	assert := assert.New(t)
+	err := (&goraff.Graph{}).Restore(goraff.GraphState{Nodes: []goraff.NodeState{{ID: "a", TriggeredBy: []string{"b"}}}})
+	assert.EqualError(err, "error restoring node a: triggered by unknown node b")
+	err = (&goraff.Graph{}).Restore(goraff.GraphState{Nodes: []goraff.NodeState{{ID: "a"}, {ID: "a"}}})
+	assert.EqualError(err, "error restoring node a: node id is not unique")
+}
@@ -0,0 +1,141 @@
+package goraff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrBlockSuspended is returned by a block action, through Suspend, to wait for an event.
+// The scheduler marks the block's node suspended and stops following that branch
+// until Scaff.Resume delivers the event. Other branches carry on.
+type ErrBlockSuspended struct {
+	Event string
+}
+
+func (e ErrBlockSuspended) Error() string {
+	return "suspended until " + e.Event
+}
+
+// Suspend is returned from BlockAction.Do to suspend the block until the named event happens.
+// Only blocks of a run's top level graph can suspend; in a sub-graph it fails the block.
+func Suspend(event string) error {
+	return ErrBlockSuspended{Event: event}
+}
+
+// ErrRunSuspended is returned by a run that ended with blocks waiting for events
+type ErrRunSuspended struct {
+	// Events are the events the run is waiting for, in alphabetical order
+	Events []string
+}
+
+func (e ErrRunSuspended) Error() string {
+	return "run suspended until " + strings.Join(e.Events, ", ")
+}
+
+// ErrEventNotAwaited is returned when resuming a graph that has no block waiting for the event
+type ErrEventNotAwaited struct {
+	Event string
+}
+
+func (e ErrEventNotAwaited) Error() string {
+	return "no block is waiting for event " + e.Event
+}
+
+// Event wakes the blocks suspended until it happens
+type Event struct {
+	Name string
+	Data []byte
+}
+
+// Resumer is implemented by block actions that suspend, to finish their work when the event
+// they wait for happens. The node is the one the block suspended on. Actions that do not
+// implement it have the event's data set on the node's "result" key.
+type Resumer interface {
+	Resume(n *Node, r *ReadableGraph, event Event) error
+}
+
+// Resume continues a suspended run on the graph, see ResumeCtx
+func (g *Scaff) Resume(graph *Graph, event Event) error {
+	if graph == nil {
+		return fmt.Errorf("graph not provided")
+	}
+	return g.ResumeCtx(graph.context(), graph, event)
+}
+
+// ResumeCtx resumes every block on the graph waiting for the event, then follows their joins
+// as if they had just finished. The graph may have been restored from a GraphState.
+// Like GoCtx, it returns ErrRunSuspended if blocks are still waiting once the rest are done.
+func (g *Scaff) ResumeCtx(ctx context.Context, graph *Graph, event Event) error {
+	if graph == nil {
+		return fmt.Errorf("graph not provided")
+	}
+	err := g.validate()
+	if err != nil {
+		return fmt.Errorf("error validating graph: %w", err)
+	}
+	start := []nextJoin{}
+	for _, n := range graph.allNodes() {
+		if n.Get().Status() != NodeStatusSuspended || n.Get().WaitingFor() != event.Name {
+			continue
+		}
+		b := g.Blocks().Get(n.name)
+		if b == nil {
+			return fmt.Errorf("error resuming node %s: %w", n.id, ErrBlockNotFound{ID: n.name})
+		}
+		start = append(start, nextJoin{Join: &Join{To: b}, resume: n, event: event})
+	}
+	if len(start) == 0 {
+		return ErrEventNotAwaited{Event: event.Name}
+	}
+	graph.setContext(ctx)
+	return g.run(ctx, graph, start)
+}
+
+// resumeBlock carries on the block that suspended on n
+func (s *Scaff) resumeBlock(g *Graph, b *Block, n *Node, event Event) (*Node, error) {
+	n.resume()
+	return s.do(g, b, n, func(r *ReadableGraph) error {
+		if res, ok := b.Action.(Resumer); ok {
+			return res.Resume(n, r, event)
+		}
+		n.Set("result", event.Data)
+		return nil
+	})
+}
+
+// suspendedErr returns ErrRunSuspended if any of the graph's nodes are suspended
+func (s *Graph) suspendedErr() error {
+	events := NewReadableGraph(s).WaitingFor()
+	if len(events) == 0 {
+		return nil
+	}
+	return ErrRunSuspended{Events: events}
+}
+
+// WaitingFor returns the events the graph's suspended nodes are waiting for, in alphabetical order
+func (s *ReadableGraph) WaitingFor() []string {
+	seen := map[string]bool{}
+	events := []string{}
+	for _, n := range s.graph.allNodes() {
+		r := n.Get()
+		if r.Status() != NodeStatusSuspended || seen[r.WaitingFor()] {
+			continue
+		}
+		seen[r.WaitingFor()] = true
+		events = append(events, r.WaitingFor())
+	}
+	sort.Strings(events)
+	return events
+}
+
+// suspension returns the event a block's error suspends it until
+func suspension(err error) (string, bool) {
+	var s ErrBlockSuspended
+	if !errors.As(err, &s) {
+		return "", false
+	}
+	return s.Event, true
+}
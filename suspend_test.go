@@ -0,0 +1,141 @@
+package goraff_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// approvalAction suspends until an approval event, and records its data when resumed
+type approvalAction struct{}
+
+func (a *approvalAction) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	n.SetStr("asked", "yes")
+	return goraff.Suspend("approval")
+}
+
+func (a *approvalAction) Resume(n *goraff.Node, r *goraff.ReadableGraph, e goraff.Event) error {
+	n.SetStr("result", "approved by "+string(e.Data))
+	return nil
+}
+
+// suspendAction suspends until event and has no Resume method
+type suspendAction struct {
+	event string
+}
+
+func (a *suspendAction) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	return goraff.Suspend(a.event)
+}
+
+func suspendingScaff(t *testing.T, wait goraff.BlockAction) *goraff.Scaff {
+	s := goraff.NewScaff()
+	start := s.Blocks().Add("start", &actionMock{name: "start"})
+	w := s.Blocks().Add("wait", wait)
+	after := s.Blocks().Add("after", &actionMock{name: "after"})
+	other := s.Blocks().Add("other", &actionMock{name: "other"})
+	s.SetEntrypoint(start)
+	require.NoError(t, s.Joins().Add(start, w, nil))
+	require.NoError(t, s.Joins().Add(w, after, nil))
+	require.NoError(t, s.Joins().Add(start, other, nil))
+	return s
+}
+
+func TestScaff_Suspend(t *testing.T) {
+	assert := assert.New(t)
+	s := suspendingScaff(t, &approvalAction{})
+	graph := &goraff.Graph{}
+	err := s.Go(graph)
+	assert.Equal(goraff.ErrRunSuspended{Events: []string{"approval"}}, err)
+	assert.EqualError(err, "run suspended until approval")
+
+	// the other branch carries on, and nothing after the suspended block runs
+	r := goraff.NewReadableGraph(graph)
+	assert.ElementsMatch([]string{"start", "wait", "other"}, r.NodeNames())
+	assert.True(graph.FirstNodeByName("other").Get().Done())
+	w := graph.FirstNodeByName("wait").Get()
+	assert.Equal(goraff.NodeStatusSuspended, w.Status())
+	assert.Equal("approval", w.WaitingFor())
+	assert.Nil(w.Err())
+	assert.Equal([]string{"approval"}, r.WaitingFor())
+
+	assert.Equal(goraff.ErrEventNotAwaited{Event: "nope"}, s.Resume(graph, goraff.Event{Name: "nope"}))
+
+	assert.NoError(s.Resume(graph, goraff.Event{Name: "approval", Data: []byte("sam")}))
+	assert.ElementsMatch([]string{"start", "wait", "other", "after"}, r.NodeNames())
+	assert.Equal(goraff.NodeStatusDone, w.Status())
+	assert.Equal("", w.WaitingFor())
+	assert.Equal("approved by sam", w.FirstStr("result"))
+	assert.Equal("yes", w.FirstStr("asked"))
+	assert.Empty(r.WaitingFor())
+}
+
+func TestScaff_ResumeRestoredGraph(t *testing.T) {
+	assert := assert.New(t)
+	s := suspendingScaff(t, &suspendAction{event: "webhook"})
+	graph := &goraff.Graph{}
+	assert.True(errors.As(s.Go(graph), &goraff.ErrRunSuspended{}))
+
+	// as if saved by one process and loaded by the next
+	b, err := json.Marshal(goraff.NewReadableGraph(graph).State())
+	require.NoError(t, err)
+	var st goraff.GraphState
+	require.NoError(t, json.Unmarshal(b, &st))
+	restored := &goraff.Graph{}
+	require.NoError(t, restored.Restore(st))
+
+	r := goraff.NewReadableGraph(restored)
+	assert.Equal(goraff.NewReadableGraph(graph).ID(), r.ID())
+	assert.Equal(goraff.NewReadableGraph(graph).NodeIDs(), r.NodeIDs())
+	assert.Equal([]string{"webhook"}, r.WaitingFor())
+
+	assert.NoError(s.Resume(restored, goraff.Event{Name: "webhook", Data: []byte(`{"ok":true}`)}))
+	assert.ElementsMatch([]string{"start", "wait", "other", "after"}, r.NodeNames())
+	w := restored.FirstNodeByName("wait").Get()
+	assert.Equal(`{"ok":true}`, w.FirstStr("result"))
+	after := restored.FirstNodeByName("after").Get()
+	assert.Equal(w.ID(), after.TriggeredBy()[0].ID())
+}
+
+func TestScaff_ResumeStillSuspended(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	start := s.Blocks().Add("start", &actionMock{name: "start"})
+	a := s.Blocks().Add("a", &suspendAction{event: "a"})
+	b := s.Blocks().Add("b", &suspendAction{event: "b"})
+	s.SetEntrypoint(start)
+	require.NoError(t, s.Joins().Add(start, a, nil))
+	require.NoError(t, s.Joins().Add(start, b, nil))
+
+	graph := &goraff.Graph{}
+	assert.Equal(goraff.ErrRunSuspended{Events: []string{"a", "b"}}, s.Go(graph))
+	assert.Equal(goraff.ErrRunSuspended{Events: []string{"b"}}, s.Resume(graph, goraff.Event{Name: "a"}))
+	assert.NoError(s.Resume(graph, goraff.Event{Name: "b"}))
+}
+
+type subScaffAction struct {
+	scaff *goraff.Scaff
+}
+
+func (a *subScaffAction) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	sub := &goraff.Graph{}
+	n.AddSubGraph(sub)
+	return a.scaff.Go(sub)
+}
+
+func TestScaff_SuspendInSubGraph(t *testing.T) {
+	assert := assert.New(t)
+	inner := goraff.NewScaff()
+	inner.SetEntrypoint(inner.Blocks().Add("inner", &suspendAction{event: "never"}))
+	s := goraff.NewScaff()
+	s.SetEntrypoint(s.Blocks().Add("outer", &subScaffAction{scaff: inner}))
+
+	err := s.Go(&goraff.Graph{})
+	assert.ErrorContains(err, "block inner cannot wait for never: only blocks of a run's top level graph can suspend")
+	assert.False(errors.As(err, &goraff.ErrRunSuspended{}))
+	assert.False(errors.As(err, &goraff.ErrBlockSuspended{}))
+}
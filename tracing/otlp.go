@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const scopeName = "github.com/lordtatty/goraff/tracing"
+
+// OTLPJSONExporter writes each run's spans as one line of OTLP-JSON,
+// the format read by the OpenTelemetry collector's file receiver
+type OTLPJSONExporter struct {
+	W io.Writer
+	// ServiceName is reported as the service.name resource attribute. Defaults to goraff.
+	ServiceName string
+	mu          sync.Mutex
+}
+
+// NewOTLPFileExporter appends OTLP-JSON to the file at path, creating it if needed
+func NewOTLPFileExporter(path string) (*OTLPJSONExporter, io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening trace file: %w", err)
+	}
+	return &OTLPJSONExporter{W: f}, f, nil
+}
+
+func (e *OTLPJSONExporter) Export(spans []Span) error {
+	b, err := json.Marshal(e.document(spans))
+	if err != nil {
+		return fmt.Errorf("error marshalling spans: %w", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.W.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("error writing spans: %w", err)
+	}
+	return nil
+}
+
+type otlpDocument struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    SpanStatusCode `json:"code"`
+	Message string         `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (e *OTLPJSONExporter) document(spans []Span) otlpDocument {
+	service := e.ServiceName
+	if service == "" {
+		service = "goraff"
+	}
+	out := []otlpSpan{}
+	for _, s := range spans {
+		out = append(out, otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentSpanID,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+			Status:            otlpStatus{Code: s.StatusCode, Message: s.StatusMessage},
+		})
+	}
+	return otlpDocument{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]any{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: out}},
+	}}}
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := []otlpAttribute{}
+	for _, k := range keys {
+		result = append(result, otlpAttribute{Key: k, Value: otlpAttributeValue(attrs[k])})
+	}
+	return result
+}
+
+func otlpAttributeValue(v any) otlpValue {
+	switch t := v.(type) {
+	case string:
+		return otlpValue{StringValue: &t}
+	case int:
+		s := strconv.Itoa(t)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(t, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &t}
+	case bool:
+		return otlpValue{BoolValue: &t}
+	default:
+		s := fmt.Sprint(t)
+		return otlpValue{StringValue: &s}
+	}
+}
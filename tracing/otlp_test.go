@@ -0,0 +1,66 @@
+package tracing_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lordtatty/goraff/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTLPJSONExporter(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Unix(1700000000, 5)
+	spans := []tracing.Span{
+		{
+			TraceID:    "0102030405060708090a0b0c0d0e0f10",
+			SpanID:     "0102030405060708",
+			Name:       "scaff.run",
+			Kind:       tracing.KindInternal,
+			Start:      start,
+			End:        start.Add(time.Second),
+			Attributes: map[string]any{"goraff.graph.id": "g1"},
+			StatusCode: tracing.StatusOK,
+		},
+		{
+			TraceID:       "0102030405060708090a0b0c0d0e0f10",
+			SpanID:        "1112131415161718",
+			ParentSpanID:  "0102030405060708",
+			Name:          "llm fake-1",
+			Kind:          tracing.KindClient,
+			Start:         start,
+			End:           start,
+			Attributes:    map[string]any{"tokens": 12, "streamed": true},
+			StatusCode:    tracing.StatusError,
+			StatusMessage: "boom",
+		},
+	}
+	b := &bytes.Buffer{}
+	sut := &tracing.OTLPJSONExporter{W: b}
+	assert.NoError(sut.Export(spans))
+
+	want := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"goraff"}}]},"scopeSpans":[{"scope":{"name":"github.com/lordtatty/goraff/tracing"},"spans":[` +
+		`{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708","name":"scaff.run","kind":1,"startTimeUnixNano":"1700000000000000005","endTimeUnixNano":"1700000001000000005","attributes":[{"key":"goraff.graph.id","value":{"stringValue":"g1"}}],"status":{"code":1}},` +
+		`{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"1112131415161718","parentSpanId":"0102030405060708","name":"llm fake-1","kind":3,"startTimeUnixNano":"1700000000000000005","endTimeUnixNano":"1700000000000000005","attributes":[{"key":"streamed","value":{"boolValue":true}},{"key":"tokens","value":{"intValue":"12"}}],"status":{"code":2,"message":"boom"}}` +
+		`]}]}]}`
+	assert.True(strings.HasSuffix(b.String(), "\n"))
+	assert.JSONEq(want, b.String())
+}
+
+func TestNewOTLPFileExporter(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "traces.json")
+	sut, closer, err := tracing.NewOTLPFileExporter(path)
+	assert.NoError(err)
+	assert.NoError(sut.Export([]tracing.Span{{Name: "one"}}))
+	assert.NoError(sut.Export([]tracing.Span{{Name: "two"}}))
+	assert.NoError(closer.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal(2, strings.Count(string(b), "\n"))
+}
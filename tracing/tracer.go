@@ -0,0 +1,247 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/redact"
+)
+
+// SpanStatusCode follows the OpenTelemetry status codes
+type SpanStatusCode int
+
+const (
+	StatusUnset SpanStatusCode = 0
+	StatusOK    SpanStatusCode = 1
+	StatusError SpanStatusCode = 2
+)
+
+// SpanKind follows the OpenTelemetry span kinds
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindClient   SpanKind = 3
+)
+
+// Span is a timed piece of work within a run
+type Span struct {
+	TraceID       string
+	SpanID        string
+	ParentSpanID  string
+	Name          string
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]any
+	StatusCode    SpanStatusCode
+	StatusMessage string
+}
+
+// Exporter receives the spans of each finished run
+type Exporter interface {
+	Export(spans []Span) error
+}
+
+// Tracer turns runs into spans: one per scaff run, one per block, one per external call.
+// Runs of sub-graphs by ScaffNode and FanOut become children of the block that started them.
+// Spans are exported together when the top level run finishes.
+type Tracer struct {
+	Exporter Exporter
+	// OnError is called when exporting fails. Errors are dropped when it is nil.
+	OnError func(err error)
+	// Redactor, when set, scrubs error messages and call attributes
+	Redactor *redact.Redactor
+	mu       sync.Mutex
+	runs     map[string]*Span
+	blocks   map[string]*Span
+	finished map[string][]Span
+}
+
+func NewTracer(e Exporter) *Tracer {
+	return &Tracer{Exporter: e}
+}
+
+// Hooks returns the hooks to add to a graph to trace its runs
+func (t *Tracer) Hooks() *goraff.Hooks {
+	return &goraff.Hooks{
+		RunStarted:    t.runStarted,
+		RunFinished:   t.runFinished,
+		BlockStarted:  t.blockStarted,
+		BlockFinished: t.blockFinished,
+		Call:          t.call,
+	}
+}
+
+func (t *Tracer) init() {
+	if t.runs == nil {
+		t.runs = map[string]*Span{}
+		t.blocks = map[string]*Span{}
+		t.finished = map[string][]Span{}
+	}
+}
+
+func (t *Tracer) runStarted(g *goraff.ReadableGraph) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.init()
+	span := &Span{
+		TraceID: newID(16),
+		Name:    "scaff.run",
+		Kind:    KindInternal,
+		Start:   time.Now(),
+		Attributes: map[string]any{
+			"goraff.graph.id": g.ID(),
+		},
+	}
+	if p := g.ParentNode(); p != nil {
+		span.Attributes["goraff.parent.node.id"] = p.ID()
+		if parent, ok := t.blocks[p.ID()]; ok {
+			span.TraceID = parent.TraceID
+			span.ParentSpanID = parent.SpanID
+		}
+	}
+	span.SpanID = newID(8)
+	t.runs[g.ID()] = span
+}
+
+func (t *Tracer) runFinished(g *goraff.ReadableGraph, err error) {
+	t.mu.Lock()
+	span, ok := t.runs[g.ID()]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.runs, g.ID())
+	span.End = time.Now()
+	t.setStatus(span, err)
+	t.finished[span.TraceID] = append(t.finished[span.TraceID], *span)
+	if span.ParentSpanID != "" {
+		t.mu.Unlock()
+		return
+	}
+	spans := t.finished[span.TraceID]
+	delete(t.finished, span.TraceID)
+	t.mu.Unlock()
+	if t.Exporter == nil {
+		return
+	}
+	if err := t.Exporter.Export(spans); err != nil && t.OnError != nil {
+		t.OnError(fmt.Errorf("error exporting spans: %w", err))
+	}
+}
+
+func (t *Tracer) blockStarted(g *goraff.ReadableGraph, n *goraff.ReadableNode) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.init()
+	span := &Span{
+		TraceID: newID(16),
+		SpanID:  newID(8),
+		Name:    "block " + n.Name(),
+		Kind:    KindInternal,
+		Start:   n.StartedAt(),
+		Attributes: map[string]any{
+			"goraff.block.name": n.Name(),
+			"goraff.node.id":    n.ID(),
+			"goraff.graph.id":   g.ID(),
+		},
+	}
+	if run, ok := t.runs[g.ID()]; ok {
+		span.TraceID = run.TraceID
+		span.ParentSpanID = run.SpanID
+	}
+	t.blocks[n.ID()] = span
+}
+
+func (t *Tracer) blockFinished(g *goraff.ReadableGraph, n *goraff.ReadableNode, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span, ok := t.blocks[n.ID()]
+	if !ok {
+		return
+	}
+	delete(t.blocks, n.ID())
+	span.End = time.Now()
+	span.Attributes["goraff.node.status"] = string(n.Status())
+	t.setStatus(span, err)
+	t.finished[span.TraceID] = append(t.finished[span.TraceID], *span)
+}
+
+func (t *Tracer) call(n *goraff.ReadableNode, c goraff.Call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.init()
+	span := Span{
+		TraceID: newID(16),
+		SpanID:  newID(8),
+		Name:    fmt.Sprintf("%s %s", c.Kind, c.Model),
+		Kind:    KindClient,
+		Start:   c.Start,
+		End:     c.End,
+		Attributes: map[string]any{
+			"goraff.node.id":             n.ID(),
+			"goraff.call.kind":           c.Kind,
+			"gen_ai.system":              c.Provider,
+			"gen_ai.request.model":       c.Model,
+			"gen_ai.usage.input_tokens":  c.InputTokens,
+			"gen_ai.usage.output_tokens": c.OutputTokens,
+		},
+	}
+	for k, v := range c.Attributes {
+		span.Attributes["goraff.call."+k] = t.Redactor.String(v)
+	}
+	if parent, ok := t.blocks[n.ID()]; ok {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+	}
+	t.setStatus(&span, c.Err)
+	t.finished[span.TraceID] = append(t.finished[span.TraceID], span)
+}
+
+func (t *Tracer) setStatus(span *Span, err error) {
+	if errors.As(err, &goraff.ErrBlockSuspended{}) || errors.As(err, &goraff.ErrRunSuspended{}) {
+		// suspending is not a failure, and the block or run carries on when resumed
+		span.StatusCode = StatusUnset
+		return
+	}
+	if err != nil {
+		span.StatusCode = StatusError
+		span.StatusMessage = t.Redactor.String(err.Error())
+		return
+	}
+	span.StatusCode = StatusOK
+}
+
+func newID(bytes int) string {
+	b := make([]byte, bytes)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MemoryExporter keeps exported spans in memory, for tests
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (m *MemoryExporter) Export(spans []Span) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spans = append(m.spans, spans...)
+	return nil
+}
+
+// Spans returns every span exported so far
+func (m *MemoryExporter) Spans() []Span {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Span, len(m.spans))
+	copy(result, m.spans)
+	return result
+}
@@ -0,0 +1,160 @@
+package tracing_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lordtatty/goraff"
+	"github.com/lordtatty/goraff/blockactions"
+	"github.com/lordtatty/goraff/redact"
+	"github.com/lordtatty/goraff/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLLM struct{}
+
+func (f *fakeLLM) Chat(systemMsg, userMsg string, stream chan string) (string, error) {
+	stream <- "hello "
+	stream <- "world"
+	return "hello world", nil
+}
+
+func (f *fakeLLM) Provider() string  { return "fake" }
+func (f *fakeLLM) ModelName() string { return "fake-1" }
+
+type failing struct{}
+
+func (f *failing) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	return fmt.Errorf("boom")
+}
+
+func spansByName(spans []tracing.Span) map[string]tracing.Span {
+	result := map[string]tracing.Span{}
+	for _, s := range spans {
+		result[s.Name] = s
+	}
+	return result
+}
+
+func TestTracer_NestedRun(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sub := goraff.NewScaff()
+	llm := sub.Blocks().Add("ask", &blockactions.LLM{Client: &fakeLLM{}, UserMsg: "say hello"})
+	sub.SetEntrypoint(llm)
+
+	s := goraff.NewScaff()
+	in := s.Blocks().Add("input", &blockactions.Input{Value: "x"})
+	nested := s.Blocks().Add("nested", &blockactions.ScaffNode{Scaff: sub})
+	s.SetEntrypoint(in)
+	s.Joins().Add(in, nested, nil)
+
+	exp := &tracing.MemoryExporter{}
+	sut := tracing.NewTracer(exp)
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{sut.Hooks()}}
+	require.NoError(s.Go(g))
+
+	spans := exp.Spans()
+	require.Len(spans, 6)
+	byName := spansByName(spans)
+	root := byName["scaff.run"]
+	for _, sp := range spans {
+		assert.Equal(root.TraceID, sp.TraceID, sp.Name)
+		assert.Len(sp.SpanID, 16)
+		assert.False(sp.End.Before(sp.Start), sp.Name)
+		assert.Equal(tracing.StatusOK, sp.StatusCode, sp.Name)
+	}
+	assert.Len(root.TraceID, 32)
+	assert.Empty(root.ParentSpanID)
+	assert.Equal(goraff.NewReadableGraph(g).ID(), root.Attributes["goraff.graph.id"])
+
+	input := byName["block input"]
+	assert.Equal(root.SpanID, input.ParentSpanID)
+	assert.Equal("input", input.Attributes["goraff.block.name"])
+	assert.Equal(g.FirstNodeByName("input").Get().ID(), input.Attributes["goraff.node.id"])
+
+	nestedSpan := byName["block nested"]
+	assert.Equal(root.SpanID, nestedSpan.ParentSpanID)
+
+	// the sub-graph run is a child of the block that started it
+	var subRun tracing.Span
+	for _, sp := range spans {
+		if sp.Name == "scaff.run" && sp.ParentSpanID != "" {
+			subRun = sp
+		}
+	}
+	assert.Equal(nestedSpan.SpanID, subRun.ParentSpanID)
+
+	ask := byName["block ask"]
+	assert.Equal(subRun.SpanID, ask.ParentSpanID)
+
+	call := byName["llm fake-1"]
+	assert.Equal(ask.SpanID, call.ParentSpanID)
+	assert.Equal(tracing.KindClient, call.Kind)
+	assert.Equal("fake", call.Attributes["gen_ai.system"])
+	assert.Equal(3, call.Attributes["gen_ai.usage.output_tokens"])
+	assert.Equal("true", call.Attributes["goraff.call.tokens_estimated"])
+}
+
+func TestTracer_FailedBlock(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	b := s.Blocks().Add("broken", &failing{})
+	s.SetEntrypoint(b)
+
+	exp := &tracing.MemoryExporter{}
+	sut := tracing.NewTracer(exp)
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{sut.Hooks()}}
+	assert.Error(s.Go(g))
+
+	byName := spansByName(exp.Spans())
+	assert.Equal(tracing.StatusError, byName["block broken"].StatusCode)
+	assert.Equal("boom", byName["block broken"].StatusMessage)
+	assert.Equal("failed", byName["block broken"].Attributes["goraff.node.status"])
+	assert.Equal(tracing.StatusError, byName["scaff.run"].StatusCode)
+}
+
+type failingExporter struct{}
+
+func (f *failingExporter) Export(spans []tracing.Span) error {
+	return fmt.Errorf("collector down")
+}
+
+func TestTracer_ExportError(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	b := s.Blocks().Add("input", &blockactions.Input{})
+	s.SetEntrypoint(b)
+
+	var got error
+	sut := &tracing.Tracer{Exporter: &failingExporter{}, OnError: func(err error) { got = err }}
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{sut.Hooks()}}
+	assert.NoError(s.Go(g))
+	assert.EqualError(got, "error exporting spans: collector down")
+}
+
+func TestTracer_Redactor(t *testing.T) {
+	assert := assert.New(t)
+	s := goraff.NewScaff()
+	b := s.Blocks().Add("broken", &redactAction{})
+	s.SetEntrypoint(b)
+
+	exp := &tracing.MemoryExporter{}
+	sut := tracing.NewTracer(exp)
+	sut.Redactor = redact.New()
+	g := &goraff.Graph{Hooks: []*goraff.Hooks{sut.Hooks()}}
+	assert.Error(s.Go(g))
+
+	byName := spansByName(exp.Spans())
+	assert.Equal("denied for [REDACTED]", byName["block broken"].StatusMessage)
+	assert.Equal("[REDACTED]", byName["http call"].Attributes["goraff.call.url"])
+}
+
+type redactAction struct{}
+
+func (a *redactAction) Do(n *goraff.Node, r *goraff.ReadableGraph, t *goraff.ReadableNode) error {
+	n.RecordCall(goraff.Call{Kind: "http", Model: "call", Attributes: map[string]string{"url": "jo@example.com"}})
+	return fmt.Errorf("denied for jo@example.com")
+}
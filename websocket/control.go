@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Command types clients may send
+const (
+	CommandStartRun    = "start_run"
+	CommandCancelRun   = "cancel_run"
+	CommandSubscribe   = "subscribe"
+	CommandUnsubscribe = "unsubscribe"
+	CommandAnswer      = "answer"
+)
+
+// MessageTypeResponse is the type of every reply to a command
+const MessageTypeResponse = "response"
+
+// Command is a request from a client. ID is chosen by the client and echoed in the response.
+type Command struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Scaff and Inputs are for start_run
+	Scaff  string            `json:"scaff,omitempty"`
+	Inputs map[string]string `json:"inputs,omitempty"`
+	// RunID is for cancel_run
+	RunID string `json:"run_id,omitempty"`
+	// GraphID is for subscribe and unsubscribe. It may be a run or any of its sub-graphs,
+	// whose subscribers also receive the changes of graphs nested in it.
+	GraphID string `json:"graph_id,omitempty"`
+	// QuestionID and Answer are for answer
+	QuestionID string `json:"question_id,omitempty"`
+	Answer     string `json:"answer,omitempty"`
+}
+
+// Response is the reply to a command
+type Response struct {
+	Type   string            `json:"type"`
+	ID     string            `json:"id"`
+	OK     bool              `json:"ok"`
+	Error  string            `json:"error,omitempty"`
+	Result map[string]string `json:"result,omitempty"`
+}
+
+// Controller carries out the commands that act on runs
+type Controller interface {
+	// StartRun starts the named scaff with the inputs and returns the run's ID,
+	// which is also the ID of its graph
+	StartRun(scaff string, inputs map[string]string) (string, error)
+	CancelRun(runID string) error
+	// Answer responds to a pending request for human input
+	Answer(questionID, answer string) error
+}
+
+func (s *WebSocketServer) handleCommand(c *client, msg []byte) {
+	var cmd Command
+	if err := json.Unmarshal(msg, &cmd); err != nil {
+		s.reply(c, Response{Error: fmt.Sprintf("invalid command: %s", err.Error())})
+		return
+	}
+	result, err := s.runCommand(c, cmd)
+	resp := Response{ID: cmd.ID, OK: err == nil, Result: result}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	s.reply(c, resp)
+}
+
+func (s *WebSocketServer) runCommand(c *client, cmd Command) (map[string]string, error) {
+	switch cmd.Type {
+	case CommandSubscribe:
+		if cmd.GraphID == "" {
+			return nil, fmt.Errorf("graph_id is required")
+		}
+		c.subscribe(cmd.GraphID)
+		return nil, nil
+	case CommandUnsubscribe:
+		if cmd.GraphID == "" {
+			return nil, fmt.Errorf("graph_id is required")
+		}
+		c.unsubscribe(cmd.GraphID)
+		return nil, nil
+	case CommandStartRun, CommandCancelRun, CommandAnswer:
+	default:
+		return nil, fmt.Errorf("unknown command type %q", cmd.Type)
+	}
+	if s.Controller == nil {
+		return nil, fmt.Errorf("this server does not accept %s commands", cmd.Type)
+	}
+	switch cmd.Type {
+	case CommandStartRun:
+		if cmd.Scaff == "" {
+			return nil, fmt.Errorf("scaff is required")
+		}
+		id, err := s.Controller.StartRun(cmd.Scaff, cmd.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("error starting run: %w", err)
+		}
+		return map[string]string{"run_id": id}, nil
+	case CommandCancelRun:
+		if cmd.RunID == "" {
+			return nil, fmt.Errorf("run_id is required")
+		}
+		if err := s.Controller.CancelRun(cmd.RunID); err != nil {
+			return nil, fmt.Errorf("error cancelling run: %w", err)
+		}
+		return nil, nil
+	default:
+		if cmd.QuestionID == "" {
+			return nil, fmt.Errorf("question_id is required")
+		}
+		if err := s.Controller.Answer(cmd.QuestionID, cmd.Answer); err != nil {
+			return nil, fmt.Errorf("error answering: %w", err)
+		}
+		return nil, nil
+	}
+}
+
+func (s *WebSocketServer) reply(c *client, resp Response) {
+	resp.Type = MessageTypeResponse
+	b, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("error marshalling response: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enqueue(c, b)
+}
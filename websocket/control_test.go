@@ -0,0 +1,156 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/lordtatty/goraff/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type controllerMock struct {
+	started   map[string]string
+	cancelled string
+	answers   map[string]string
+}
+
+func (c *controllerMock) StartRun(scaff string, inputs map[string]string) (string, error) {
+	if scaff != "known" {
+		return "", fmt.Errorf("scaff %s not registered", scaff)
+	}
+	c.started = inputs
+	return "run-1", nil
+}
+
+func (c *controllerMock) CancelRun(runID string) error {
+	c.cancelled = runID
+	return nil
+}
+
+func (c *controllerMock) Answer(questionID, answer string) error {
+	if c.answers == nil {
+		c.answers = map[string]string{}
+	}
+	c.answers[questionID] = answer
+	return nil
+}
+
+func command(t *testing.T, conn *gws.Conn, cmd string) websocket.Response {
+	t.Helper()
+	require.NoError(t, conn.WriteMessage(gws.TextMessage, []byte(cmd)))
+	var resp websocket.Response
+	require.NoError(t, json.Unmarshal([]byte(read(t, conn)), &resp))
+	return resp
+}
+
+func TestWebSocketServer_Commands(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := &controllerMock{}
+	sut := websocket.NewWebSocketServer("")
+	sut.Controller = ctrl
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+	conn := dial(t, srv.URL)
+
+	resp := command(t, conn, `{"id":"1","type":"start_run","scaff":"known","inputs":{"topic":"cats"}}`)
+	assert.Equal(websocket.Response{Type: "response", ID: "1", OK: true, Result: map[string]string{"run_id": "run-1"}}, resp)
+	assert.Equal(map[string]string{"topic": "cats"}, ctrl.started)
+
+	resp = command(t, conn, `{"id":"2","type":"start_run","scaff":"unknown"}`)
+	assert.Equal(websocket.Response{Type: "response", ID: "2", Error: "error starting run: scaff unknown not registered"}, resp)
+
+	resp = command(t, conn, `{"id":"3","type":"cancel_run","run_id":"run-1"}`)
+	assert.True(resp.OK)
+	assert.Equal("run-1", ctrl.cancelled)
+
+	resp = command(t, conn, `{"id":"4","type":"answer","question_id":"q1","answer":"yes"}`)
+	assert.True(resp.OK)
+	assert.Equal(map[string]string{"q1": "yes"}, ctrl.answers)
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{`{"id":"5","type":"cancel_run"}`, "run_id is required"},
+		{`{"id":"6","type":"answer"}`, "question_id is required"},
+		{`{"id":"7","type":"start_run"}`, "scaff is required"},
+		{`{"id":"8","type":"subscribe"}`, "graph_id is required"},
+		{`{"id":"9","type":"dance"}`, `unknown command type "dance"`},
+		{`not json`, "invalid command: invalid character 'o' in literal null (expecting 'u')"},
+	}
+	for _, tt := range tests {
+		resp := command(t, conn, tt.cmd)
+		assert.False(resp.OK, tt.cmd)
+		assert.Equal(tt.want, resp.Error, tt.cmd)
+	}
+}
+
+func TestWebSocketServer_CommandsWithoutController(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+	conn := dial(t, srv.URL)
+
+	resp := command(t, conn, `{"id":"1","type":"start_run","scaff":"known"}`)
+	assert.Equal("this server does not accept start_run commands", resp.Error)
+}
+
+func TestWebSocketServer_Subscriptions(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+	all := dial(t, srv.URL)
+	one := dial(t, srv.URL)
+
+	assert.True(command(t, one, `{"id":"1","type":"subscribe","graph_id":"g1"}`).OK)
+	sut.SendGraph("g2", "for g2")
+	sut.SendGraph("g1", "for g1")
+	sut.Send("for everyone")
+	assert.Equal("for g2", read(t, all))
+	assert.Equal("for g1", read(t, all))
+	assert.Equal("for everyone", read(t, all))
+	assert.Equal("for g1", read(t, one))
+	assert.Equal("for everyone", read(t, one))
+
+	assert.True(command(t, one, `{"id":"2","type":"unsubscribe","graph_id":"g1"}`).OK)
+	sut.SendGraph("g2", "for g2 again")
+	assert.Equal("for g2 again", read(t, one))
+
+	_ = one.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	_, _, err := one.ReadMessage()
+	assert.Error(err)
+}
+
+func TestWebSocketServer_SubscribeToSubGraph(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+	branch := dial(t, srv.URL)
+	run := dial(t, srv.URL)
+
+	assert.True(command(t, branch, `{"id":"1","type":"subscribe","graph_id":"branch"}`).OK)
+	assert.True(command(t, run, `{"id":"1","type":"subscribe","graph_id":"run"}`).OK)
+	sut.SendGraphs([]string{"run"}, "for the run")
+	sut.SendGraphs([]string{"run", "branch"}, "for the branch")
+	sut.SendGraphs([]string{"run", "branch", "leaf"}, "nested in the branch")
+	sut.SendGraphs([]string{"run", "other"}, "for another branch")
+
+	// a sub-graph's subscribers get its changes and those of graphs nested in it
+	assert.Equal("for the branch", read(t, branch))
+	assert.Equal("nested in the branch", read(t, branch))
+	// and the run's subscribers get everything in the run
+	for _, want := range []string{"for the run", "for the branch", "nested in the branch", "for another branch"} {
+		assert.Equal(want, read(t, run))
+	}
+	_ = branch.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	_, _, err := branch.ReadMessage()
+	assert.Error(err)
+}
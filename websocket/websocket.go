@@ -1,24 +1,62 @@
 package websocket
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+const (
+	defaultClientQueue  = 256
+	defaultPingInterval = 30 * time.Second
+	defaultWriteWait    = 10 * time.Second
+	shutdownTimeout     = 5 * time.Second
+	maxMessageSize      = 64 * 1024
+)
+
 type Item struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Value string `json:"value"`
 }
 
+// Source supplies the messages a client needs when it connects
+type Source interface {
+	// Replay returns the messages that bring a client which has seen everything up to
+	// since up to date. Since is zero for clients that have seen nothing.
+	Replay(since uint64) []string
+}
+
+// WebSocketServer broadcasts messages to every connected client.
+// Each client has its own queue and writer, so a slow client cannot hold up the others;
+// a client whose queue fills is disconnected and can reconnect.
 type WebSocketServer struct {
-	Addr      string
-	Upgrader  websocket.Upgrader
-	clients   map[*websocket.Conn]bool
-	broadcast chan string
+	Addr     string
+	Upgrader websocket.Upgrader
+	// ClientQueue is the number of messages held for each client. Defaults to 256.
+	ClientQueue int
+	// PingInterval is how often clients are pinged. A client that does not answer
+	// within two intervals is disconnected. Defaults to 30 seconds.
+	PingInterval time.Duration
+	// WriteWait is how long a single write may take. Defaults to 10 seconds.
+	WriteWait time.Duration
+	// Source, when set, is replayed to each client as it connects.
+	// Clients resume after a sequence number by connecting with ?since=<seq>.
+	Source Source
+	// Controller, when set, carries out the run commands clients send. See Command.
+	Controller Controller
+
+	mu        sync.Mutex
+	clients   map[*client]struct{}
+	connected chan struct{}
 }
 
 func NewWebSocketServer(addr string) *WebSocketServer {
@@ -29,73 +67,308 @@ func NewWebSocketServer(addr string) *WebSocketServer {
 			WriteBufferSize: 1024,
 			CheckOrigin:     func(r *http.Request) bool { return true },
 		},
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: make(chan string),
 	}
 }
 
-func (s *WebSocketServer) handleConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := s.Upgrader.Upgrade(w, r, nil)
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+	// graphs the client has subscribed to. Clients without subscriptions receive everything.
+	mu     sync.Mutex
+	graphs map[string]bool
+}
+
+func (c *client) subscribe(graphID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.graphs == nil {
+		c.graphs = map[string]bool{}
+	}
+	c.graphs[graphID] = true
+}
+
+func (c *client) unsubscribe(graphID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.graphs, graphID)
+}
+
+// wants reports whether the client has subscribed to any of the graphs, or to nothing
+func (c *client) wants(graphIDs []string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.graphs) == 0 {
+		return true
+	}
+	for _, id := range graphIDs {
+		if c.graphs[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+func (s *WebSocketServer) pingInterval() time.Duration {
+	if s.PingInterval <= 0 {
+		return defaultPingInterval
+	}
+	return s.PingInterval
+}
+
+func (s *WebSocketServer) writeWait() time.Duration {
+	if s.WriteWait <= 0 {
+		return defaultWriteWait
+	}
+	return s.WriteWait
+}
+
+// Handler upgrades requests to websocket connections and registers them as clients.
+// Mount it wherever suits, such as "/ws".
+func (s *WebSocketServer) Handler() http.Handler {
+	return http.HandlerFunc(s.handleConnection)
+}
+
+func (s *WebSocketServer) handleConnection(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "since must be a sequence number", http.StatusBadRequest)
+			return
+		}
+		since = n
+	}
+	conn, err := s.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Fatal(err)
+		// Upgrade has already replied to the client
+		log.Printf("error upgrading websocket connection: %v", err)
+		return
 	}
-	defer ws.Close()
+	queue := s.ClientQueue
+	if queue <= 0 {
+		queue = defaultClientQueue
+	}
+	c := &client{conn: conn, send: make(chan []byte, queue), done: make(chan struct{})}
+	s.add(c)
+	// Messages sent from now on wait in the client's queue, so replaying afterwards leaves no gap.
+	// Anything both replayed and queued arrives twice, which patches tolerate.
+	if s.Source != nil {
+		for _, msg := range s.Source.Replay(since) {
+			_ = conn.SetWriteDeadline(time.Now().Add(s.writeWait()))
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				s.remove(c)
+				conn.Close()
+				return
+			}
+		}
+	}
+	go s.writeLoop(c)
+	s.readLoop(c)
+}
+
+func (s *WebSocketServer) add(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients == nil {
+		s.clients = map[*client]struct{}{}
+	}
+	s.clients[c] = struct{}{}
+	if s.connected != nil {
+		close(s.connected)
+		s.connected = nil
+	}
+}
 
-	s.clients[ws] = true
+func (s *WebSocketServer) remove(c *client) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+	c.close()
+}
+
+// readLoop keeps the read deadline moving while pongs arrive, handles commands, and notices disconnects
+func (s *WebSocketServer) readLoop(c *client) {
+	defer s.remove(c)
+	wait := 2 * s.pingInterval()
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wait))
+	})
 	for {
-		_, _, err := ws.ReadMessage()
+		_, msg, err := c.conn.ReadMessage()
 		if err != nil {
-			log.Printf("error: %v", err)
-			break
+			return
 		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(wait))
+		s.handleCommand(c, msg)
 	}
-	delete(s.clients, ws)
 }
 
-func (server *WebSocketServer) handleMessages() {
+// writeLoop is the only writer to the connection
+func (s *WebSocketServer) writeLoop(c *client) {
+	ticker := time.NewTicker(s.pingInterval())
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
 	for {
-		msg := <-server.broadcast
-		for client := range server.clients {
-			err := client.WriteMessage(websocket.TextMessage, []byte(msg))
-			if err != nil {
-				log.Printf("error: %v", err)
-				client.Close()
-				delete(server.clients, client)
+		select {
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(s.writeWait()))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				s.remove(c)
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.writeWait())); err != nil {
+				s.remove(c)
+				return
 			}
+		case <-c.done:
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(s.writeWait()))
+			return
 		}
 	}
 }
 
-func (server *WebSocketServer) Serve() {
-	http.HandleFunc("/ws", server.handleConnections)
-	go server.handleMessages()
-	log.Println("WebSocket server started on", server.Addr)
-	log.Fatal(http.ListenAndServe(server.Addr, nil))
+// Send queues the message for every connected client without blocking.
+// Clients whose queue is full are disconnected.
+func (s *WebSocketServer) Send(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		s.enqueue(c, []byte(msg))
+	}
+}
+
+// SendGraph is Send for a message about the graph with the given ID.
+// It only reaches clients that subscribed to the graph, or that have no subscriptions.
+func (s *WebSocketServer) SendGraph(graphID, msg string) {
+	s.SendGraphs([]string{graphID}, msg)
+}
+
+// SendGraphs is SendGraph for a message about several graphs, such as a sub-graph and the
+// graphs it is nested in. It reaches clients subscribed to any of them.
+func (s *WebSocketServer) SendGraphs(graphIDs []string, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		if c.wants(graphIDs) {
+			s.enqueue(c, []byte(msg))
+		}
+	}
 }
 
-func (server *WebSocketServer) Send(msg string) {
-	// msg to a json map
-	server.broadcast <- msg
+// enqueue must be called with s.mu held
+func (s *WebSocketServer) enqueue(c *client, msg []byte) {
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		log.Println("websocket client too slow, disconnecting")
+		delete(s.clients, c)
+		c.close()
+	}
 }
 
-func (s *WebSocketServer) WaitForConnection() {
-	// Create a channel to signal when a client connects
-	connected := make(chan struct{})
+// Clients returns the number of connected clients
+func (s *WebSocketServer) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
 
-	// Goroutine to wait for a client to connect
-	go func() {
-		for {
-			// Check if there are any clients connected
-			if len(s.clients) > 0 {
-				// Signal that a client is connected
-				close(connected)
-				return
-			}
-			// Sleep for a short duration before checking again
-			time.Sleep(100 * time.Millisecond)
+// Close disconnects every client
+func (s *WebSocketServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		delete(s.clients, c)
+		c.close()
+	}
+}
+
+// WaitForConnection blocks until at least one client is connected.
+// It returns an error if none connects within timeout; a timeout of zero waits forever.
+func (s *WebSocketServer) WaitForConnection(timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		s.mu.Lock()
+		if len(s.clients) > 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		if s.connected == nil {
+			s.connected = make(chan struct{})
 		}
+		connected := s.connected
+		s.mu.Unlock()
+		select {
+		case <-connected:
+		case <-expired:
+			return fmt.Errorf("no websocket client connected within %s", timeout)
+		}
+	}
+}
+
+// ListenAndServe serves the websocket on /ws at Addr until ctx is cancelled
+func (s *WebSocketServer) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", s.Addr, err)
+	}
+	return s.ServeListener(ctx, ln)
+}
+
+// ServeListener serves the websocket on /ws until ctx is cancelled,
+// then disconnects clients and shuts down gracefully
+func (s *WebSocketServer) ServeListener(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/ws", s.Handler())
+	srv := &http.Server{Handler: mux}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
 	}()
+	log.Println("WebSocket server started on", ln.Addr())
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("error serving websocket: %w", err)
+	case <-ctx.Done():
+	}
+	// hijacked websocket connections are not closed by Shutdown
+	s.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error shutting down websocket server: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error serving websocket: %w", err)
+	}
+	return nil
+}
 
-	// Wait until a client connects
-	<-connected
+// Serve serves the websocket on /ws at Addr until the process exits
+func (s *WebSocketServer) Serve() {
+	if err := s.ListenAndServe(context.Background()); err != nil {
+		log.Println(err)
+	}
 }
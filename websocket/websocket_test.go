@@ -0,0 +1,185 @@
+package websocket_test
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/lordtatty/goraff/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dial(t *testing.T, url string) *gws.Conn {
+	t.Helper()
+	conn, _, err := gws.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *gws.Conn) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	return string(msg)
+}
+
+func TestWebSocketServer_Broadcast(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+
+	a := dial(t, srv.URL)
+	b := dial(t, srv.URL)
+	assert.Eventually(func() bool { return sut.Clients() == 2 }, time.Second, 5*time.Millisecond)
+
+	sut.Send("one")
+	sut.Send("two")
+	for _, c := range []*gws.Conn{a, b} {
+		assert.Equal("one", read(t, c))
+		assert.Equal("two", read(t, c))
+	}
+
+	a.Close()
+	assert.Eventually(func() bool { return sut.Clients() == 1 }, time.Second, 5*time.Millisecond)
+	sut.Send("three")
+	assert.Equal("three", read(t, b))
+}
+
+func TestWebSocketServer_SlowClientDoesNotBlock(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	sut.ClientQueue = 4
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+
+	_ = dial(t, srv.URL) // never reads
+	assert.NoError(sut.WaitForConnection(time.Second))
+
+	msg := strings.Repeat("x", 32*1024)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			sut.Send(msg)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked on a slow client")
+	}
+	assert.Eventually(func() bool { return sut.Clients() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestWebSocketServer_WaitForConnection(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+
+	assert.EqualError(sut.WaitForConnection(20*time.Millisecond), "no websocket client connected within 20ms")
+
+	result := make(chan error)
+	go func() { result <- sut.WaitForConnection(2 * time.Second) }()
+	_ = dial(t, srv.URL)
+	assert.NoError(<-result)
+}
+
+func TestWebSocketServer_Ping(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	sut.PingInterval = 50 * time.Millisecond
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+
+	conn := dial(t, srv.URL)
+	var pings atomic.Int32
+	conn.SetPingHandler(func(data string) error {
+		pings.Add(1)
+		return conn.WriteControl(gws.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	// ping and pong frames are handled while reading
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	assert.Eventually(func() bool { return pings.Load() >= 3 }, 2*time.Second, 5*time.Millisecond)
+	// answering pings keeps the client connected beyond the pong deadline
+	assert.Equal(1, sut.Clients())
+}
+
+func TestWebSocketServer_UnansweredPingsDisconnect(t *testing.T) {
+	assert := assert.New(t)
+	sut := websocket.NewWebSocketServer("")
+	sut.PingInterval = 10 * time.Millisecond
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+
+	_ = dial(t, srv.URL) // never reads, so never answers pings
+	assert.NoError(sut.WaitForConnection(time.Second))
+	assert.Eventually(func() bool { return sut.Clients() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestWebSocketServer_ServeListenerShutdown(t *testing.T) {
+	assert := assert.New(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	sut := websocket.NewWebSocketServer("")
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() { result <- sut.ServeListener(ctx, ln) }()
+
+	conn := dial(t, "http://"+ln.Addr().String()+"/ws")
+	assert.NoError(sut.WaitForConnection(time.Second))
+	cancel()
+	assert.NoError(<-result)
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	assert.True(gws.IsCloseError(err, gws.CloseGoingAway), "got %v", err)
+}
+
+type replaySource struct {
+	since chan uint64
+}
+
+func (r *replaySource) Replay(since uint64) []string {
+	r.since <- since
+	return []string{"replayed 1", "replayed 2"}
+}
+
+func TestWebSocketServer_Source(t *testing.T) {
+	assert := assert.New(t)
+	src := &replaySource{since: make(chan uint64, 2)}
+	sut := websocket.NewWebSocketServer("")
+	sut.Source = src
+	srv := httptest.NewServer(sut.Handler())
+	defer srv.Close()
+
+	conn := dial(t, srv.URL)
+	assert.Equal(uint64(0), <-src.since)
+	sut.Send("live")
+	assert.Equal("replayed 1", read(t, conn))
+	assert.Equal("replayed 2", read(t, conn))
+	assert.Equal("live", read(t, conn))
+
+	conn = dial(t, srv.URL+"?since=42")
+	assert.Equal(uint64(42), <-src.since)
+	assert.Equal("replayed 1", read(t, conn))
+
+	_, resp, err := gws.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?since=abc", nil)
+	assert.Error(err)
+	assert.Equal(400, resp.StatusCode)
+}
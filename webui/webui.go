@@ -0,0 +1,69 @@
+package webui
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+const defaultWebSocketURL = "/ws"
+
+//go:embed index.html
+var files embed.FS
+
+var page = template.Must(template.ParseFS(files, "index.html"))
+
+// Viewer serves a single page that watches runs live over the websocket.
+// It renders the graph, its sub-graphs, node statuses and errors, and values as they stream in,
+// applying the snapshot and patch messages described by outputs.DiffSchema.
+//
+// The page reconnects with ?since=<seq> when the connection drops. Open it with ?graph_id=<id>
+// to follow a single graph, or ?ws=<url> to connect somewhere other than WebSocketURL.
+//
+//	mux.Handle("/ws", ws.Handler())
+//	mux.Handle("/", webui.New())
+type Viewer struct {
+	Title string
+	// WebSocketURL is where the page connects. A path is taken as relative to the page's host.
+	// Defaults to /ws.
+	WebSocketURL string
+	// OnError, when set, is called when the page cannot be written, such as to a client
+	// that has gone away. Such errors are otherwise ignored.
+	OnError func(err error)
+}
+
+func New() *Viewer {
+	return &Viewer{}
+}
+
+type pageConfig struct {
+	Title        string `json:"title"`
+	WebSocketURL string `json:"websocket_url"`
+}
+
+func (v *Viewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := pageConfig{Title: v.Title, WebSocketURL: v.WebSocketURL}
+	if cfg.Title == "" {
+		cfg.Title = "goraff"
+	}
+	if cfg.WebSocketURL == "" {
+		cfg.WebSocketURL = defaultWebSocketURL
+	}
+	buf := &bytes.Buffer{}
+	if err := page.Execute(buf, cfg); err != nil {
+		http.Error(w, fmt.Sprintf("error rendering viewer: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := buf.WriteTo(w); err != nil && v.OnError != nil {
+		v.OnError(fmt.Errorf("error writing viewer: %w", err))
+	}
+}
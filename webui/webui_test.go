@@ -0,0 +1,67 @@
+package webui_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lordtatty/goraff/webui"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, h http.Handler, method string) (*http.Response, string) {
+	req := httptest.NewRequest(method, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	res := rec.Result()
+	b, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return res, string(b)
+}
+
+func TestViewer_ServesPage(t *testing.T) {
+	assert := assert.New(t)
+	res, body := get(t, webui.New(), http.MethodGet)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("text/html; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Contains(body, "<title>goraff</title>")
+	assert.Contains(body, `"websocket_url":"/ws"`)
+	// the page applies patches itself, so it must know every op
+	for _, op := range []string{"node_added", "key_appended", "key_set", "status_changed", "subgraph_attached"} {
+		assert.Contains(body, `"`+op+`"`)
+	}
+}
+
+func TestViewer_Config(t *testing.T) {
+	assert := assert.New(t)
+	sut := &webui.Viewer{Title: "Run <1>", WebSocketURL: "wss://example.com/live"}
+	_, body := get(t, sut, http.MethodGet)
+	assert.Contains(body, "<title>Run &lt;1&gt;</title>")
+	assert.NotContains(body, "Run <1>")
+	assert.Contains(body, `"websocket_url":"wss://example.com/live"`)
+}
+
+func TestViewer_MethodNotAllowed(t *testing.T) {
+	assert := assert.New(t)
+	res, _ := get(t, webui.New(), http.MethodPost)
+	assert.Equal(http.StatusMethodNotAllowed, res.StatusCode)
+	assert.Equal("GET, HEAD", res.Header.Get("Allow"))
+}
+
+// brokenWriter is a client that has gone away
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (b brokenWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("connection reset")
+}
+
+func TestViewer_OnError(t *testing.T) {
+	var got error
+	sut := &webui.Viewer{OnError: func(err error) { got = err }}
+	sut.ServeHTTP(brokenWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualError(t, got, "error writing viewer: connection reset")
+}